@@ -26,7 +26,7 @@ declare global {
       gender: string;
       age?: number;
       date_of_birth: string;
-      location_point: string;
+      location?: { lat: number; lon: number };
       city: string;
       education: string;
       occupation: string;
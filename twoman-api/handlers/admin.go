@@ -9,11 +9,18 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"twoman/globals"
 	"twoman/handlers/helpers/admin"
+	"twoman/handlers/helpers/birthdate"
 	"twoman/handlers/helpers/database"
+	"twoman/handlers/helpers/discovery"
 	"twoman/handlers/helpers/friendship"
+	"twoman/handlers/helpers/geocoder"
+	"twoman/handlers/helpers/interests"
 	"twoman/handlers/helpers/matches"
+	"twoman/handlers/helpers/photos"
 	"twoman/handlers/helpers/profile"
+	"twoman/handlers/helpers/seen"
 	"twoman/handlers/helpers/user"
 	"twoman/handlers/response"
 	"twoman/schemas"
@@ -153,6 +160,9 @@ func (h Handler) HandleAdminGetProfile() http.Handler {
 			return
 		}
 
+		// Admins see the same exact location the owner does
+		profileRecord.ShowLocation()
+
 		response.OKWithData(w, "Successfully got profile", profileRecord)
 	})
 }
@@ -287,8 +297,7 @@ func (h Handler) HandleAdminCreateProfile() http.Handler {
 			return
 		}
 
-		if len(profileData.Interests) > 50 {
-			response.BadRequest(w, "Interests must be less than 50 characters")
+		if !validInterestNames(w, profileData.Interests) {
 			return
 		}
 
@@ -307,7 +316,7 @@ func (h Handler) HandleAdminCreateProfile() http.Handler {
 			return
 		}
 
-		city, err := profile.GetCity(profileData.Lat, profileData.Lon, h.maps)
+		city, err := geocoder.CityOrEmpty(h.geocoder, profileData.Lat, profileData.Lon)
 
 		if err != nil {
 			log.Println(err)
@@ -322,10 +331,8 @@ func (h Handler) HandleAdminCreateProfile() http.Handler {
 			return
 		}
 
-		minAge := 18
-		minBirthDate := time.Now().AddDate(-minAge, 0, 0)
-		if parsedDateOfBirth.After(minBirthDate) {
-			response.BadRequest(w, "User must be at least 18 years old")
+		if err := birthdate.Validate(parsedDateOfBirth); err != nil {
+			birthdateErrorResponse(w, err)
 			return
 		}
 
@@ -341,22 +348,36 @@ func (h Handler) HandleAdminCreateProfile() http.Handler {
 			Education:            "",
 			Occupation:           "",
 			Interests:            profileData.Interests,
-			Image1:               profileData.Image1,
-			Image2:               profileData.Image2,
-			Image3:               profileData.Image3,
-			Image4:               profileData.Image4,
 			PreferredGender:      profileData.PreferredGender,
 			PreferredAgeMin:      profileData.PreferredAgeMin,
 			PreferredAgeMax:      profileData.PreferredAgeMax,
 			PreferredDistanceMax: profileData.PreferredDistanceMax,
 		}
 
-		if err := profile.CreateProfileWithData(newProfile, db); err != nil {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := profile.CreateProfileWithData(newProfile, tx); err != nil {
+				return err
+			}
+
+			if _, err := photos.AdminSetImages(newProfile.UserID, []string{profileData.Image1, profileData.Image2, profileData.Image3, profileData.Image4}, tx); err != nil {
+				return err
+			}
+
+			_, err := interests.SetFromNames(newProfile.UserID, profileData.Interests, tx)
+			return err
+		})
+		if err != nil {
+			photoErrorResponse(w, err)
+			return
+		}
+
+		createdProfile, err := profile.GetProfileById(newProfile.UserID, db)
+		if err != nil {
 			response.InternalServerError(w, err, "Something went wrong")
 			return
 		}
 
-		response.OKWithData(w, "Successfully created profile", newProfile)
+		response.OKWithData(w, "Successfully created profile", createdProfile)
 	})
 }
 
@@ -372,7 +393,7 @@ func (h Handler) HandleAdminDeleteProfile() http.Handler {
 			return
 		}
 
-		if err := user.DeleteUser(uint(parsedProfileId), h.DB(r), h.s3); err != nil {
+		if err := user.DeleteUser(uint(parsedProfileId), h.DB(r), h.s3, h.rdb); err != nil {
 			response.InternalServerError(w, err, "Something went wrong")
 			return
 		}
@@ -383,6 +404,7 @@ func (h Handler) HandleAdminDeleteProfile() http.Handler {
 
 func (h Handler) HandleAdminUpdateProfile() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminId := r.Context().Value(globals.AdminMiddlewareKey).(uint)
 
 		profileId := r.PathValue("profileId")
 
@@ -401,9 +423,18 @@ func (h Handler) HandleAdminUpdateProfile() http.Handler {
 			return
 		}
 
-		if err := profile.AdminUpdateProfile(requestBody, uint(parsedProfileId), h.DB(r), h.s3, h.maps); err != nil {
+		if !validInterestNames(w, requestBody.Interests) {
+			return
+		}
+
+		if err := profile.AdminUpdateProfile(requestBody, uint(parsedProfileId), adminId, h.DB(r), h.s3, h.geocoder); err != nil {
 			log.Println(err)
-			response.InternalServerError(w, err, "Something went wrong")
+			if errors.Is(err, birthdate.ErrTooYoung) || errors.Is(err, birthdate.ErrInvalidDate) {
+				birthdateErrorResponse(w, err)
+				return
+			}
+
+			usernameErrorResponse(w, err)
 			return
 		}
 
@@ -649,10 +680,24 @@ func (h Handler) HandleAdminDeleteMatch() http.Handler {
 			return
 		}
 
-		// Delete profile views between matched profiles
-		err = profile.DeleteProfileViewsForMatch(*match, h.DB(r))
+		// Delete passes between matched profiles
+		err = profile.DeletePassesForMatch(*match, h.DB(r))
 		if err != nil {
-			log.Printf("Error deleting profile views: %v", err)
+			log.Printf("Error deleting profile passes: %v", err)
+		}
+
+		matchProfileIDs := []uint{match.Profile1ID, match.Profile3ID}
+		if match.Profile2ID != nil {
+			matchProfileIDs = append(matchProfileIDs, *match.Profile2ID)
+		}
+		if match.Profile4ID != nil {
+			matchProfileIDs = append(matchProfileIDs, *match.Profile4ID)
+		}
+
+		for _, profileID := range matchProfileIDs {
+			if err := seen.Unmark(profileID, matchProfileIDs, h.DB(r), h.rdb); err != nil {
+				log.Printf("Error clearing seen profiles: %v", err)
+			}
 		}
 
 		response.OK(w, "Match Deleted")
@@ -798,8 +843,125 @@ func (h Handler) HandleAdminSeedDemoDatabase() http.Handler {
 		lat := 34.0549
 		lng := -118.2426
 
-		database.CreateUsersAndProfiles(h.demoDB, 100, lat, lng, h.maps)
+		database.CreateUsersAndProfiles(h.demoDB, 100, lat, lng, h.geocoder)
 
 		response.OK(w, "Successfully seeded database")
 	})
 }
+
+func (h Handler) HandleAdminGetDiscoveryWeights() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		weights, err := discovery.GetWeights(h.DB(r))
+
+		if err != nil {
+			response.InternalServerError(w, err, "Could not get discovery weights")
+			return
+		}
+
+		response.OKWithData(w, "Successfully got discovery weights", weights)
+	})
+}
+
+func (h Handler) HandleAdminUpdateDiscoveryWeights() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requestBody schemas.DiscoveryWeights
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+
+		weights, err := discovery.UpdateWeights(requestBody, h.DB(r))
+
+		if err != nil {
+			response.BadRequest(w, err.Error())
+			return
+		}
+
+		response.OKWithData(w, "Successfully updated discovery weights", weights)
+	})
+}
+
+func (h Handler) HandleAdminGetRecyclePolicy() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy, err := discovery.GetRecyclePolicy(h.DB(r))
+
+		if err != nil {
+			response.InternalServerError(w, err, "Could not get recycle policy")
+			return
+		}
+
+		response.OKWithData(w, "Successfully got recycle policy", policy)
+	})
+}
+
+func (h Handler) HandleAdminUpdateRecyclePolicy() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requestBody schemas.DiscoveryRecyclePolicy
+		err := json.NewDecoder(r.Body).Decode(&requestBody)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+
+		policy, err := discovery.UpdateRecyclePolicy(requestBody, h.DB(r))
+
+		if err != nil {
+			response.BadRequest(w, err.Error())
+			return
+		}
+
+		response.OKWithData(w, "Successfully updated recycle policy", policy)
+	})
+}
+
+func (h Handler) HandleAdminExplainDiscovery() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		profileId := r.PathValue("profileId")
+
+		parsedProfileId, err := strconv.ParseUint(profileId, 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid profile id")
+			return
+		}
+
+		limit := 20
+		if limitParam := r.URL.Query().Get("limit"); limitParam != "" {
+			limit, err = strconv.Atoi(limitParam)
+
+			if err != nil || limit < 1 || limit > discovery.CandidatePoolSize {
+				response.BadRequest(w, fmt.Sprintf("Limit must be between 1 and %d", discovery.CandidatePoolSize))
+				return
+			}
+		}
+
+		profileRecord, err := profile.GetProfileById(uint(parsedProfileId), h.DB(r))
+
+		if err != nil {
+			response.NotFound(w, "Profile not found")
+			return
+		}
+
+		weights, err := discovery.GetWeights(h.DB(r))
+
+		if err != nil {
+			response.InternalServerError(w, err, "Could not get discovery weights")
+			return
+		}
+
+		ranked, err := discovery.Discover(*profileRecord, limit, h.DB(r), h.rdb)
+
+		if err != nil {
+			response.InternalServerError(w, err, "Could not rank candidates")
+			return
+		}
+
+		response.OKWithData(w, "Successfully explained discovery", map[string]interface{}{
+			"weights":    weights,
+			"candidates": ranked,
+		})
+	})
+}
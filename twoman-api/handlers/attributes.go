@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"twoman/handlers/helpers/attributes"
+	"twoman/handlers/response"
+	"twoman/schemas"
+	"twoman/types"
+)
+
+func (h Handler) HandleGetAttributes() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			definitions, err := attributes.Definitions(false, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			response.OKWithData(w, "Successfully got profile attributes", definitions)
+		}
+	})
+}
+
+func (h Handler) HandleAdminGetAttributes() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		definitions, err := attributes.Definitions(true, h.DB(r))
+
+		if err != nil {
+			response.InternalServerError(w, err, "Could not get profile attributes")
+			return
+		}
+
+		response.OKWithData(w, "Successfully got profile attributes", definitions)
+	})
+}
+
+func (h Handler) HandleAdminCreateAttribute() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requestBody types.AdminCreateAttributeRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+
+		definition, err := attributes.CreateDefinition(schemas.ProfileAttribute{
+			Key:           strings.TrimSpace(requestBody.Key),
+			Label:         requestBody.Label,
+			Type:          requestBody.Type,
+			AllowedValues: requestBody.AllowedValues,
+			Min:           requestBody.Min,
+			Max:           requestBody.Max,
+			Visibility:    requestBody.Visibility,
+			Filterable:    requestBody.Filterable,
+		}, h.DB(r))
+
+		if err != nil {
+			attributeErrorResponse(w, err)
+			return
+		}
+
+		response.OKWithData(w, "Successfully created profile attribute", definition)
+	})
+}
+
+func (h Handler) HandleAdminUpdateAttribute() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attributeId, err := strconv.ParseUint(r.PathValue("attributeId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid attribute id")
+			return
+		}
+
+		var requestBody types.AdminUpdateAttributeRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+
+		definition, err := attributes.UpdateDefinition(uint(attributeId), attributes.DefinitionUpdate{
+			Label:         requestBody.Label,
+			Type:          requestBody.Type,
+			AllowedValues: requestBody.AllowedValues,
+			Min:           requestBody.Min,
+			Max:           requestBody.Max,
+			Visibility:    requestBody.Visibility,
+			Filterable:    requestBody.Filterable,
+			Active:        requestBody.Active,
+			Position:      requestBody.Position,
+		}, h.DB(r))
+
+		if err != nil {
+			attributeErrorResponse(w, err)
+			return
+		}
+
+		response.OKWithData(w, "Successfully updated profile attribute", definition)
+	})
+}
+
+func (h Handler) HandleAdminDeleteAttribute() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attributeId, err := strconv.ParseUint(r.PathValue("attributeId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid attribute id")
+			return
+		}
+
+		if err := attributes.DeleteDefinition(uint(attributeId), h.DB(r)); err != nil {
+			attributeErrorResponse(w, err)
+			return
+		}
+
+		response.OK(w, "Successfully deleted profile attribute")
+	})
+}
+
+// attributeErrorResponse turns profile attribute definition and value errors into client errors
+func attributeErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, attributes.ErrAttributeNotFound):
+		response.NotFound(w, "Profile attribute not found")
+	case errors.Is(err, attributes.ErrUnknownAttribute), errors.Is(err, attributes.ErrInvalidValue),
+		errors.Is(err, attributes.ErrInvalidKey), errors.Is(err, attributes.ErrDuplicateKey),
+		errors.Is(err, attributes.ErrInvalidType), errors.Is(err, attributes.ErrInvalidVisibility),
+		errors.Is(err, attributes.ErrInvalidLabel), errors.Is(err, attributes.ErrInvalidOptions),
+		errors.Is(err, attributes.ErrInvalidRange), errors.Is(err, attributes.ErrPrivateFilterable),
+		errors.Is(err, attributes.ErrTypeInUse):
+		response.BadRequest(w, err.Error())
+	default:
+		response.InternalServerError(w, err, "Something went wrong")
+	}
+}
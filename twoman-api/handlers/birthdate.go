@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"twoman/globals"
+	"twoman/handlers/helpers/birthdate"
+	"twoman/handlers/response"
+	"twoman/types"
+)
+
+func (h Handler) HandleAdminGetDateOfBirthChanges() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		changes, err := birthdate.ListPending(h.DB(r))
+
+		if err != nil {
+			response.InternalServerError(w, err, "Could not get date of birth changes")
+			return
+		}
+
+		response.OKWithData(w, "Successfully got date of birth changes", changes)
+	})
+}
+
+func (h Handler) HandleAdminReviewDateOfBirthChange() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminId := r.Context().Value(globals.AdminMiddlewareKey).(uint)
+
+		changeId, err := strconv.ParseUint(r.PathValue("changeId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid change id")
+			return
+		}
+
+		var requestBody types.AdminReviewDateOfBirthRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+
+		change, err := birthdate.Decide(uint(changeId), requestBody.Status, requestBody.Reason, adminId, h.DB(r))
+
+		if err != nil {
+			birthdateErrorResponse(w, err)
+			return
+		}
+
+		response.OKWithData(w, "Successfully reviewed date of birth change", change)
+	})
+}
+
+func (h Handler) HandleAdminGetDateOfBirthHistory() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parsedProfileId, err := strconv.ParseUint(r.PathValue("profileId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid profileId")
+			return
+		}
+
+		changes, err := birthdate.History(uint(parsedProfileId), h.DB(r))
+
+		if err != nil {
+			response.InternalServerError(w, err, "Something went wrong")
+			return
+		}
+
+		response.OKWithData(w, "Successfully got date of birth history", changes)
+	})
+}
+
+// birthdateErrorResponse turns date of birth validation and review errors into client errors
+func birthdateErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, birthdate.ErrProfileNotFound):
+		response.NotFound(w, "Could not find requested user")
+	case errors.Is(err, birthdate.ErrChangeNotFound):
+		response.NotFound(w, "Date of birth change not found")
+	case errors.Is(err, birthdate.ErrChangePending):
+		response.Conflict(w, err.Error())
+	case errors.Is(err, birthdate.ErrTooYoung), errors.Is(err, birthdate.ErrInvalidDate),
+		errors.Is(err, birthdate.ErrUnchanged), errors.Is(err, birthdate.ErrInvalidStatus),
+		errors.Is(err, birthdate.ErrAlreadyReviewed), errors.Is(err, birthdate.ErrReasonRequired):
+		response.BadRequest(w, err.Error())
+	default:
+		response.InternalServerError(w, err, "Something went wrong")
+	}
+}
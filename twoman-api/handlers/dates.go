@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"twoman/globals"
+	"twoman/handlers/helpers/chat"
+	"twoman/handlers/helpers/dates"
+	"twoman/handlers/helpers/matches"
+	"twoman/handlers/helpers/socket"
+	"twoman/handlers/response"
+	"twoman/schemas"
+	"twoman/types"
+)
+
+func (h Handler) HandleGetMatchDatePlans() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+
+			matchId, err := strconv.ParseUint(r.PathValue("matchId"), 10, 64)
+
+			if err != nil {
+				response.BadRequest(w, "Invalid match id")
+				return
+			}
+
+			if !chat.VerifyUserInMatch(session.UserID, uint(matchId), h.DB(r)) {
+				response.Forbidden(w, "You are not a part of this match")
+				return
+			}
+
+			plans, err := dates.GetMatchDatePlans(uint(matchId), h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			response.OKWithData(w, "Successfully retrieved date plans", plans)
+		}
+	})
+}
+
+func (h Handler) HandleCreateDatePlan() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+
+			matchId, err := strconv.ParseUint(r.PathValue("matchId"), 10, 64)
+
+			if err != nil {
+				response.BadRequest(w, "Invalid match id")
+				return
+			}
+
+			var request types.CreateDatePlanRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			proposedTime, err := time.Parse(time.RFC3339, request.ProposedTime)
+
+			if err != nil {
+				response.BadRequest(w, "Invalid proposed time")
+				return
+			}
+
+			if proposedTime.Before(time.Now()) {
+				response.BadRequest(w, "Proposed time must be in the future")
+				return
+			}
+
+			venueName := strings.TrimSpace(request.VenueName)
+
+			if venueName == "" {
+				response.BadRequest(w, "Venue name is required")
+				return
+			}
+
+			if len(venueName) > 100 {
+				response.BadRequest(w, "Venue name must be less than 100 characters")
+				return
+			}
+
+			if len(request.Note) > 200 {
+				response.BadRequest(w, "Note must be less than 200 characters")
+				return
+			}
+
+			// 0 is a real latitude and longitude, so only a missing coordinate counts as no location
+			if request.VenueLat == nil || request.VenueLon == nil {
+				response.BadRequest(w, "Venue location is required")
+				return
+			}
+
+			if !schemas.NewPoint(*request.VenueLat, *request.VenueLon).Valid() {
+				response.BadRequest(w, "Invalid venue location")
+				return
+			}
+
+			if !chat.VerifyUserInMatch(session.UserID, uint(matchId), h.DB(r)) {
+				response.Forbidden(w, "You are not a part of this match")
+				return
+			}
+
+			plan, match, err := dates.CreateDatePlan(uint(matchId), session.UserID, proposedTime, venueName, *request.VenueLat, *request.VenueLon, strings.TrimSpace(request.Note), h.DB(r))
+
+			if err != nil {
+				dateErrorResponse(w, err)
+				return
+			}
+
+			h.broadcastDatePlan(plan, match, true, r)
+
+			response.OKWithData(w, "Successfully created date plan", plan)
+		}
+	})
+}
+
+func (h Handler) HandleRSVPDatePlan() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+
+			dateId, err := strconv.ParseUint(r.PathValue("dateId"), 10, 64)
+
+			if err != nil {
+				response.BadRequest(w, "Invalid date id")
+				return
+			}
+
+			var request types.DateRSVPRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			if request.Response != schemas.RSVPGoing && request.Response != schemas.RSVPMaybe && request.Response != schemas.RSVPDeclined {
+				response.BadRequest(w, "Response must be going, maybe or declined")
+				return
+			}
+
+			previousPlan, err := dates.GetDatePlanByID(uint(dateId), h.DB(r))
+
+			if err != nil {
+				response.NotFound(w, "Date plan not found")
+				return
+			}
+
+			plan, match, err := dates.RSVPDatePlan(uint(dateId), session.UserID, request.Response, h.DB(r))
+
+			if err != nil {
+				dateErrorResponse(w, err)
+				return
+			}
+
+			h.broadcastDatePlan(plan, match, plan.Status != previousPlan.Status, r)
+
+			response.OKWithData(w, "Successfully updated rsvp", plan)
+		}
+	})
+}
+
+func (h Handler) HandleCancelDatePlan() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+
+			dateId, err := strconv.ParseUint(r.PathValue("dateId"), 10, 64)
+
+			if err != nil {
+				response.BadRequest(w, "Invalid date id")
+				return
+			}
+
+			plan, match, err := dates.CancelDatePlan(uint(dateId), session.UserID, h.DB(r))
+
+			if err != nil {
+				dateErrorResponse(w, err)
+				return
+			}
+
+			h.broadcastDatePlan(plan, match, true, r)
+
+			response.OKWithData(w, "Successfully cancelled date plan", plan)
+		}
+	})
+}
+
+// broadcastDatePlan sends the plan to every participant. Push notifications only go out when notify is set,
+// so RSVPs that don't change the plan status stay silent.
+func (h Handler) broadcastDatePlan(plan *schemas.DatePlan, match *schemas.Matches, notify bool, r *http.Request) {
+	dateSocketMessage := types.SocketMessage[*schemas.DatePlan]{
+		Type: "date",
+		Data: plan,
+	}
+
+	for _, participantID := range matches.GetParticipantIDs(*match) {
+		if notify {
+			socket.BroadcastToUser(participantID, dateSocketMessage, h.rdb, h.DB(r))
+		} else {
+			socket.PublishToUser(participantID, dateSocketMessage, h.rdb)
+		}
+	}
+
+	log.Printf("Broadcast date plan %d to match %d", plan.ID, match.ID)
+}
+
+// dateErrorResponse turns date planning errors into client errors
+func dateErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, dates.ErrPlanNotFound):
+		response.NotFound(w, "Date plan not found")
+	case errors.Is(err, dates.ErrMatchNotFound):
+		response.NotFound(w, "Match not found")
+	case errors.Is(err, dates.ErrNotParticipant):
+		response.Forbidden(w, "You are not a part of this match")
+	case errors.Is(err, dates.ErrMatchNotAccepted), errors.Is(err, dates.ErrInvalidResponse),
+		errors.Is(err, dates.ErrPlanCancelled), errors.Is(err, dates.ErrPlanPassed):
+		response.BadRequest(w, err.Error())
+	default:
+		response.InternalServerError(w, err, "Something went wrong")
+	}
+}
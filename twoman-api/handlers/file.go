@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"log"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"twoman/globals"
+	"twoman/handlers/helpers/duplicates"
 	"twoman/handlers/response"
 	"twoman/types"
 
@@ -55,12 +57,27 @@ func (h Handler) HandleFileUpload() http.Handler {
 			}(file)
 
 			mimeType := header.Header.Get("Content-Type")
-			if !allowedMimeTypes[mimeType] {
+			if !allowedMimeTypes[mimeType] && !fileHelper.AudioMimeTypes[mimeType] {
 				log.Println("Invalid file type")
 				response.BadRequest(w, "Invalid file type")
 				return
 			}
 
+			var db *gorm.DB
+
+			if h.DB(r) == h.liveDB {
+				log.Print("liveDB")
+				db = h.liveDB
+			} else {
+				log.Print("demoDB")
+				db = h.demoDB
+			}
+
+			if fileHelper.AudioMimeTypes[mimeType] {
+				h.uploadAudio(w, file, session.UserID, db)
+				return
+			}
+
 			// maxFileSize := int64(5 * 1024 * 1024) // 5MB
 			// if header.Size > maxFileSize {
 			// 	log.Println("File too large")
@@ -77,46 +94,128 @@ func (h Handler) HandleFileUpload() http.Handler {
 				return
 			}
 
-			_, err = h.s3.PutObject(&s3.PutObjectInput{
-				Key:    aws.String(filename),
-				Body:   bytes.NewReader(processedImage),
-				Bucket: aws.String(os.Getenv("AWS_BUCKET_NAME")),
-			})
+			renditions := make(map[string]string, len(processedImage.Renditions))
+			uploaded := make([]string, 0, len(processedImage.Renditions))
+			for _, rendition := range processedImage.Renditions {
+				key := fileHelper.RenditionFilename(filename, rendition.Name)
 
-			if err != nil {
-				log.Println("Error uploading file:", err)
-				response.InternalServerError(w, err, "Error uploading file")
-				return
-			}
+				_, err = h.s3.PutObject(&s3.PutObjectInput{
+					Key:         aws.String(key),
+					Body:        bytes.NewReader(rendition.Data),
+					Bucket:      aws.String(os.Getenv("AWS_BUCKET_NAME")),
+					ContentType: aws.String("image/jpeg"),
+				})
 
-			var db *gorm.DB
+				if err != nil {
+					log.Println("Error uploading file:", err)
+					h.deleteUploadedKeys(uploaded)
+					response.InternalServerError(w, err, "Error uploading file")
+					return
+				}
 
-			if h.DB(r) == h.liveDB {
-				log.Print("liveDB")
-				db = h.liveDB
-			} else {
-				log.Print("demoDB")
-				db = h.demoDB
+				uploaded = append(uploaded, key)
+				renditions[rendition.Name] = os.Getenv("AWS_PUBLIC_URL") + "/" + key
 			}
 
-			fileMetadata, err := fileHelper.StoreFileMetadata(filename, int64(len(processedImage)), session.UserID, db)
+			fileMetadata, err := fileHelper.StoreImageMetadata(filename, processedImage, session.UserID, db)
 			if err != nil {
 				log.Println("Error storing file metadata:", err)
+				h.deleteUploadedKeys(uploaded)
 				response.InternalServerError(w, err, "Error storing file metadata")
 				return
 			}
 
+			// Stolen photos are reused across fake accounts, so queue near copies of other accounts' photos for review
+			if _, err := duplicates.Check(fileMetadata, db); err != nil {
+				log.Println("Error checking for duplicate photos:", err)
+			}
+
 			type FileResponse struct {
-				URL  string `json:"url"`
-				ID   uint   `json:"id"`
-				File string `json:"file"`
+				URL        string            `json:"url"`
+				ID         uint              `json:"id"`
+				File       string            `json:"file"`
+				Renditions map[string]string `json:"renditions"`
+				Blurhash   string            `json:"blurhash"`
+				Width      int               `json:"width"`
+				Height     int               `json:"height"`
 			}
 
 			response.OKWithData(w, "file uploaded successfully", FileResponse{
-				URL:  os.Getenv("AWS_PUBLIC_URL") + "/" + filename,
-				ID:   fileMetadata.ID,
-				File: fileMetadata.Filename,
+				URL:        os.Getenv("AWS_PUBLIC_URL") + "/" + filename,
+				ID:         fileMetadata.ID,
+				File:       fileMetadata.Filename,
+				Renditions: renditions,
+				Blurhash:   fileMetadata.Blurhash,
+				Width:      fileMetadata.Width,
+				Height:     fileMetadata.Height,
 			})
 		}
 	})
 }
+
+// uploadAudio stores a short audio clip, e.g. for a voice prompt. Clips are stored as uploaded, only their size and
+// duration are checked.
+func (h Handler) uploadAudio(w http.ResponseWriter, file multipart.File, userID uint, db *gorm.DB) {
+	processedAudio, err := fileHelper.ProcessAudio(file)
+	if err != nil {
+		switch {
+		case errors.Is(err, fileHelper.ErrUnsupportedAudio), errors.Is(err, fileHelper.ErrAudioTooLarge),
+			errors.Is(err, fileHelper.ErrAudioTooLong), errors.Is(err, fileHelper.ErrAudioTooShort):
+			response.BadRequest(w, err.Error())
+		default:
+			log.Println("Error processing audio:", err)
+			response.InternalServerError(w, err, "Error processing audio")
+		}
+		return
+	}
+
+	filename := fileHelper.GenerateUniqueAudioFilename(processedAudio)
+
+	_, err = h.s3.PutObject(&s3.PutObjectInput{
+		Key:         aws.String(filename),
+		Body:        bytes.NewReader(processedAudio.Data),
+		Bucket:      aws.String(os.Getenv("AWS_BUCKET_NAME")),
+		ContentType: aws.String(processedAudio.ContentType),
+	})
+
+	if err != nil {
+		log.Println("Error uploading file:", err)
+		response.InternalServerError(w, err, "Error uploading file")
+		return
+	}
+
+	fileMetadata, err := fileHelper.StoreAudioMetadata(filename, processedAudio, userID, db)
+	if err != nil {
+		log.Println("Error storing file metadata:", err)
+		h.deleteUploadedKeys([]string{filename})
+		response.InternalServerError(w, err, "Error storing file metadata")
+		return
+	}
+
+	type AudioResponse struct {
+		URL        string `json:"url"`
+		ID         uint   `json:"id"`
+		File       string `json:"file"`
+		DurationMs int    `json:"duration_ms"`
+	}
+
+	response.OKWithData(w, "file uploaded successfully", AudioResponse{
+		URL:        os.Getenv("AWS_PUBLIC_URL") + "/" + filename,
+		ID:         fileMetadata.ID,
+		File:       fileMetadata.Filename,
+		DurationMs: fileMetadata.DurationMs,
+	})
+}
+
+// deleteUploadedKeys removes the objects of an upload that failed part way, so files without metadata aren't left in
+// the bucket where nothing would ever delete them
+func (h Handler) deleteUploadedKeys(keys []string) {
+	for _, key := range keys {
+		if _, err := h.s3.DeleteObject(&s3.DeleteObjectInput{
+			Key:    aws.String(key),
+			Bucket: aws.String(os.Getenv("AWS_BUCKET_NAME")),
+		}); err != nil {
+			log.Printf("Error deleting partial upload %s: %v", key, err)
+		}
+	}
+}
@@ -4,12 +4,12 @@ import (
 	"fmt"
 	"net/http"
 	"twoman/globals"
+	"twoman/handlers/helpers/geocoder"
 	wsvalidator "twoman/handlers/helpers/websocket"
 
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/redis/go-redis/v9"
 	"github.com/twilio/twilio-go"
-	"googlemaps.github.io/maps"
 	"gorm.io/gorm"
 )
 
@@ -19,12 +19,12 @@ type Handler struct {
 	s3                   *s3.S3
 	rdb                  *redis.Client
 	rateLimitingDatabase *redis.Client
-	maps                 *maps.Client
+	geocoder             geocoder.Geocoder
 	tw                   *twilio.RestClient
 	wsValidator          *wsvalidator.Validator
 }
 
-func NewHandler(liveDB, demoDB *gorm.DB, s3 *s3.S3, rdb *redis.Client, rlmdb *redis.Client, maps *maps.Client, twClient *twilio.RestClient) *Handler {
+func NewHandler(liveDB, demoDB *gorm.DB, s3 *s3.S3, rdb *redis.Client, rlmdb *redis.Client, geo geocoder.Geocoder, twClient *twilio.RestClient) *Handler {
 	validator, err := wsvalidator.NewValidator()
 	if err != nil {
 		panic(fmt.Sprintf("Failed to initialize WebSocket validator: %v", err))
@@ -36,7 +36,7 @@ func NewHandler(liveDB, demoDB *gorm.DB, s3 *s3.S3, rdb *redis.Client, rlmdb *re
 		s3:                   s3,
 		rdb:                  rdb,
 		rateLimitingDatabase: rlmdb,
-		maps:                 maps,
+		geocoder:             geo,
 		tw:                   twClient,
 		wsValidator:          validator,
 	}
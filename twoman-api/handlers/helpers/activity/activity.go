@@ -0,0 +1,37 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"twoman/schemas"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// TOUCH_INTERVAL is the most often a user's last_active_at is written
+const TOUCH_INTERVAL = 5 * time.Minute
+
+func touchKey(userID uint) string {
+	return fmt.Sprintf("user:%d:active", userID)
+}
+
+// Touch records that the user is active right now. Writes are throttled to one per TOUCH_INTERVAL, so it is
+// cheap enough to call on every request and websocket message.
+func Touch(userID uint, db *gorm.DB, rdb *redis.Client) {
+	acquired, err := rdb.SetNX(context.Background(), touchKey(userID), 1, TOUCH_INTERVAL).Result()
+	if err != nil {
+		log.Printf("Error throttling activity for user %d: %v", userID, err)
+		return
+	}
+
+	if !acquired {
+		return
+	}
+
+	if err := db.Model(&schemas.Profile{}).Where("user_id = ?", userID).Update("last_active_at", time.Now()).Error; err != nil {
+		log.Printf("Error recording activity for user %d: %v", userID, err)
+	}
+}
@@ -0,0 +1,578 @@
+package attributes
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"twoman/schemas"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxTextLength caps text values and allowed values
+	MaxTextLength = 100
+	// MaxLabelLength caps how long an attribute's label can be
+	MaxLabelLength = 128
+	// MaxAllowedValues caps how many options a select attribute can have
+	MaxAllowedValues = 50
+)
+
+var (
+	ErrAttributeNotFound = errors.New("profile attribute not found")
+	ErrUnknownAttribute  = errors.New("unknown profile attribute")
+	ErrInvalidValue      = errors.New("invalid profile attribute value")
+	ErrInvalidKey        = errors.New("keys must start with a letter and only use lower case letters, digits and underscores")
+	ErrDuplicateKey      = errors.New("a profile attribute with this key already exists")
+	ErrInvalidType       = errors.New("type must be text, number, boolean, select or multi_select")
+	ErrInvalidVisibility = errors.New("visibility must be public or private")
+	ErrInvalidLabel      = fmt.Errorf("labels must be between 1 and %d characters", MaxLabelLength)
+	ErrInvalidOptions    = fmt.Errorf("select attributes need between 1 and %d allowed values of at most %d characters", MaxAllowedValues, MaxTextLength)
+	ErrInvalidRange      = errors.New("min must not be greater than max")
+	ErrPrivateFilterable = errors.New("private attributes can't be filterable")
+	ErrTypeInUse         = errors.New("the type of an attribute can't change once profiles have values for it")
+	ErrNotFilterable     = errors.New("profile attribute can't be filtered on")
+	ErrInvalidFilter     = errors.New("invalid profile attribute filter")
+)
+
+var keyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+var validTypes = map[string]bool{
+	schemas.AttributeTypeText:        true,
+	schemas.AttributeTypeNumber:      true,
+	schemas.AttributeTypeBoolean:     true,
+	schemas.AttributeTypeSelect:      true,
+	schemas.AttributeTypeMultiSelect: true,
+}
+
+// DefaultDefinitions are the attributes a new database starts with, in display order
+var DefaultDefinitions = []schemas.ProfileAttribute{
+	{Key: "height", Label: "Height (cm)", Type: schemas.AttributeTypeNumber, Min: float(90), Max: float(250), Filterable: true},
+	{Key: "drinking", Label: "Drinking", Type: schemas.AttributeTypeSelect, AllowedValues: []string{"Never", "Rarely", "Socially", "Often"}, Filterable: true},
+	{Key: "smoking", Label: "Smoking", Type: schemas.AttributeTypeSelect, AllowedValues: []string{"Never", "Socially", "Regularly", "Trying to quit"}, Filterable: true},
+	{Key: "religion", Label: "Religion", Type: schemas.AttributeTypeSelect, AllowedValues: []string{"Agnostic", "Atheist", "Buddhist", "Catholic", "Christian", "Hindu", "Jewish", "Muslim", "Spiritual", "Other"}, Filterable: true},
+	{Key: "kids", Label: "Kids", Type: schemas.AttributeTypeSelect, AllowedValues: []string{"Don't have kids", "Have kids", "Want kids", "Don't want kids", "Open to kids"}, Filterable: true},
+	{Key: "pronouns", Label: "Pronouns", Type: schemas.AttributeTypeMultiSelect, AllowedValues: []string{"she/her", "he/him", "they/them"}},
+}
+
+// SeedDefinitions adds the default attributes, leaving ones that already exist (by key) as admins left them
+func SeedDefinitions(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for position, definition := range DefaultDefinitions {
+			definition.Position = position
+			definition.Visibility = schemas.AttributeVisibilityPublic
+			definition.Active = true
+
+			var existing schemas.ProfileAttribute
+			if err := tx.Where(schemas.ProfileAttribute{Key: definition.Key}).Attrs(definition).FirstOrCreate(&existing).Error; err != nil {
+				return fmt.Errorf("error seeding profile attribute %s: %w", definition.Key, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// Definitions returns the attribute definitions in display order. Admins also see inactive ones.
+func Definitions(includeInactive bool, db *gorm.DB) ([]schemas.ProfileAttribute, error) {
+	query := db.Model(&schemas.ProfileAttribute{})
+	if !includeInactive {
+		query = query.Where("active = ?", true)
+	}
+
+	definitions := []schemas.ProfileAttribute{}
+	if err := query.Order("position, id").Find(&definitions).Error; err != nil {
+		return nil, fmt.Errorf("error loading profile attributes: %w", err)
+	}
+
+	return definitions, nil
+}
+
+// CreateDefinition adds an attribute definition at the end of the list
+func CreateDefinition(definition schemas.ProfileAttribute, db *gorm.DB) (*schemas.ProfileAttribute, error) {
+	if definition.Visibility == "" {
+		definition.Visibility = schemas.AttributeVisibilityPublic
+	}
+	definition.ID = 0
+	definition.Active = true
+
+	if err := validateDefinition(&definition); err != nil {
+		return nil, err
+	}
+
+	var duplicates int64
+	if err := db.Model(&schemas.ProfileAttribute{}).Where("`key` = ?", definition.Key).Count(&duplicates).Error; err != nil {
+		return nil, err
+	}
+	if duplicates > 0 {
+		return nil, ErrDuplicateKey
+	}
+
+	var last int
+	if err := db.Model(&schemas.ProfileAttribute{}).Select("COALESCE(MAX(position), -1)").Scan(&last).Error; err != nil {
+		return nil, err
+	}
+	definition.Position = last + 1
+
+	if err := db.Create(&definition).Error; err != nil {
+		return nil, err
+	}
+
+	return &definition, nil
+}
+
+// DefinitionUpdate holds the fields of a definition an admin wants to change. Nil fields are left unchanged; the key
+// can't change because clients send values by key.
+type DefinitionUpdate struct {
+	Label         *string
+	Type          *string
+	AllowedValues *[]string
+	Min           *float64
+	Max           *float64
+	Visibility    *string
+	Filterable    *bool
+	Active        *bool
+	Position      *int
+}
+
+// UpdateDefinition edits an attribute definition. Values that the new allowed values or range no longer accept are
+// removed from profiles.
+func UpdateDefinition(attributeID uint, update DefinitionUpdate, db *gorm.DB) (*schemas.ProfileAttribute, error) {
+	definition, err := getDefinition(attributeID, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.Type != nil && *update.Type != definition.Type {
+		var values int64
+		if err := db.Model(&schemas.ProfileAttributeValue{}).Where("attribute_id = ?", attributeID).Count(&values).Error; err != nil {
+			return nil, err
+		}
+		if values > 0 {
+			return nil, ErrTypeInUse
+		}
+		definition.Type = *update.Type
+	}
+	if update.Label != nil {
+		definition.Label = *update.Label
+	}
+	if update.AllowedValues != nil {
+		definition.AllowedValues = *update.AllowedValues
+	}
+	if update.Min != nil {
+		definition.Min = update.Min
+	}
+	if update.Max != nil {
+		definition.Max = update.Max
+	}
+	if update.Visibility != nil {
+		definition.Visibility = *update.Visibility
+	}
+	if update.Filterable != nil {
+		definition.Filterable = *update.Filterable
+	}
+	if update.Active != nil {
+		definition.Active = *update.Active
+	}
+	if update.Position != nil {
+		definition.Position = *update.Position
+	}
+
+	if err := validateDefinition(definition); err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(definition).Error; err != nil {
+			return err
+		}
+
+		if !definition.Filterable {
+			if err := tx.Where("attribute_id = ?", attributeID).Delete(&schemas.DiscoveryAttributeFilter{}).Error; err != nil {
+				return err
+			}
+		}
+
+		return pruneValues(definition, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return definition, nil
+}
+
+// DeleteDefinition removes an attribute definition along with every profile's value and filter for it
+func DeleteDefinition(attributeID uint, db *gorm.DB) error {
+	definition, err := getDefinition(attributeID, db)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("attribute_id = ?", attributeID).Delete(&schemas.ProfileAttributeValue{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("attribute_id = ?", attributeID).Delete(&schemas.DiscoveryAttributeFilter{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(definition).Error
+	})
+}
+
+// Get returns a profile's values for active attributes. Private attributes are only included for the owner.
+func Get(profileID uint, includePrivate bool, db *gorm.DB) (schemas.Attributes, error) {
+	profile := schemas.Profile{UserID: profileID}
+	if err := attach([]*schemas.Profile{&profile}, includePrivate, db); err != nil {
+		return nil, err
+	}
+
+	if profile.Attributes == nil {
+		return schemas.Attributes{}, nil
+	}
+
+	return profile.Attributes, nil
+}
+
+// Attach fills Attributes on each profile with its public values, using a single query
+func Attach(profiles []*schemas.Profile, db *gorm.DB) error {
+	return attach(profiles, false, db)
+}
+
+// Set validates and stores a profile's attribute values, keyed by attribute key. Attributes that aren't in values
+// are left unchanged, and a null or empty value removes the profile's value. Nothing is stored if any value is
+// invalid.
+func Set(profileID uint, values map[string]interface{}, db *gorm.DB) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	definitions, err := Definitions(false, db)
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[string]schemas.ProfileAttribute, len(definitions))
+	for _, definition := range definitions {
+		byKey[definition.Key] = definition
+	}
+
+	rows := make(map[uint][]string, len(values))
+	for _, key := range sortedKeys(values) {
+		value := values[key]
+		definition, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownAttribute, key)
+		}
+
+		stored, err := normalizeValue(definition, value)
+		if err != nil {
+			return err
+		}
+		rows[definition.ID] = stored
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for attributeID, stored := range rows {
+			if err := tx.Where("profile_id = ? AND attribute_id = ?", profileID, attributeID).Delete(&schemas.ProfileAttributeValue{}).Error; err != nil {
+				return err
+			}
+
+			if len(stored) == 0 {
+				continue
+			}
+
+			created := make([]schemas.ProfileAttributeValue, 0, len(stored))
+			for _, value := range stored {
+				created = append(created, schemas.ProfileAttributeValue{ProfileID: profileID, AttributeID: attributeID, Value: value})
+			}
+			if err := tx.Create(&created).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteAll removes a profile's values and attribute filters, e.g. when the account is deleted
+func DeleteAll(profileID uint, db *gorm.DB) error {
+	if err := db.Where("profile_id = ?", profileID).Delete(&schemas.DiscoveryAttributeFilter{}).Error; err != nil {
+		return err
+	}
+
+	return db.Where("profile_id = ?", profileID).Delete(&schemas.ProfileAttributeValue{}).Error
+}
+
+func getDefinition(attributeID uint, db *gorm.DB) (*schemas.ProfileAttribute, error) {
+	var definition schemas.ProfileAttribute
+	if err := db.First(&definition, attributeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAttributeNotFound
+		}
+		return nil, err
+	}
+
+	return &definition, nil
+}
+
+func validateDefinition(definition *schemas.ProfileAttribute) error {
+	definition.Label = strings.TrimSpace(definition.Label)
+
+	if !keyPattern.MatchString(definition.Key) {
+		return ErrInvalidKey
+	}
+	if definition.Label == "" || len(definition.Label) > MaxLabelLength {
+		return ErrInvalidLabel
+	}
+	if !validTypes[definition.Type] {
+		return ErrInvalidType
+	}
+	if definition.Visibility != schemas.AttributeVisibilityPublic && definition.Visibility != schemas.AttributeVisibilityPrivate {
+		return ErrInvalidVisibility
+	}
+	if definition.Filterable && definition.Visibility == schemas.AttributeVisibilityPrivate {
+		return ErrPrivateFilterable
+	}
+
+	if isSelect(definition.Type) {
+		seen := make(map[string]bool, len(definition.AllowedValues))
+		options := make([]string, 0, len(definition.AllowedValues))
+		for _, option := range definition.AllowedValues {
+			option = strings.TrimSpace(option)
+			if option == "" || len(option) > MaxTextLength {
+				return ErrInvalidOptions
+			}
+			if seen[strings.ToLower(option)] {
+				continue
+			}
+			seen[strings.ToLower(option)] = true
+			options = append(options, option)
+		}
+		if len(options) == 0 || len(options) > MaxAllowedValues {
+			return ErrInvalidOptions
+		}
+		definition.AllowedValues = options
+	} else {
+		definition.AllowedValues = nil
+	}
+
+	if definition.Type == schemas.AttributeTypeNumber {
+		if definition.Min != nil && definition.Max != nil && *definition.Min > *definition.Max {
+			return ErrInvalidRange
+		}
+	} else {
+		definition.Min, definition.Max = nil, nil
+	}
+
+	return nil
+}
+
+// normalizeValue checks a value sent by a client against its definition and returns what to store for it, which is
+// empty when the value should be removed
+func normalizeValue(definition schemas.ProfileAttribute, value interface{}) ([]string, error) {
+	invalid := func(reason string) error {
+		return fmt.Errorf("%w: %s %s", ErrInvalidValue, definition.Key, reason)
+	}
+
+	if value == nil {
+		return nil, nil
+	}
+	if text, ok := value.(string); ok && strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	switch definition.Type {
+	case schemas.AttributeTypeText:
+		text, ok := value.(string)
+		if !ok {
+			return nil, invalid("must be text")
+		}
+		text = strings.TrimSpace(text)
+		if len(text) > MaxTextLength {
+			return nil, invalid(fmt.Sprintf("must be less than %d characters", MaxTextLength))
+		}
+		return []string{text}, nil
+
+	case schemas.AttributeTypeNumber:
+		number, ok := value.(float64)
+		if !ok || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, invalid("must be a number")
+		}
+		if definition.Min != nil && number < *definition.Min {
+			return nil, invalid(fmt.Sprintf("must be at least %s", formatNumber(*definition.Min)))
+		}
+		if definition.Max != nil && number > *definition.Max {
+			return nil, invalid(fmt.Sprintf("must be at most %s", formatNumber(*definition.Max)))
+		}
+		return []string{formatNumber(number)}, nil
+
+	case schemas.AttributeTypeBoolean:
+		flag, ok := value.(bool)
+		if !ok {
+			return nil, invalid("must be true or false")
+		}
+		return []string{strconv.FormatBool(flag)}, nil
+
+	case schemas.AttributeTypeSelect:
+		text, ok := value.(string)
+		if !ok {
+			return nil, invalid("must be one of its allowed values")
+		}
+		option, ok := allowedValue(definition, text)
+		if !ok {
+			return nil, invalid("must be one of its allowed values")
+		}
+		return []string{option}, nil
+
+	case schemas.AttributeTypeMultiSelect:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, invalid("must be a list of its allowed values")
+		}
+		seen := make(map[string]bool, len(list))
+		picked := make([]string, 0, len(list))
+		for _, item := range list {
+			text, ok := item.(string)
+			if !ok {
+				return nil, invalid("must be a list of its allowed values")
+			}
+			option, ok := allowedValue(definition, text)
+			if !ok {
+				return nil, invalid("must be a list of its allowed values")
+			}
+			if !seen[option] {
+				seen[option] = true
+				picked = append(picked, option)
+			}
+		}
+		return picked, nil
+	}
+
+	return nil, invalid("has an unknown type")
+}
+
+// allowedValue matches text against the definition's allowed values ignoring case, returning the allowed spelling
+func allowedValue(definition schemas.ProfileAttribute, text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	for _, option := range definition.AllowedValues {
+		if strings.EqualFold(option, text) {
+			return option, true
+		}
+	}
+	return "", false
+}
+
+// pruneValues removes stored values that a changed definition no longer accepts
+func pruneValues(definition *schemas.ProfileAttribute, tx *gorm.DB) error {
+	if isSelect(definition.Type) {
+		return tx.Where("attribute_id = ? AND value NOT IN ?", definition.ID, definition.AllowedValues).Delete(&schemas.ProfileAttributeValue{}).Error
+	}
+
+	if definition.Type != schemas.AttributeTypeNumber {
+		return nil
+	}
+
+	var outside []string
+	var args []interface{}
+	if definition.Min != nil {
+		outside = append(outside, "CAST(value AS DECIMAL(20,6)) < ?")
+		args = append(args, *definition.Min)
+	}
+	if definition.Max != nil {
+		outside = append(outside, "CAST(value AS DECIMAL(20,6)) > ?")
+		args = append(args, *definition.Max)
+	}
+	if len(outside) == 0 {
+		return nil
+	}
+
+	return tx.Where("attribute_id = ?", definition.ID).Where(strings.Join(outside, " OR "), args...).Delete(&schemas.ProfileAttributeValue{}).Error
+}
+
+func attach(profiles []*schemas.Profile, includePrivate bool, db *gorm.DB) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(profiles))
+	for _, profile := range profiles {
+		ids = append(ids, profile.UserID)
+	}
+
+	query := db.Table("profile_attribute_values pav").
+		Select("pav.profile_id, pav.value, pa.`key`, pa.type").
+		Joins("JOIN profile_attributes pa ON pa.id = pav.attribute_id").
+		Where("pav.profile_id IN ? AND pa.active = ?", ids, true)
+	if !includePrivate {
+		query = query.Where("pa.visibility = ?", schemas.AttributeVisibilityPublic)
+	}
+
+	var rows []struct {
+		ProfileID uint
+		Value     string
+		Key       string
+		Type      string
+	}
+	if err := query.Order("pa.position, pav.value").Scan(&rows).Error; err != nil {
+		return fmt.Errorf("error loading profile attributes: %w", err)
+	}
+
+	byProfile := make(map[uint]schemas.Attributes, len(profiles))
+	for _, row := range rows {
+		values, ok := byProfile[row.ProfileID]
+		if !ok {
+			values = schemas.Attributes{}
+			byProfile[row.ProfileID] = values
+		}
+
+		switch row.Type {
+		case schemas.AttributeTypeNumber:
+			number, err := strconv.ParseFloat(row.Value, 64)
+			if err != nil {
+				continue
+			}
+			values[row.Key] = number
+		case schemas.AttributeTypeBoolean:
+			values[row.Key] = row.Value == "true"
+		case schemas.AttributeTypeMultiSelect:
+			list, _ := values[row.Key].([]string)
+			values[row.Key] = append(list, row.Value)
+		default:
+			values[row.Key] = row.Value
+		}
+	}
+
+	for _, profile := range profiles {
+		profile.Attributes = byProfile[profile.UserID]
+	}
+
+	return nil
+}
+
+// sortedKeys returns the keys of values in order, so validation errors are reported consistently
+func sortedKeys(values map[string]interface{}) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isSelect(attributeType string) bool {
+	return attributeType == schemas.AttributeTypeSelect || attributeType == schemas.AttributeTypeMultiSelect
+}
+
+func formatNumber(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+func float(value float64) *float64 {
+	return &value
+}
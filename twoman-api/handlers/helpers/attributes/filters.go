@@ -0,0 +1,160 @@
+package attributes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"twoman/schemas"
+	"twoman/types"
+
+	"gorm.io/gorm"
+)
+
+// Filters validates a user's requested attribute filters and returns them ready to store. Only active, filterable
+// attributes can be filtered on, and each attribute at most once.
+func Filters(profileID uint, requested []types.AttributeFilterRequest, db *gorm.DB) ([]schemas.DiscoveryAttributeFilter, error) {
+	filters := []schemas.DiscoveryAttributeFilter{}
+	if len(requested) == 0 {
+		return filters, nil
+	}
+
+	definitions, err := Definitions(false, db)
+	if err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]schemas.ProfileAttribute, len(definitions))
+	for _, definition := range definitions {
+		byKey[definition.Key] = definition
+	}
+
+	seen := make(map[string]bool, len(requested))
+	for _, request := range requested {
+		definition, ok := byKey[request.Key]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownAttribute, request.Key)
+		}
+		if !definition.Filterable {
+			return nil, fmt.Errorf("%w: %s", ErrNotFilterable, request.Key)
+		}
+		if seen[request.Key] {
+			return nil, fmt.Errorf("%w: %s is filtered more than once", ErrInvalidFilter, request.Key)
+		}
+		seen[request.Key] = true
+
+		filter := schemas.DiscoveryAttributeFilter{
+			ProfileID:   profileID,
+			AttributeID: definition.ID,
+			Attribute:   definition,
+			Key:         definition.Key,
+			Required:    request.Required,
+		}
+
+		if definition.Type == schemas.AttributeTypeNumber {
+			if request.Min == nil && request.Max == nil {
+				return nil, fmt.Errorf("%w: %s needs a min or a max", ErrInvalidFilter, request.Key)
+			}
+			if request.Min != nil && request.Max != nil && *request.Min > *request.Max {
+				return nil, fmt.Errorf("%w: %s min must not be greater than max", ErrInvalidFilter, request.Key)
+			}
+			filter.Min, filter.Max = request.Min, request.Max
+		} else {
+			if len(request.Values) == 0 || len(request.Values) > MaxAllowedValues {
+				return nil, fmt.Errorf("%w: %s needs between 1 and %d values", ErrInvalidFilter, request.Key, MaxAllowedValues)
+			}
+			for _, value := range request.Values {
+				stored, ok := filterValue(definition, value)
+				if !ok {
+					return nil, fmt.Errorf("%w: %s can't match %q", ErrInvalidFilter, request.Key, value)
+				}
+				filter.Values = append(filter.Values, stored)
+			}
+		}
+
+		filters = append(filters, filter)
+	}
+
+	return filters, nil
+}
+
+// Usable reports whether a stored filter still applies. Filters on attributes that were deactivated or made
+// unfilterable since are kept but ignored.
+func Usable(filter schemas.DiscoveryAttributeFilter) bool {
+	return filter.Attribute.ID != 0 && filter.Attribute.Active && filter.Attribute.Filterable
+}
+
+// FilterSQL returns a condition that only keeps candidates (rows of profiles under alias) whose value for the
+// filter's attribute matches it
+func FilterSQL(filter schemas.DiscoveryAttributeFilter, alias string) (string, []interface{}) {
+	conditions := []string{"pav.attribute_id = ?"}
+	args := []interface{}{filter.AttributeID}
+
+	if filter.Attribute.Type == schemas.AttributeTypeNumber {
+		if filter.Min != nil {
+			conditions = append(conditions, "CAST(pav.value AS DECIMAL(20,6)) >= ?")
+			args = append(args, *filter.Min)
+		}
+		if filter.Max != nil {
+			conditions = append(conditions, "CAST(pav.value AS DECIMAL(20,6)) <= ?")
+			args = append(args, *filter.Max)
+		}
+	} else {
+		conditions = append(conditions, "pav.value IN ?")
+		args = append(args, filter.Values)
+	}
+
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM profile_attribute_values pav WHERE pav.profile_id = %s.user_id AND %s)",
+		alias, strings.Join(conditions, " AND ")), args
+}
+
+// Matches reports whether a candidate's attributes, as filled by Attach, match the filter
+func Matches(filter schemas.DiscoveryAttributeFilter, values schemas.Attributes) bool {
+	value, ok := values[filter.Attribute.Key]
+	if !ok {
+		return false
+	}
+
+	switch typed := value.(type) {
+	case float64:
+		return (filter.Min == nil || typed >= *filter.Min) && (filter.Max == nil || typed <= *filter.Max)
+	case bool:
+		return containsFold(filter.Values, strconv.FormatBool(typed))
+	case string:
+		return containsFold(filter.Values, typed)
+	case []string:
+		for _, item := range typed {
+			if containsFold(filter.Values, item) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// filterValue returns how a filter value is stored for the attribute, matching the stored profile values
+func filterValue(definition schemas.ProfileAttribute, value string) (string, bool) {
+	value = strings.TrimSpace(value)
+
+	switch definition.Type {
+	case schemas.AttributeTypeSelect, schemas.AttributeTypeMultiSelect:
+		return allowedValue(definition, value)
+	case schemas.AttributeTypeBoolean:
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", false
+		}
+		return strconv.FormatBool(flag), true
+	default:
+		return value, value != "" && len(value) <= MaxTextLength
+	}
+}
+
+func containsFold(values []string, value string) bool {
+	for _, candidate := range values {
+		if strings.EqualFold(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package birthdate
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	"twoman/schemas"
+	"twoman/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxAge is the oldest age accepted. Older dates are treated as not set, which is how profiles made before dates of
+// birth were required look.
+const MaxAge = 100
+
+var (
+	ErrTooYoung        = fmt.Errorf("user must be at least %d years old", utils.MinimumAge)
+	ErrInvalidDate     = fmt.Errorf("date of birth must be less than %d years ago", MaxAge)
+	ErrUnchanged       = errors.New("date of birth is the same as the current one")
+	ErrChangePending   = errors.New("a date of birth change is already waiting for review")
+	ErrProfileNotFound = errors.New("profile not found")
+	ErrChangeNotFound  = errors.New("date of birth change not found")
+	ErrInvalidStatus   = errors.New("status must be approved or rejected")
+	ErrAlreadyReviewed = errors.New("date of birth change is not waiting for review")
+	ErrReasonRequired  = errors.New("a reason is required to reject a date of birth change")
+	ErrAdminRequired   = errors.New("an admin is required to change a date of birth")
+)
+
+// Validate checks that someone born on dateOfBirth is old enough to use the app and the date is believable
+func Validate(dateOfBirth time.Time) error {
+	age := utils.Age(dateOfBirth, time.Now())
+	if age < utils.MinimumAge {
+		return ErrTooYoung
+	}
+	if age >= MaxAge {
+		return ErrInvalidDate
+	}
+	return nil
+}
+
+// IsSet reports whether a profile's date of birth has been filled in
+func IsSet(dateOfBirth time.Time) bool {
+	return utils.Age(dateOfBirth, time.Now()) < MaxAge
+}
+
+// Request changes a user's date of birth. Filling in a missing date and the first change are applied straight away.
+// Later changes are saved as pending until an admin decides on them.
+func Request(profileID uint, dateOfBirth time.Time, reason string, db *gorm.DB) (*schemas.DateOfBirthChange, error) {
+	if err := Validate(dateOfBirth); err != nil {
+		return nil, err
+	}
+
+	var change schemas.DateOfBirthChange
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Locking the profile stops two requests both counting no earlier changes and being applied straight away
+		var profile schemas.Profile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("user_id", "date_of_birth").
+			Where("user_id = ?", profileID).Limit(1).Find(&profile).Error; err != nil {
+			return err
+		}
+		if profile.UserID == 0 {
+			return ErrProfileNotFound
+		}
+		if sameDay(profile.DateOfBirth, dateOfBirth) {
+			return ErrUnchanged
+		}
+
+		change = schemas.DateOfBirthChange{
+			ProfileID:      profileID,
+			OldDateOfBirth: profile.DateOfBirth,
+			NewDateOfBirth: dateOfBirth,
+			Reason:         reason,
+		}
+
+		var pending, applied int64
+		if err := tx.Model(&schemas.DateOfBirthChange{}).Where("profile_id = ? AND status = ?", profileID, schemas.DateOfBirthPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrChangePending
+		}
+		if err := tx.Model(&schemas.DateOfBirthChange{}).Where("profile_id = ? AND status = ?", profileID, schemas.DateOfBirthApplied).
+			Count(&applied).Error; err != nil {
+			return err
+		}
+
+		switch {
+		case !IsSet(profile.DateOfBirth):
+			change.Status = schemas.DateOfBirthInitial
+		case applied == 0:
+			change.Status = schemas.DateOfBirthApplied
+		default:
+			change.Status = schemas.DateOfBirthPending
+		}
+
+		if err := tx.Create(&change).Error; err != nil {
+			return err
+		}
+		if change.Status == schemas.DateOfBirthPending {
+			return nil
+		}
+
+		return tx.Model(&schemas.Profile{}).Where("user_id = ?", profileID).Update("date_of_birth", dateOfBirth).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+// AdminSet validates and records a date of birth an admin set directly, if it changed. The caller saves the date on
+// the profile.
+func AdminSet(profileID uint, oldDateOfBirth, dateOfBirth time.Time, adminID uint, db *gorm.DB) error {
+	if err := Validate(dateOfBirth); err != nil {
+		return err
+	}
+	if sameDay(oldDateOfBirth, dateOfBirth) {
+		return nil
+	}
+	if adminID == 0 {
+		return ErrAdminRequired
+	}
+
+	now := time.Now()
+	return db.Create(&schemas.DateOfBirthChange{
+		ProfileID:      profileID,
+		OldDateOfBirth: oldDateOfBirth,
+		NewDateOfBirth: dateOfBirth,
+		Status:         schemas.DateOfBirthAdmin,
+		ReviewedBy:     &adminID,
+		ReviewedAt:     &now,
+	}).Error
+}
+
+// ListPending returns the changes waiting for an admin, oldest first
+func ListPending(db *gorm.DB) ([]schemas.DateOfBirthChange, error) {
+	changes := []schemas.DateOfBirthChange{}
+	if err := db.Where("status = ?", schemas.DateOfBirthPending).Order("created_at ASC").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// History lists every date of birth change of a profile, newest first
+func History(profileID uint, db *gorm.DB) ([]schemas.DateOfBirthChange, error) {
+	changes := []schemas.DateOfBirthChange{}
+	if err := db.Where("profile_id = ?", profileID).Order("created_at DESC").Find(&changes).Error; err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// Decide approves or rejects a pending change. Approving it updates the profile.
+func Decide(changeID uint, status string, reason string, adminID uint, db *gorm.DB) (*schemas.DateOfBirthChange, error) {
+	if status != schemas.DateOfBirthApproved && status != schemas.DateOfBirthRejected {
+		return nil, ErrInvalidStatus
+	}
+	if status == schemas.DateOfBirthRejected && reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	var change schemas.DateOfBirthChange
+	if err := db.First(&change, changeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrChangeNotFound
+		}
+		return nil, err
+	}
+
+	if change.Status != schemas.DateOfBirthPending {
+		return nil, ErrAlreadyReviewed
+	}
+
+	// The user may have had a birthday since asking, so check again
+	if status == schemas.DateOfBirthApproved {
+		if err := Validate(change.NewDateOfBirth); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		updated := tx.Model(&schemas.DateOfBirthChange{}).Where("id = ? AND status = ?", change.ID, schemas.DateOfBirthPending).
+			Updates(map[string]interface{}{
+				"status":           status,
+				"rejection_reason": reason,
+				"reviewed_by":      adminID,
+				"reviewed_at":      now,
+			})
+		if updated.Error != nil {
+			return updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			return ErrAlreadyReviewed
+		}
+
+		if status != schemas.DateOfBirthApproved {
+			return nil
+		}
+
+		return tx.Model(&schemas.Profile{}).Where("user_id = ?", change.ProfileID).Update("date_of_birth", change.NewDateOfBirth).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	change.Status = status
+	change.RejectionReason = reason
+	change.ReviewedBy = &adminID
+	change.ReviewedAt = &now
+
+	return &change, nil
+}
+
+// DeleteAll removes a profile's date of birth changes
+func DeleteAll(profileID uint, db *gorm.DB) error {
+	return db.Where("profile_id = ?", profileID).Delete(&schemas.DateOfBirthChange{}).Error
+}
+
+func sameDay(a, b time.Time) bool {
+	return a.UTC().Format(time.DateOnly) == b.UTC().Format(time.DateOnly)
+}
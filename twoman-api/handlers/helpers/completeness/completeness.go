@@ -0,0 +1,162 @@
+package completeness
+
+import (
+	"fmt"
+	"strings"
+	"twoman/schemas"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// RecommendedPhotos is how many photos a profile needs for full marks on photos
+	RecommendedPhotos = 4
+	// RecommendedInterests is how many interests a profile needs for full marks on interests
+	RecommendedInterests = 3
+	// RecommendedPrompts is how many prompt answers a profile needs for full marks on prompts
+	RecommendedPrompts = 2
+)
+
+// Items that make up the score
+const (
+	ItemPhotos     = "photos"
+	ItemBio        = "bio"
+	ItemEducation  = "education"
+	ItemOccupation = "occupation"
+	ItemInterests  = "interests"
+	ItemPrompts    = "prompts"
+)
+
+// items are scored in this order, which is also the order Missing lists them in. Their points add up to 100.
+var items = []struct {
+	key    string
+	points int
+}{
+	{ItemPhotos, 30},
+	{ItemBio, 15},
+	{ItemEducation, 10},
+	{ItemOccupation, 10},
+	{ItemInterests, 15},
+	{ItemPrompts, 20},
+}
+
+// Counts holds how many of the counted items a profile has
+type Counts struct {
+	Photos    int
+	Interests int
+	Prompts   int
+}
+
+// Result is a profile's completeness score out of 100, with the items it could still fill in. Items that are only
+// partly done, like having two of four photos, earn part of their points and are still listed as missing.
+type Result struct {
+	Score   int      `json:"score"`
+	Missing []string `json:"missing"`
+}
+
+// Compute scores a profile from its fields and counts
+func Compute(profile schemas.Profile, counts Counts) Result {
+	result := Result{Missing: []string{}}
+	total := 0.0
+
+	for _, item := range items {
+		done := 0.0
+		switch item.key {
+		case ItemPhotos:
+			done = fraction(counts.Photos, RecommendedPhotos)
+		case ItemBio:
+			done = filled(profile.Bio)
+		case ItemEducation:
+			done = filled(profile.Education)
+		case ItemOccupation:
+			done = filled(profile.Occupation)
+		case ItemInterests:
+			done = fraction(counts.Interests, RecommendedInterests)
+		case ItemPrompts:
+			done = fraction(counts.Prompts, RecommendedPrompts)
+		}
+
+		total += done * float64(item.points)
+		if done < 1 {
+			result.Missing = append(result.Missing, item.key)
+		}
+	}
+
+	result.Score = int(total + 0.5)
+	return result
+}
+
+// ForProfile scores a profile, or returns nil if the user hasn't created one yet
+func ForProfile(profileID uint, db *gorm.DB) (*Result, error) {
+	var profile schemas.Profile
+	if err := db.Where("user_id = ?", profileID).Limit(1).Find(&profile).Error; err != nil {
+		return nil, err
+	}
+	if profile.UserID == 0 {
+		return nil, nil
+	}
+
+	counts, err := CountsFor([]uint{profileID}, db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := Compute(profile, counts[profileID])
+	return &result, nil
+}
+
+// CountsFor loads the photo, interest and prompt counts of several profiles. Rejected photos don't count.
+func CountsFor(profileIDs []uint, db *gorm.DB) (map[uint]Counts, error) {
+	counts := make(map[uint]Counts, len(profileIDs))
+	if len(profileIDs) == 0 {
+		return counts, nil
+	}
+
+	type row struct {
+		ProfileID uint
+		Total     int
+	}
+
+	for _, source := range []struct {
+		table string
+		where string
+		args  []interface{}
+		set   func(*Counts, int)
+	}{
+		{"profile_photos", "moderation_status != ?", []interface{}{schemas.PhotoModerationRejected}, func(c *Counts, n int) { c.Photos = n }},
+		{"profile_interests", "", nil, func(c *Counts, n int) { c.Interests = n }},
+		{"prompt_answers", "", nil, func(c *Counts, n int) { c.Prompts = n }},
+	} {
+		query := db.Table(source.table).Select("profile_id, COUNT(*) AS total").Where("profile_id IN ?", profileIDs)
+		if source.where != "" {
+			query = query.Where(source.where, source.args...)
+		}
+
+		var rows []row
+		if err := query.Group("profile_id").Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("error counting %s: %w", source.table, err)
+		}
+
+		for _, r := range rows {
+			c := counts[r.ProfileID]
+			source.set(&c, r.Total)
+			counts[r.ProfileID] = c
+		}
+	}
+
+	return counts, nil
+}
+
+func fraction(count, recommended int) float64 {
+	if count >= recommended {
+		return 1
+	}
+	return float64(count) / float64(recommended)
+}
+
+func filled(value string) float64 {
+	if strings.TrimSpace(value) == "" {
+		return 0
+	}
+	return 1
+}
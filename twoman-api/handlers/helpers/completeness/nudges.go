@@ -0,0 +1,131 @@
+package completeness
+
+import (
+	"log"
+	"time"
+	"twoman/handlers/helpers/notifications"
+	"twoman/schemas"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// NudgeThreshold is the score below which a profile gets nudged
+	NudgeThreshold = 80
+	// NudgeCooldown is the least time between two nudges to the same profile
+	NudgeCooldown = 7 * 24 * time.Hour
+	// MaxNudges is how many nudges a profile gets before we stop asking
+	MaxNudges = 3
+	// NudgeActiveWithin limits nudges to people who have opened the app lately
+	NudgeActiveWithin = 30 * 24 * time.Hour
+	// NudgeInterval is how often the nudge worker looks for incomplete profiles
+	NudgeInterval = time.Hour
+	// nudgeBatchSize is how many profiles are scored at once
+	nudgeBatchSize = 200
+)
+
+// nudgeMessages say what to fill in next, by the first missing item
+var nudgeMessages = map[string]string{
+	ItemPhotos:     "Profiles with more photos get more matches. Add a few more to yours.",
+	ItemBio:        "Tell people a little about yourself. Add a bio to your profile.",
+	ItemEducation:  "Add your education so people with things in common can find you.",
+	ItemOccupation: "Add what you do to give people something to talk about.",
+	ItemInterests:  "Pick a few interests so we can show you people who share them.",
+	ItemPrompts:    "Answer a prompt to give people an easy way to start a conversation.",
+}
+
+// SendNudges pushes a reminder to recently active users whose profiles score below NudgeThreshold, at most once
+// per NudgeCooldown and MaxNudges times in total
+func SendNudges(db *gorm.DB) error {
+	now := time.Now()
+	sent := 0
+
+	var profiles []schemas.Profile
+	result := db.Where("profiles.last_active_at >= ? AND profiles.visibility != ?", now.Add(-NudgeActiveWithin), schemas.VisibilityPaused).
+		Where("NOT EXISTS (SELECT 1 FROM completeness_nudges cn WHERE cn.profile_id = profiles.user_id AND (cn.count >= ? OR cn.last_sent_at > ?))",
+			MaxNudges, now.Add(-NudgeCooldown)).
+		FindInBatches(&profiles, nudgeBatchSize, func(tx *gorm.DB, batch int) error {
+			ids := make([]uint, 0, len(profiles))
+			for _, profile := range profiles {
+				ids = append(ids, profile.UserID)
+			}
+
+			counts, err := CountsFor(ids, db)
+			if err != nil {
+				return err
+			}
+
+			for _, profile := range profiles {
+				score := Compute(profile, counts[profile.UserID])
+				if score.Score >= NudgeThreshold || len(score.Missing) == 0 {
+					continue
+				}
+
+				claimed, err := claimNudge(profile.UserID, now, db)
+				if err != nil {
+					log.Printf("Error claiming completeness nudge for user %d: %v", profile.UserID, err)
+					continue
+				}
+				if !claimed {
+					continue
+				}
+
+				data := map[string]interface{}{
+					"type":    "profile_completeness",
+					"score":   score.Score,
+					"missing": score.Missing,
+				}
+
+				if err := notifications.SendNotificationV2(profile.UserID, "Complete your profile", nudgeMessages[score.Missing[0]], data, db); err != nil {
+					log.Printf("Error sending completeness nudge to user %d: %v", profile.UserID, err)
+					continue
+				}
+				sent++
+			}
+
+			return nil
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if sent > 0 {
+		log.Printf("Sent %d profile completeness nudges", sent)
+	}
+	return nil
+}
+
+// StartNudgeWorker blocks, sending completeness nudges every NudgeInterval
+func StartNudgeWorker(db *gorm.DB) {
+	ticker := time.NewTicker(NudgeInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := SendNudges(db); err != nil {
+			log.Printf("Error sending completeness nudges: %v", err)
+		}
+	}
+}
+
+// claimNudge records a nudge before it's sent so multiple API instances don't send it twice. It reports false when
+// another instance got there first or the profile isn't due.
+func claimNudge(profileID uint, now time.Time, db *gorm.DB) (bool, error) {
+	created := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&schemas.CompletenessNudge{ProfileID: profileID, Count: 1, LastSentAt: now})
+	if created.Error != nil {
+		return false, created.Error
+	}
+	if created.RowsAffected > 0 {
+		return true, nil
+	}
+
+	updated := db.Model(&schemas.CompletenessNudge{}).
+		Where("profile_id = ? AND count < ? AND last_sent_at <= ?", profileID, MaxNudges, now.Add(-NudgeCooldown)).
+		Updates(map[string]interface{}{"count": gorm.Expr("count + 1"), "last_sent_at": now})
+	if updated.Error != nil {
+		return false, updated.Error
+	}
+
+	return updated.RowsAffected > 0, nil
+}
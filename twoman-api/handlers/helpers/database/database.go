@@ -4,14 +4,13 @@ import (
 	"fmt"
 	"log"
 	"math"
-	"strings"
 	"time"
-	"twoman/handlers/helpers/profile"
+	"twoman/handlers/helpers/geocoder"
+	"twoman/handlers/helpers/interests"
 	"twoman/schemas"
 
 	"math/rand"
 
-	"googlemaps.github.io/maps"
 	"gorm.io/gorm"
 )
 
@@ -20,10 +19,16 @@ var (
 	boyNames  = []string{"John", "Noah", "Liam", "Mason", "Jacob", "William", "Ethan", "Michael", "Alexander", "James"}
 )
 
-func CreateUsersAndProfiles(db *gorm.DB, numUsers int, lat float64, lon float64, mapsClient *maps.Client) {
+func CreateUsersAndProfiles(db *gorm.DB, numUsers int, lat float64, lon float64, geo geocoder.Geocoder) {
 	users := make([]schemas.User, numUsers)
 	profiles := make([]schemas.Profile, numUsers)
 
+	catalog, err := interestCatalog(db)
+	if err != nil {
+		log.Printf("Failed to load interest catalog: %v", err)
+		return
+	}
+
 	// Create users and profiles
 	for i := 0; i < numUsers; i++ {
 		user := schemas.User{
@@ -42,13 +47,14 @@ func CreateUsersAndProfiles(db *gorm.DB, numUsers int, lat float64, lon float64,
 		images := genderImages(gender)
 		latitude, longitude := generateRandomCoordinate(lat, lon)
 
-		city, err := profile.GetCity(latitude, longitude, mapsClient)
+		city, err := geocoder.CityOrEmpty(geo, latitude, longitude)
 
 		if err != nil {
 			log.Printf("Failed to get city: %v", err)
 			continue
 		}
 
+		picked := randomInterests(catalog)
 		name := ""
 
 		if gender == "female" {
@@ -68,7 +74,7 @@ func CreateUsersAndProfiles(db *gorm.DB, numUsers int, lat float64, lon float64,
 			City:                 city,
 			Education:            randomEducation(),
 			Occupation:           randomOccupation(),
-			Interests:            randomInterests(),
+			Interests:            interests.Names(picked),
 			Image1:               images.Image1,
 			Image2:               images.Image2,
 			Image3:               images.Image3,
@@ -77,6 +83,7 @@ func CreateUsersAndProfiles(db *gorm.DB, numUsers int, lat float64, lon float64,
 			PreferredAgeMin:      rand.Intn(10) + 18, // Between 18 and 27
 			PreferredAgeMax:      rand.Intn(32) + 28, // Between 28 and 59
 			PreferredDistanceMax: rand.Intn(50) + 1,  // Between 1 and 50 km
+			LastActiveAt:         randomLastActive(),
 		}
 
 		if err := db.Create(&newProfile).Error; err != nil {
@@ -84,6 +91,28 @@ func CreateUsersAndProfiles(db *gorm.DB, numUsers int, lat float64, lon float64,
 			continue
 		}
 
+		if err := createProfileInterests(user.ID, picked, db); err != nil {
+			log.Printf("Failed to create profile interests: %v", err)
+		}
+
+		for position, url := range []string{images.Image1, images.Image2, images.Image3, images.Image4} {
+			if url == "" {
+				continue
+			}
+
+			photo := schemas.ProfilePhoto{
+				ProfileID:        user.ID,
+				URL:              url,
+				Position:         position,
+				IsPrimary:        position == 0,
+				ModerationStatus: schemas.PhotoModerationApproved,
+			}
+
+			if err := db.Create(&photo).Error; err != nil {
+				log.Printf("Failed to create profile photo: %v", err)
+			}
+		}
+
 		users[i] = user
 		profiles[i] = newProfile
 	}
@@ -132,6 +161,80 @@ func CreateUsersAndProfiles(db *gorm.DB, numUsers int, lat float64, lon float64,
 	}
 }
 
+// CreateBenchmarkProfiles bulk creates numProfiles users and profiles spread within radiusKm of lat, lon. Unlike
+// CreateUsersAndProfiles it skips city lookups and friendships so large datasets can be seeded quickly.
+func CreateBenchmarkProfiles(db *gorm.DB, numProfiles int, lat float64, lon float64, radiusKm float64) error {
+	const batchSize = 1000
+	run := time.Now().Unix()
+
+	catalog, err := interestCatalog(db)
+	if err != nil {
+		return fmt.Errorf("failed to load interest catalog: %w", err)
+	}
+
+	for created := 0; created < numProfiles; created += batchSize {
+		count := min(batchSize, numProfiles-created)
+
+		users := make([]schemas.User, count)
+		for i := range users {
+			n := created + i
+			users[i] = schemas.User{
+				PhoneNumber:     fmt.Sprintf("bench_%d_%d", run, n),
+				Email:           fmt.Sprintf("bench_%d_%d@example.com", run, n),
+				AppleID:         fmt.Sprintf("bench_%d_%d", run, n),
+				OauthProvider:   "local",
+				OauthProviderID: fmt.Sprintf("bench_%d_%d", run, n),
+			}
+		}
+
+		if err := db.CreateInBatches(&users, batchSize).Error; err != nil {
+			return fmt.Errorf("failed to create benchmark users: %w", err)
+		}
+
+		profiles := make([]schemas.Profile, count)
+		var links []schemas.ProfileInterest
+		for i, user := range users {
+			gender := randomGender()
+			latitude, longitude := generateRandomCoordinateWithin(lat, lon, radiusKm)
+
+			picked := randomInterests(catalog)
+			for _, interest := range picked {
+				links = append(links, schemas.ProfileInterest{ProfileID: user.ID, InterestID: interest.ID})
+			}
+
+			profiles[i] = schemas.Profile{
+				UserID:               user.ID,
+				Name:                 "Benchmark",
+				Username:             fmt.Sprintf("bench_%d_%d", run, created+i),
+				Gender:               gender,
+				DateOfBirth:          time.Now().AddDate(-rand.Intn(30)-18, 0, 0),
+				LocationPoint:        *schemas.NewPoint(latitude, longitude),
+				City:                 "Benchmark",
+				Interests:            interests.Names(picked),
+				PreferredGender:      oppositeGender(gender),
+				PreferredAgeMin:      18,
+				PreferredAgeMax:      rand.Intn(32) + 28,
+				PreferredDistanceMax: rand.Intn(100) + 1,
+				LastActiveAt:         randomLastActive(),
+			}
+		}
+
+		if err := db.CreateInBatches(&profiles, batchSize).Error; err != nil {
+			return fmt.Errorf("failed to create benchmark profiles: %w", err)
+		}
+
+		if len(links) > 0 {
+			if err := db.CreateInBatches(&links, batchSize).Error; err != nil {
+				return fmt.Errorf("failed to create benchmark profile interests: %w", err)
+			}
+		}
+
+		log.Printf("Created %d/%d benchmark profiles", created+count, numProfiles)
+	}
+
+	return nil
+}
+
 func randomGender() string {
 	genders := []string{"male", "female"}
 	return genders[rand.Intn(len(genders))]
@@ -170,6 +273,12 @@ func genderImages(gender string) GenderImages {
 	}
 }
 
+// randomLastActive returns a time within the last 29 days so seeded profiles count as active in discovery
+func randomLastActive() *time.Time {
+	lastActive := time.Now().Add(-time.Duration(rand.Int63n(int64(29 * 24 * time.Hour))))
+	return &lastActive
+}
+
 func randomEducation() string {
 	educations := []string{"High School", "Bachelor's", "Master's", "PhD"}
 	return educations[rand.Intn(len(educations))]
@@ -180,14 +289,41 @@ func randomOccupation() string {
 	return occupations[rand.Intn(len(occupations))]
 }
 
-func randomInterests() string {
-	interests := []string{"Sports", "Music", "Travel", "Reading", "Cooking", "Photography"}
-	numInterests := rand.Intn(3) + 1 // 1 to 3 interests
-	selectedInterests := make([]string, numInterests)
-	for i := 0; i < numInterests; i++ {
-		selectedInterests[i] = interests[rand.Intn(len(interests))]
+// interestCatalog seeds the default interest catalog if needed and returns the interests profiles can pick
+func interestCatalog(db *gorm.DB) ([]schemas.Interest, error) {
+	if err := interests.SeedCatalog(db); err != nil {
+		return nil, err
+	}
+
+	var catalog []schemas.Interest
+	if err := db.Where("active = ?", true).Find(&catalog).Error; err != nil {
+		return nil, err
+	}
+
+	return catalog, nil
+}
+
+// randomInterests picks 1 to 5 different interests from the catalog
+func randomInterests(catalog []schemas.Interest) []schemas.Interest {
+	numInterests := min(rand.Intn(5)+1, len(catalog))
+	selectedInterests := make([]schemas.Interest, 0, numInterests)
+	for _, i := range rand.Perm(len(catalog))[:numInterests] {
+		selectedInterests = append(selectedInterests, catalog[i])
+	}
+	return selectedInterests
+}
+
+func createProfileInterests(profileID uint, picked []schemas.Interest, db *gorm.DB) error {
+	if len(picked) == 0 {
+		return nil
+	}
+
+	links := make([]schemas.ProfileInterest, 0, len(picked))
+	for _, interest := range picked {
+		links = append(links, schemas.ProfileInterest{ProfileID: profileID, InterestID: interest.ID})
 	}
-	return strings.Join(selectedInterests, ", ")
+
+	return db.Create(&links).Error
 }
 
 const (
@@ -197,8 +333,10 @@ const (
 
 func generateRandomCoordinate(centralLat, centralLon float64) (float64, float64) {
 	// Convert max distance to kilometers
-	maxDistanceKm := maxDistanceMiles * 1.60934
+	return generateRandomCoordinateWithin(centralLat, centralLon, maxDistanceMiles*1.60934)
+}
 
+func generateRandomCoordinateWithin(centralLat, centralLon float64, maxDistanceKm float64) (float64, float64) {
 	// Generate a random distance within the maximum distance
 	distanceKm := rand.Float64() * maxDistanceKm
 
@@ -0,0 +1,295 @@
+package dates
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"twoman/handlers/helpers/matches"
+	"twoman/handlers/helpers/notifications"
+	"twoman/schemas"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// ReminderLeadTime is how long before a date the reminder push goes out
+	ReminderLeadTime = 2 * time.Hour
+	// ReminderInterval is how often the reminder worker looks for upcoming dates
+	ReminderInterval = time.Minute
+)
+
+var (
+	ErrPlanNotFound     = errors.New("date plan not found")
+	ErrMatchNotFound    = errors.New("match not found")
+	ErrNotParticipant   = errors.New("profile is not a part of this match")
+	ErrMatchNotAccepted = errors.New("dates can only be planned for accepted matches")
+	ErrInvalidResponse  = errors.New("invalid rsvp response")
+	ErrPlanCancelled    = errors.New("date plan has been cancelled")
+	ErrPlanPassed       = errors.New("date plan has already happened")
+)
+
+// CreateDatePlan proposes a date inside an accepted match. The proposer is automatically marked as going.
+func CreateDatePlan(matchID uint, profileID uint, proposedTime time.Time, venueName string, lat, lon float64, note string, db *gorm.DB) (*schemas.DatePlan, *schemas.Matches, error) {
+	match, err := matches.GetMatchByID(matchID, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if match == nil {
+		return nil, nil, ErrMatchNotFound
+	}
+
+	if !matches.IsMatchParticipant(*match, profileID) {
+		return nil, nil, ErrNotParticipant
+	}
+
+	if match.Status != "accepted" {
+		return nil, nil, ErrMatchNotAccepted
+	}
+
+	plan := schemas.DatePlan{
+		MatchID:       matchID,
+		ProposedByID:  profileID,
+		ProposedTime:  proposedTime,
+		VenueName:     venueName,
+		VenueLocation: *schemas.NewPoint(lat, lon),
+		Note:          note,
+		Status:        schemas.DateStatusProposed,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&plan).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&schemas.DateRSVP{
+			DatePlanID: plan.ID,
+			ProfileID:  profileID,
+			Response:   schemas.RSVPGoing,
+		}).Error
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	createdPlan, err := GetDatePlanByID(plan.ID, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return createdPlan, match, nil
+}
+
+// GetDatePlanByID returns a date plan with its proposer and RSVPs loaded
+func GetDatePlanByID(datePlanID uint, db *gorm.DB) (*schemas.DatePlan, error) {
+	var plan schemas.DatePlan
+
+	if err := db.Preload("ProposedBy").Preload("RSVPs").Where("id = ?", datePlanID).First(&plan).Error; err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+// GetMatchDatePlans returns all date plans for a match, soonest first
+func GetMatchDatePlans(matchID uint, db *gorm.DB) ([]schemas.DatePlan, error) {
+	var plans []schemas.DatePlan
+
+	if err := db.Preload("ProposedBy").Preload("RSVPs").Where("match_id = ?", matchID).Order("proposed_time asc").Find(&plans).Error; err != nil {
+		return nil, err
+	}
+
+	return plans, nil
+}
+
+// RSVPDatePlan records a participant's response. The plan is confirmed once every participant is going.
+func RSVPDatePlan(datePlanID uint, profileID uint, response string, db *gorm.DB) (*schemas.DatePlan, *schemas.Matches, error) {
+	if response != schemas.RSVPGoing && response != schemas.RSVPMaybe && response != schemas.RSVPDeclined {
+		return nil, nil, ErrInvalidResponse
+	}
+
+	plan, match, err := getPlanForParticipant(datePlanID, profileID, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if plan.Status == schemas.DateStatusCancelled {
+		return nil, nil, ErrPlanCancelled
+	}
+
+	if plan.ProposedTime.Before(time.Now()) {
+		return nil, nil, ErrPlanPassed
+	}
+
+	var rsvp schemas.DateRSVP
+	err = db.Where("date_plan_id = ? AND profile_id = ?", datePlanID, profileID).First(&rsvp).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, err
+		}
+
+		rsvp = schemas.DateRSVP{
+			DatePlanID: datePlanID,
+			ProfileID:  profileID,
+		}
+	}
+
+	rsvp.Response = response
+	if err := db.Save(&rsvp).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var goingCount int64
+	if err := db.Model(&schemas.DateRSVP{}).Where("date_plan_id = ? AND response = ?", datePlanID, schemas.RSVPGoing).Count(&goingCount).Error; err != nil {
+		return nil, nil, err
+	}
+
+	status := schemas.DateStatusProposed
+	if int(goingCount) == len(matches.GetParticipantIDs(*match)) {
+		status = schemas.DateStatusConfirmed
+	}
+
+	if status != plan.Status {
+		if err := db.Model(&schemas.DatePlan{}).Where("id = ?", datePlanID).Update("status", status).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+
+	updatedPlan, err := GetDatePlanByID(datePlanID, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return updatedPlan, match, nil
+}
+
+// CancelDatePlan cancels a date plan. Any participant can cancel.
+func CancelDatePlan(datePlanID uint, profileID uint, db *gorm.DB) (*schemas.DatePlan, *schemas.Matches, error) {
+	plan, match, err := getPlanForParticipant(datePlanID, profileID, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if plan.Status == schemas.DateStatusCancelled {
+		return plan, match, nil
+	}
+
+	if err := db.Model(&schemas.DatePlan{}).Where("id = ?", datePlanID).Update("status", schemas.DateStatusCancelled).Error; err != nil {
+		return nil, nil, err
+	}
+
+	updatedPlan, err := GetDatePlanByID(datePlanID, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return updatedPlan, match, nil
+}
+
+func getPlanForParticipant(datePlanID uint, profileID uint, db *gorm.DB) (*schemas.DatePlan, *schemas.Matches, error) {
+	plan, err := GetDatePlanByID(datePlanID, db)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrPlanNotFound
+		}
+		return nil, nil, err
+	}
+
+	match, err := matches.GetMatchByID(plan.MatchID, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if match == nil {
+		return nil, nil, ErrMatchNotFound
+	}
+
+	if !matches.IsMatchParticipant(*match, profileID) {
+		return nil, nil, ErrNotParticipant
+	}
+
+	return plan, match, nil
+}
+
+// SendDueReminders pushes a reminder to every participant who hasn't declined a date starting within ReminderLeadTime
+func SendDueReminders(db *gorm.DB) error {
+	now := time.Now()
+
+	var plans []schemas.DatePlan
+	err := db.Preload("RSVPs").
+		Where("status IN ?", []string{schemas.DateStatusProposed, schemas.DateStatusConfirmed}).
+		Where("reminder_sent_at IS NULL").
+		Where("proposed_time BETWEEN ? AND ?", now, now.Add(ReminderLeadTime)).
+		Find(&plans).Error
+
+	if err != nil {
+		return err
+	}
+
+	for _, plan := range plans {
+		// Claim the reminder first so multiple API instances don't send it twice
+		result := db.Model(&schemas.DatePlan{}).
+			Where("id = ? AND reminder_sent_at IS NULL", plan.ID).
+			Update("reminder_sent_at", now)
+
+		if result.Error != nil {
+			log.Printf("Error claiming reminder for date plan %d: %v", plan.ID, result.Error)
+			continue
+		}
+
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		match, err := matches.GetMatchByID(plan.MatchID, db)
+		if err != nil || match == nil {
+			log.Printf("Error loading match %d for date reminder: %v", plan.MatchID, err)
+			continue
+		}
+
+		declined := make(map[uint]bool)
+		for _, rsvp := range plan.RSVPs {
+			if rsvp.Response == schemas.RSVPDeclined {
+				declined[rsvp.ProfileID] = true
+			}
+		}
+
+		minutesUntil := int(time.Until(plan.ProposedTime).Minutes())
+		body := fmt.Sprintf("Your plans at %s start in %d minutes", plan.VenueName, minutesUntil)
+		if minutesUntil >= 60 {
+			body = fmt.Sprintf("Your plans at %s start in about %d hours", plan.VenueName, (minutesUntil+30)/60)
+		}
+
+		data := map[string]interface{}{
+			"type":         "date_reminder",
+			"date_plan_id": plan.ID,
+			"match_id":     plan.MatchID,
+		}
+
+		for _, participantID := range matches.GetParticipantIDs(*match) {
+			if declined[participantID] {
+				continue
+			}
+
+			if err := notifications.SendNotificationV2(participantID, "Date Reminder", body, data, db); err != nil {
+				log.Printf("Error sending date reminder to user %d: %v", participantID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// StartReminderWorker blocks, sending due date reminders every ReminderInterval
+func StartReminderWorker(db *gorm.DB) {
+	ticker := time.NewTicker(ReminderInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := SendDueReminders(db); err != nil {
+			log.Printf("Error sending date reminders: %v", err)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package deck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"twoman/handlers/helpers/attributes"
+	"twoman/handlers/helpers/discovery"
+	"twoman/handlers/helpers/interests"
+	"twoman/schemas"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const (
+	// DECK_TTL is how long an untouched deck is kept before it is rebuilt from scratch
+	DECK_TTL = 6 * time.Hour
+	// DeckMaxSize is how many candidates a refill ranks and stores
+	DeckMaxSize = 50
+	// DeckRefillThreshold triggers a background refill once fewer unseen candidates remain
+	DeckRefillThreshold = 15
+	// DefaultPageSize is used when the client doesn't ask for a specific number of candidates
+	DefaultPageSize = 10
+	// MaxPageSize caps how many candidates a single request can return
+	MaxPageSize = 25
+	// refillLockTTL stops several refills for the same user running at once
+	refillLockTTL = 30 * time.Second
+)
+
+// DeckRedis is the per user deck stored in Redis. ProfileIDs before Cursor have been swiped.
+type DeckRedis struct {
+	ProfileIDs  []uint    `json:"profile_ids"`
+	Cursor      int       `json:"cursor"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+func deckKey(userID uint) string {
+	return fmt.Sprintf("user:%d:discover:deck", userID)
+}
+
+func refillLockKey(userID uint) string {
+	return fmt.Sprintf("user:%d:discover:deck:refilling", userID)
+}
+
+// GetDeck returns up to size ranked candidates starting at the user's cursor. Reading does not move the cursor;
+// swipes do through ConsumeCandidate. Candidates that became invalid since the deck was built are dropped.
+func GetDeck(viewer schemas.Profile, size int, db *gorm.DB, rdb *redis.Client) ([]schemas.Profile, error) {
+	ctx := context.Background()
+
+	deck, err := loadDeck(viewer.UserID, rdb)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the deck synchronously when there is nothing left to show
+	if deck == nil || deck.Cursor >= len(deck.ProfileIDs) {
+		log.Printf("Generating fresh discovery deck for user %d", viewer.UserID)
+		deck, err = refillDeck(viewer, deck, db, rdb)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pending := deck.ProfileIDs[deck.Cursor:]
+
+	profiles, invalid, err := loadValidCandidates(viewer, pending, deck.GeneratedAt, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(profiles) > size {
+		profiles = profiles[:size]
+	}
+
+	if len(invalid) > 0 {
+		deck.ProfileIDs = append(deck.ProfileIDs[:deck.Cursor:deck.Cursor], removeIDs(pending, invalid)...)
+	}
+
+	if err := saveDeck(viewer.UserID, deck, rdb); err != nil {
+		return nil, err
+	}
+
+	if len(deck.ProfileIDs)-deck.Cursor < DeckRefillThreshold {
+		if acquired, err := rdb.SetNX(ctx, refillLockKey(viewer.UserID), 1, refillLockTTL).Result(); err == nil && acquired {
+			go backgroundRefill(viewer, db, rdb)
+		}
+	}
+
+	return profiles, nil
+}
+
+// ConsumeCandidate marks a profile as swiped so it isn't served again. Swipes on profiles outside the
+// deck (e.g. from standouts or search) are ignored.
+func ConsumeCandidate(userID uint, profileID uint, rdb *redis.Client) error {
+	deck, err := loadDeck(userID, rdb)
+	if err != nil || deck == nil {
+		return err
+	}
+
+	for i := deck.Cursor; i < len(deck.ProfileIDs); i++ {
+		if deck.ProfileIDs[i] != profileID {
+			continue
+		}
+
+		// Move the swiped profile to the cursor and advance past it so the rest keep their rank order
+		copy(deck.ProfileIDs[deck.Cursor+1:i+1], deck.ProfileIDs[deck.Cursor:i])
+		deck.ProfileIDs[deck.Cursor] = profileID
+		deck.Cursor++
+
+		return saveDeck(userID, deck, rdb)
+	}
+
+	return nil
+}
+
+// ResetDeck throws away the cached deck, e.g. after the user changes their preferences
+func ResetDeck(userID uint, rdb *redis.Client) error {
+	return rdb.Del(context.Background(), deckKey(userID)).Err()
+}
+
+func backgroundRefill(viewer schemas.Profile, db *gorm.DB, rdb *redis.Client) {
+	defer rdb.Del(context.Background(), refillLockKey(viewer.UserID))
+
+	deck, err := loadDeck(viewer.UserID, rdb)
+	if err != nil {
+		log.Printf("Error loading discovery deck for refill of user %d: %v", viewer.UserID, err)
+		return
+	}
+
+	refilled, err := refillDeck(viewer, deck, db, rdb)
+	if err != nil {
+		log.Printf("Error refilling discovery deck for user %d: %v", viewer.UserID, err)
+		return
+	}
+
+	// Swipes that landed while ranking would be lost by overwriting the deck, so carry them over
+	latest, err := loadDeck(viewer.UserID, rdb)
+	if err == nil && latest != nil {
+		consumed := make(map[uint]bool)
+		for _, id := range latest.ProfileIDs[:latest.Cursor] {
+			consumed[id] = true
+		}
+		refilled.ProfileIDs = removeIDs(refilled.ProfileIDs, consumed)
+	}
+
+	if err := saveDeck(viewer.UserID, refilled, rdb); err != nil {
+		log.Printf("Error saving refilled discovery deck for user %d: %v", viewer.UserID, err)
+	}
+}
+
+// refillDeck drops swiped candidates and appends newly ranked ones after the ones still waiting to be shown
+func refillDeck(viewer schemas.Profile, deck *DeckRedis, db *gorm.DB, rdb *redis.Client) (*DeckRedis, error) {
+	var pending []uint
+	if deck != nil {
+		pending = deck.ProfileIDs[deck.Cursor:]
+	}
+
+	ranked, err := discovery.Discover(viewer, DeckMaxSize, db, rdb)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uint]bool, len(pending))
+	for _, id := range pending {
+		seen[id] = true
+	}
+
+	ids := append([]uint{}, pending...)
+	for _, candidate := range ranked {
+		if seen[candidate.Profile.UserID] {
+			continue
+		}
+		seen[candidate.Profile.UserID] = true
+		ids = append(ids, candidate.Profile.UserID)
+	}
+
+	return &DeckRedis{
+		ProfileIDs:  ids,
+		Cursor:      0,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+// loadValidCandidates loads the profiles for ids in order, reporting the ones that were deleted, matched or swiped
+// since the deck was built, or that no longer pass the hard discovery filters, e.g. because they were blocked,
+// went dormant or incognito, or either side changed their preferences. Passes from before the deck was built are
+// recycled profiles.
+func loadValidCandidates(viewer schemas.Profile, ids []uint, generatedAt time.Time, db *gorm.DB) ([]schemas.Profile, map[uint]bool, error) {
+	invalid := make(map[uint]bool)
+	if len(ids) == 0 {
+		return []schemas.Profile{}, invalid, nil
+	}
+
+	filters, err := discovery.GetActiveFilters(viewer.UserID, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query, err := discovery.FilterQuery(viewer, filters, db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var profiles []schemas.Profile
+	if err := query.Where("profiles.user_id IN ?", ids).Find(&profiles).Error; err != nil {
+		return nil, nil, err
+	}
+
+	// Likes show up as matches below
+	var passedIDs []uint
+	if err := db.Model(&schemas.ProfilePass{}).
+		Where("user_id = ? AND profile_id IN ? AND passed_at >= ?", viewer.UserID, ids, generatedAt).
+		Pluck("profile_id", &passedIDs).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var userMatches []schemas.Matches
+	if err := db.Where("(profile1_id = ? OR profile2_id = ? OR profile3_id = ? OR profile4_id = ?) AND (profile1_id IN ? OR profile2_id IN ? OR profile3_id IN ? OR profile4_id IN ?)",
+		viewer.UserID, viewer.UserID, viewer.UserID, viewer.UserID, ids, ids, ids, ids).
+		Find(&userMatches).Error; err != nil {
+		return nil, nil, err
+	}
+
+	for _, id := range passedIDs {
+		invalid[id] = true
+	}
+
+	for _, match := range userMatches {
+		invalid[match.Profile1ID] = true
+		invalid[match.Profile3ID] = true
+		if match.Profile2ID != nil {
+			invalid[*match.Profile2ID] = true
+		}
+		if match.Profile4ID != nil {
+			invalid[*match.Profile4ID] = true
+		}
+	}
+
+	byID := make(map[uint]schemas.Profile, len(profiles))
+	for _, profile := range profiles {
+		byID[profile.UserID] = profile
+	}
+
+	result := []schemas.Profile{}
+	for _, id := range ids {
+		profile, ok := byID[id]
+		if !ok || invalid[id] {
+			invalid[id] = true
+			continue
+		}
+
+		result = append(result, profile)
+	}
+
+	// Fill in the same details FetchCandidates does, so cards from the deck match freshly ranked ones
+	withDetails := make([]*schemas.Profile, len(result))
+	for i := range result {
+		withDetails[i] = &result[i]
+	}
+
+	if err := interests.Attach(withDetails, db); err != nil {
+		return nil, nil, err
+	}
+
+	if err := attributes.Attach(withDetails, db); err != nil {
+		return nil, nil, err
+	}
+
+	return result, invalid, nil
+}
+
+func loadDeck(userID uint, rdb *redis.Client) (*DeckRedis, error) {
+	data, err := rdb.Get(context.Background(), deckKey(userID)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get discovery deck: %v", err)
+	}
+
+	var deck DeckRedis
+	if err := json.Unmarshal([]byte(data), &deck); err != nil {
+		log.Printf("Error parsing discovery deck from Redis: %v", err)
+		return nil, nil
+	}
+
+	if deck.Cursor < 0 || deck.Cursor > len(deck.ProfileIDs) {
+		return nil, nil
+	}
+
+	return &deck, nil
+}
+
+func saveDeck(userID uint, deck *DeckRedis, rdb *redis.Client) error {
+	data, err := json.Marshal(deck)
+	if err != nil {
+		return fmt.Errorf("failed to marshal discovery deck: %v", err)
+	}
+
+	if err := rdb.Set(context.Background(), deckKey(userID), data, DECK_TTL).Err(); err != nil {
+		return fmt.Errorf("failed to cache discovery deck: %v", err)
+	}
+
+	return nil
+}
+
+func removeIDs(ids []uint, remove map[uint]bool) []uint {
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !remove[id] {
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,1037 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"twoman/handlers/helpers/attributes"
+	"twoman/handlers/helpers/completeness"
+	"twoman/handlers/helpers/interests"
+	"twoman/handlers/helpers/seen"
+	"twoman/handlers/helpers/subscription"
+	"twoman/schemas"
+	"twoman/types"
+	"twoman/utils"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// CandidatePoolSize is how many filtered candidates are pulled from the database before scoring
+const CandidatePoolSize = 200
+
+// PoolJitter is the most random noise added to a candidate's pool score, so the pool isn't always the same
+// nearest, most active profiles and others within range get a turn
+const PoolJitter = 0.5
+
+// DefaultWeights are used until an admin stores custom weights
+var DefaultWeights = schemas.DiscoveryWeights{
+	Distance:        0.30,
+	Activity:        0.25,
+	InterestOverlap: 0.15,
+	ReciprocalFit:   0.20,
+	InboundLike:     0.10,
+	FilterMatch:     0.15,
+	Completeness:    0.10,
+}
+
+// DefaultRecyclePolicy is used until an admin stores a custom recycle policy
+var DefaultRecyclePolicy = schemas.DiscoveryRecyclePolicy{
+	Enabled:                    true,
+	CooldownDays:               30,
+	ChangedContentCooldownDays: 7,
+}
+
+// DormantAfterDays is how long someone can go without opening the app before they stop being shown
+const DormantAfterDays = 30
+
+// earthRadiusKm is the radius ST_Distance_Sphere uses by default
+const earthRadiusKm = 6370.986
+
+// RecycledScoreFactor scales the score of passed profiles that come back unchanged, so fresh profiles are shown first
+const RecycledScoreFactor = 0.5
+
+const (
+	// MaxFilterValues is how many values each discovery filter list can hold
+	MaxFilterValues = 10
+	// MaxFilterValueLength is the longest single filter value
+	MaxFilterValueLength = 50
+)
+
+// Candidate is a profile that passed the hard discovery filters, along with the raw data needed to score it
+type Candidate struct {
+	schemas.Profile `gorm:"embedded"`
+	DistanceMeters  *float64
+	LikesSent       int
+	ViewsMade       int
+	PassedAt        *time.Time
+	PhotoCount      int
+	PromptCount     int
+}
+
+// Signals holds one value per ranking signal
+type Signals struct {
+	Distance        float64 `json:"distance"`
+	Activity        float64 `json:"activity"`
+	InterestOverlap float64 `json:"interest_overlap"`
+	ReciprocalFit   float64 `json:"reciprocal_fit"`
+	InboundLike     float64 `json:"inbound_like"`
+	FilterMatch     float64 `json:"filter_match"`
+	Completeness    float64 `json:"completeness"`
+}
+
+// RankedCandidate is a scored candidate. Signals are normalised to 0..1 and Contributions are the weighted values
+// that sum to Score, which lets admins see why a profile was ranked where it was.
+type RankedCandidate struct {
+	Profile         schemas.Profile `json:"profile"`
+	Score           float64         `json:"score"`
+	Signals         Signals         `json:"signals"`
+	Contributions   Signals         `json:"contributions"`
+	DistanceKm      *float64        `json:"distance_km"`
+	LastActiveAt    *time.Time      `json:"last_active_at"`
+	SharedInterests []string        `json:"shared_interests"`
+	Recycled        bool            `json:"recycled"`
+}
+
+// GetWeights returns the stored discovery weights, or the defaults if none are stored
+func GetWeights(db *gorm.DB) (schemas.DiscoveryWeights, error) {
+	var weights schemas.DiscoveryWeights
+	if err := db.First(&weights, 1).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return DefaultWeights, nil
+		}
+		return schemas.DiscoveryWeights{}, err
+	}
+
+	return weights, nil
+}
+
+// UpdateWeights stores new discovery weights
+func UpdateWeights(weights schemas.DiscoveryWeights, db *gorm.DB) (schemas.DiscoveryWeights, error) {
+	if weights.Distance < 0 || weights.Activity < 0 || weights.InterestOverlap < 0 || weights.ReciprocalFit < 0 || weights.InboundLike < 0 || weights.FilterMatch < 0 || weights.Completeness < 0 {
+		return schemas.DiscoveryWeights{}, errors.New("weights cannot be negative")
+	}
+
+	if weights.Distance+weights.Activity+weights.InterestOverlap+weights.ReciprocalFit+weights.InboundLike+weights.FilterMatch+weights.Completeness == 0 {
+		return schemas.DiscoveryWeights{}, errors.New("at least one weight must be greater than 0")
+	}
+
+	weights.ID = 1
+	if err := db.Save(&weights).Error; err != nil {
+		return schemas.DiscoveryWeights{}, err
+	}
+
+	return weights, nil
+}
+
+// GetRecyclePolicy returns the stored recycle policy, or the default if none is stored
+func GetRecyclePolicy(db *gorm.DB) (schemas.DiscoveryRecyclePolicy, error) {
+	var policy schemas.DiscoveryRecyclePolicy
+	if err := db.First(&policy, 1).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return DefaultRecyclePolicy, nil
+		}
+		return schemas.DiscoveryRecyclePolicy{}, err
+	}
+
+	return policy, nil
+}
+
+// UpdateRecyclePolicy stores a new recycle policy
+func UpdateRecyclePolicy(policy schemas.DiscoveryRecyclePolicy, db *gorm.DB) (schemas.DiscoveryRecyclePolicy, error) {
+	if policy.CooldownDays < 1 || policy.ChangedContentCooldownDays < 1 {
+		return schemas.DiscoveryRecyclePolicy{}, errors.New("cooldowns must be at least 1 day")
+	}
+
+	if policy.ChangedContentCooldownDays > policy.CooldownDays {
+		return schemas.DiscoveryRecyclePolicy{}, errors.New("changed content cooldown cannot be longer than the cooldown")
+	}
+
+	policy.ID = 1
+	if err := db.Save(&policy).Error; err != nil {
+		return schemas.DiscoveryRecyclePolicy{}, err
+	}
+
+	return policy, nil
+}
+
+// Discover runs the full pipeline for a viewer: hard filters, candidate pool, scoring and ranking.
+// At most limit candidates are returned, best first.
+func Discover(viewer schemas.Profile, limit int, db *gorm.DB, rdb *redis.Client) ([]RankedCandidate, error) {
+	weights, err := GetWeights(db)
+	if err != nil {
+		return nil, fmt.Errorf("error loading discovery weights: %w", err)
+	}
+
+	filters, err := GetActiveFilters(viewer.UserID, db)
+	if err != nil {
+		return nil, fmt.Errorf("error loading discovery filters: %w", err)
+	}
+
+	candidates, err := FetchCandidates(viewer, filters, CandidatePoolSize, db, rdb)
+	if err != nil {
+		return nil, err
+	}
+
+	if viewer.InterestTags == nil {
+		if viewer.InterestTags, err = interests.List(viewer.UserID, db); err != nil {
+			return nil, err
+		}
+	}
+
+	ranked := Rank(viewer, candidates, weights, filters, time.Now())
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	return ranked, nil
+}
+
+// FetchCandidates applies the hard discovery filters, including any required pro filters, and returns up to
+// poolSize candidates. The pool favours near and recently active profiles with some randomness, and is ranked
+// properly by Rank. filters may be nil.
+func FetchCandidates(viewer schemas.Profile, filters *schemas.DiscoveryFilters, poolSize int, db *gorm.DB, rdb *redis.Client) ([]Candidate, error) {
+	unseenSQL, unseenArgs, err := unseenCondition(viewer.UserID, time.Now(), db, rdb)
+	if err != nil {
+		return nil, err
+	}
+
+	return fetchCandidates(viewer, filters, poolSize, unseenSQL, unseenArgs, db)
+}
+
+// FilterQuery returns a query on profiles that applies the hard discovery filters for viewer: blocks, dormancy,
+// visibility, age, gender and distance preferences on both sides, and any required pro filters. It doesn't drop
+// profiles the viewer has already swiped on. filters may be nil.
+func FilterQuery(viewer schemas.Profile, filters *schemas.DiscoveryFilters, db *gorm.DB) (*gorm.DB, error) {
+	viewerLocation := viewer.EffectiveLocation()
+	hasLocation := viewerLocation.Point != nil
+	candidateLocation := EffectiveLocationSQL("profiles")
+
+	if viewer.PreferredDistanceMax > 0 && !hasLocation {
+		return nil, fmt.Errorf("invalid user location point")
+	}
+
+	// Subquery to get the IDs of profiles that are blocked or have blocked the user
+	blockedSubquery := db.Table("blocks").
+		Select("CASE WHEN profile_id = ? THEN blocked_profile_id ELSE profile_id END", viewer.UserID).
+		Where("profile_id = ? OR blocked_profile_id = ?", viewer.UserID, viewer.UserID)
+
+	query := db.Table("profiles").
+		Where("profiles.user_id != ?", viewer.UserID).
+		Where("profiles.user_id NOT IN (?)", blockedSubquery)
+
+	activeSQL, activeArgs := ActiveSQL("profiles", time.Now())
+	query = query.Where(activeSQL, activeArgs...)
+
+	visibleSQL, visibleArgs := VisibleSQL(viewer.UserID, "profiles")
+	query = query.Where(visibleSQL, visibleArgs...)
+
+	if viewer.PreferredGender != "" {
+		query = query.Where("profiles.gender = ?", viewer.PreferredGender)
+	}
+
+	// Nobody under the minimum age is shown, whatever the viewer's preferences
+	candidateAge := utils.AgeSQL("profiles.date_of_birth")
+	query = query.Where(candidateAge+" >= ?", utils.MinimumAge)
+
+	if viewer.PreferredAgeMin > 0 && viewer.PreferredAgeMax > 0 {
+		query = query.Where(candidateAge+" >= ? AND "+candidateAge+" <= ?", viewer.PreferredAgeMin, viewer.PreferredAgeMax)
+	}
+
+	if viewer.PreferredDistanceMax > 0 {
+		nearbySQL, nearbyArgs := NearbySQL(viewerLocation, float64(viewer.PreferredDistanceMax), "profiles")
+		query = query.
+			Where(nearbySQL, nearbyArgs...).
+			Where("ST_Distance_Sphere("+candidateLocation+", ST_GeomFromText(?)) <= ?", viewerLocation.String(), viewer.PreferredDistanceMax*1000)
+	}
+
+	reciprocalSQL, reciprocalArgs := ReciprocalPreferenceSQL(viewer, "profiles")
+	query = query.Where(reciprocalSQL, reciprocalArgs...)
+
+	if filters != nil {
+		filterSQL, filterArgs := RequiredFilterSQL(*filters, "profiles")
+		query = query.Where(filterSQL, filterArgs...)
+	}
+
+	return query, nil
+}
+
+func fetchCandidates(viewer schemas.Profile, filters *schemas.DiscoveryFilters, poolSize int, unseenSQL string, unseenArgs []interface{}, db *gorm.DB) ([]Candidate, error) {
+	viewerLocation := viewer.EffectiveLocation()
+	hasLocation := viewerLocation.Point != nil
+	candidateLocation := EffectiveLocationSQL("profiles")
+
+	query, err := FilterQuery(viewer, filters, db)
+	if err != nil {
+		return nil, err
+	}
+	query = query.Where(unseenSQL, unseenArgs...)
+
+	// Only cheap columns are used to pick the pool. The counts Rank needs are loaded for the pool afterwards.
+	activityScore := "1 / (1 + GREATEST(TIMESTAMPDIFF(HOUR, profiles.last_active_at, NOW()), 0) / 72)"
+
+	if hasLocation {
+		scaleMeters := 25000.0
+		if viewer.PreferredDistanceMax > 0 {
+			scaleMeters = float64(viewer.PreferredDistanceMax) * 1000
+		}
+
+		distanceSQL := "ST_Distance_Sphere(" + candidateLocation + ", ST_GeomFromText(?))"
+		query = query.Select("profiles.*, "+distanceSQL+" AS distance_meters, "+
+			"1 / (1 + "+distanceSQL+" / ?) + "+activityScore+" + RAND() * ? AS pool_score",
+			viewerLocation.String(), viewerLocation.String(), scaleMeters, PoolJitter)
+	} else {
+		query = query.Select("profiles.*, NULL AS distance_meters, "+activityScore+" + RAND() * ? AS pool_score", PoolJitter)
+	}
+
+	var candidates []Candidate
+	if err := query.Order("pool_score DESC").Limit(poolSize).Scan(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("error executing query: %w", err)
+	}
+
+	// Scan skips the AfterFind hook
+	profiles := make([]*schemas.Profile, 0, len(candidates))
+	for i := range candidates {
+		candidates[i].Profile.SetTravelingTo()
+		candidates[i].Profile.SetActivityBadge()
+		profiles = append(profiles, &candidates[i].Profile)
+	}
+
+	if err := interests.Attach(profiles, db); err != nil {
+		return nil, err
+	}
+
+	if err := attributes.Attach(profiles, db); err != nil {
+		return nil, err
+	}
+
+	if err := attachSwipes(viewer.UserID, candidates, db); err != nil {
+		return nil, err
+	}
+
+	if err := attachCounts(candidates, db); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// attachCounts fills in each candidate's photo and prompt counts
+func attachCounts(candidates []Candidate, db *gorm.DB) error {
+	ids := make([]uint, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.UserID
+	}
+
+	counts, err := completeness.CountsFor(ids, db)
+	if err != nil {
+		return err
+	}
+
+	for i := range candidates {
+		candidates[i].PhotoCount = counts[candidates[i].UserID].Photos
+		candidates[i].PromptCount = counts[candidates[i].UserID].Prompts
+	}
+
+	return nil
+}
+
+// attachSwipes fills in each candidate's swipe counts and when the viewer last passed on them, with one query each
+func attachSwipes(viewerID uint, candidates []Candidate, db *gorm.DB) error {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(candidates))
+	for i, candidate := range candidates {
+		ids[i] = candidate.UserID
+	}
+
+	var stats []schemas.SwipeStats
+	if err := db.Where("user_id IN ?", ids).Find(&stats).Error; err != nil {
+		return fmt.Errorf("error loading swipe stats: %w", err)
+	}
+
+	var passes []schemas.ProfilePass
+	if err := db.Where("user_id = ? AND profile_id IN ?", viewerID, ids).Find(&passes).Error; err != nil {
+		return fmt.Errorf("error loading profile passes: %w", err)
+	}
+
+	statsByID := make(map[uint]schemas.SwipeStats, len(stats))
+	for _, stat := range stats {
+		statsByID[stat.UserID] = stat
+	}
+
+	passedAt := make(map[uint]time.Time, len(passes))
+	for _, pass := range passes {
+		passedAt[pass.ProfileID] = pass.PassedAt
+	}
+
+	for i := range candidates {
+		stat := statsByID[candidates[i].UserID]
+		candidates[i].LikesSent = stat.Likes
+		candidates[i].ViewsMade = stat.Likes + stat.Passes
+
+		if at, ok := passedAt[candidates[i].UserID]; ok {
+			candidates[i].PassedAt = &at
+		}
+	}
+
+	return nil
+}
+
+// unseenCondition keeps candidates the viewer hasn't swiped on, plus passed candidates whose cooldown has ended
+func unseenCondition(viewerID uint, now time.Time, db *gorm.DB, rdb *redis.Client) (string, []interface{}, error) {
+	bitmap, err := seen.Bitmap(viewerID, db, rdb)
+	if err != nil {
+		return "", nil, err
+	}
+
+	policy, err := GetRecyclePolicy(db)
+	if err != nil {
+		return "", nil, fmt.Errorf("error loading recycle policy: %w", err)
+	}
+
+	recyclable, err := recyclablePasses(viewerID, policy, now, db)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, id := range recyclable {
+		seen.Set(bitmap, id, false)
+	}
+
+	condition, args := seen.UnseenSQL(bitmap, "profiles")
+	return condition, args, nil
+}
+
+// recyclablePasses returns the profiles the viewer passed on whose cooldown has ended
+func recyclablePasses(viewerID uint, policy schemas.DiscoveryRecyclePolicy, now time.Time, db *gorm.DB) ([]uint, error) {
+	if !policy.Enabled {
+		return nil, nil
+	}
+
+	var ids []uint
+	if err := db.Table("profile_passes pp").
+		Select("pp.profile_id").
+		Joins("JOIN profiles p ON p.user_id = pp.profile_id").
+		Where("pp.user_id = ?", viewerID).
+		Where("(pp.passed_at < ? OR (pp.passed_at < ? AND p.content_updated_at > pp.passed_at))",
+			now.AddDate(0, 0, -policy.CooldownDays), now.AddDate(0, 0, -policy.ChangedContentCooldownDays)).
+		Scan(&ids).Error; err != nil {
+		return nil, fmt.Errorf("error loading recyclable passes: %w", err)
+	}
+
+	return ids, nil
+}
+
+// ActiveSQL returns a condition that drops dormant profiles, i.e. rows of profiles under alias that haven't been
+// active in the last DormantAfterDays days
+func ActiveSQL(alias string, now time.Time) (string, []interface{}) {
+	return alias + ".last_active_at >= ?", []interface{}{now.AddDate(0, 0, -DormantAfterDays)}
+}
+
+// VisibleSQL returns a condition that keeps rows of profiles under alias that the viewer is allowed to discover:
+// visible profiles, and incognito profiles that have already liked the viewer. Paused profiles are never kept.
+func VisibleSQL(viewerID uint, alias string) (string, []interface{}) {
+	condition := fmt.Sprintf("(%[1]s.visibility = ? OR (%[1]s.visibility = ? AND EXISTS (SELECT 1 FROM matches liked "+
+		"WHERE (liked.profile1_id = %[1]s.user_id OR liked.profile2_id = %[1]s.user_id) AND (liked.profile3_id = ? OR liked.profile4_id = ?))))", alias)
+
+	return condition, []interface{}{schemas.VisibilityVisible, schemas.VisibilityIncognito, viewerID, viewerID}
+}
+
+// EffectiveLocationSQL is the SQL equivalent of Profile.EffectiveLocation for rows of profiles under alias
+func EffectiveLocationSQL(alias string) string {
+	return fmt.Sprintf("(CASE WHEN %[1]s.travel_expires_at > NOW() AND %[1]s.travel_location_point IS NOT NULL THEN %[1]s.travel_location_point ELSE %[1]s.location_point END)", alias)
+}
+
+// BoundingBox returns a WKT polygon, in the same lon/lat order as Point, that contains every location within
+// distanceKm of location. Boxes reaching a pole or crossing the antimeridian span every longitude.
+func BoundingBox(location schemas.Point, distanceKm float64) string {
+	lon, lat := location.X(), location.Y()
+	angular := distanceKm / earthRadiusKm
+
+	latDelta := angular * 180 / math.Pi
+	minLat, maxLat := lat-latDelta, lat+latDelta
+	minLon, maxLon := -180.0, 180.0
+
+	if minLat > -90 && maxLat < 90 {
+		// Exact longitude extent of a circle on a sphere, which is a little wider than latDelta / cos(lat)
+		ratio := math.Sin(angular) / math.Cos(lat*math.Pi/180)
+		if ratio < 1 {
+			lonDelta := math.Asin(ratio) * 180 / math.Pi
+			if lon-lonDelta >= -180 && lon+lonDelta <= 180 {
+				minLon, maxLon = lon-lonDelta, lon+lonDelta
+			}
+		}
+	}
+
+	minLat, maxLat = math.Max(minLat, -90), math.Min(maxLat, 90)
+
+	return fmt.Sprintf("POLYGON((%[1]f %[2]f, %[3]f %[2]f, %[3]f %[4]f, %[1]f %[4]f, %[1]f %[2]f))", minLon, minLat, maxLon, maxLat)
+}
+
+// NearbySQL returns a condition that keeps rows of profiles under alias whose effective location might be within
+// distanceKm of location. It only compares bounding boxes so the spatial index on location_point can be used,
+// and callers still need to check the exact distance. Travel locations aren't indexed, but few profiles travel
+// at once, so they are found through the travel_expires_at index instead.
+func NearbySQL(location schemas.Point, distanceKm float64, alias string) (string, []interface{}) {
+	box := BoundingBox(location, distanceKm)
+
+	return alias + ".user_id IN (SELECT nearby.user_id FROM (" +
+		"SELECT user_id FROM profiles WHERE MBRContains(ST_GeomFromText(?), location_point) " +
+		"UNION SELECT user_id FROM profiles WHERE travel_expires_at > NOW() AND MBRContains(ST_GeomFromText(?), travel_location_point)" +
+		") AS nearby)", []interface{}{box, box}
+}
+
+// ReciprocalPreferenceSQL returns a condition that only keeps candidates (rows of profiles under alias) whose own
+// gender, age and distance preferences accept the viewer. Unset preferences accept everyone.
+func ReciprocalPreferenceSQL(viewer schemas.Profile, alias string) (string, []interface{}) {
+	conditions := []string{
+		fmt.Sprintf("(%[1]s.preferred_gender IS NULL OR %[1]s.preferred_gender = '' OR %[1]s.preferred_gender = ?)", alias),
+		fmt.Sprintf("(%[1]s.preferred_age_min <= 0 OR %[1]s.preferred_age_max <= 0 OR ? BETWEEN %[1]s.preferred_age_min AND %[1]s.preferred_age_max)", alias),
+	}
+	args := []interface{}{viewer.Gender, utils.Age(viewer.DateOfBirth, time.Now())}
+
+	if viewerLocation := viewer.EffectiveLocation(); viewerLocation.Point != nil {
+		conditions = append(conditions,
+			fmt.Sprintf("(%s.preferred_distance_max <= 0 OR ST_Distance_Sphere(%s, ST_GeomFromText(?)) <= %s.preferred_distance_max * 1000)", alias, EffectiveLocationSQL(alias), alias))
+		args = append(args, viewerLocation.String())
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// Rank scores every candidate against the viewer and sorts them best first
+func Rank(viewer schemas.Profile, candidates []Candidate, weights schemas.DiscoveryWeights, filters *schemas.DiscoveryFilters, now time.Time) []RankedCandidate {
+	viewerAge := utils.Age(viewer.DateOfBirth, now)
+
+	ranked := make([]RankedCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		var distanceKm *float64
+		if candidate.DistanceMeters != nil {
+			km := *candidate.DistanceMeters / 1000
+			distanceKm = &km
+		}
+
+		shared := sharedInterests(viewer.InterestTags, candidate.InterestTags)
+
+		signals := Signals{
+			Distance:        distanceSignal(distanceKm, viewer.PreferredDistanceMax),
+			Activity:        activitySignal(candidate.Profile.LastActiveAt, now),
+			InterestOverlap: interestOverlapSignal(len(viewer.InterestTags), len(candidate.InterestTags), len(shared)),
+			ReciprocalFit:   reciprocalFitSignal(viewerAge, candidate.Profile, distanceKm),
+			InboundLike:     inboundLikeSignal(candidate.LikesSent, candidate.ViewsMade),
+			FilterMatch:     filterMatchSignal(filters, candidate.Profile),
+			Completeness:    completenessSignal(candidate),
+		}
+
+		contributions := Signals{
+			Distance:        signals.Distance * weights.Distance,
+			Activity:        signals.Activity * weights.Activity,
+			InterestOverlap: signals.InterestOverlap * weights.InterestOverlap,
+			ReciprocalFit:   signals.ReciprocalFit * weights.ReciprocalFit,
+			InboundLike:     signals.InboundLike * weights.InboundLike,
+			FilterMatch:     signals.FilterMatch * weights.FilterMatch,
+			Completeness:    signals.Completeness * weights.Completeness,
+		}
+
+		score := contributions.Distance + contributions.Activity + contributions.InterestOverlap + contributions.ReciprocalFit + contributions.InboundLike + contributions.FilterMatch + contributions.Completeness
+
+		// Passed profiles only come back after the cooldown. Ones that changed their photos or bio since
+		// are worth another look, the rest go behind fresh profiles.
+		recycled := candidate.PassedAt != nil
+		if recycled && (candidate.ContentUpdatedAt == nil || candidate.ContentUpdatedAt.Before(*candidate.PassedAt)) {
+			score *= RecycledScoreFactor
+		}
+
+		ranked = append(ranked, RankedCandidate{
+			Profile:         candidate.Profile,
+			Score:           score,
+			Signals:         signals,
+			Contributions:   contributions,
+			DistanceKm:      distanceKm,
+			LastActiveAt:    candidate.Profile.LastActiveAt,
+			SharedInterests: shared,
+			Recycled:        recycled,
+		})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Profile.UserID < ranked[j].Profile.UserID
+	})
+
+	return ranked
+}
+
+// distanceSignal decays smoothly with distance, scaled by the viewer's preferred distance (or 25km if unset)
+func distanceSignal(distanceKm *float64, preferredDistanceMax int) float64 {
+	if distanceKm == nil {
+		return 0
+	}
+
+	scaleKm := 25.0
+	if preferredDistanceMax > 0 {
+		scaleKm = float64(preferredDistanceMax)
+	}
+
+	return 1 / (1 + *distanceKm/scaleKm)
+}
+
+// activitySignal is 1 for someone active right now and halves after three days
+func activitySignal(lastActiveAt *time.Time, now time.Time) float64 {
+	if lastActiveAt == nil {
+		return 0
+	}
+
+	days := math.Max(now.Sub(*lastActiveAt).Hours()/24, 0)
+	return 1 / (1 + days/3)
+}
+
+// interestOverlapSignal is the Jaccard similarity of the two interest sets
+func interestOverlapSignal(viewerCount, candidateCount, sharedCount int) float64 {
+	union := viewerCount + candidateCount - sharedCount
+	if union == 0 {
+		return 0
+	}
+
+	return float64(sharedCount) / float64(union)
+}
+
+// reciprocalFitSignal measures how comfortably the viewer sits inside the candidate's preferences. Candidates whose
+// preferences exclude the viewer are already filtered out, so this rewards being near the middle of their age range
+// and well within their distance rather than right at the edge.
+func reciprocalFitSignal(viewerAge int, candidate schemas.Profile, distanceKm *float64) float64 {
+	checks, total := 0, 0.0
+
+	if candidate.PreferredAgeMin > 0 && candidate.PreferredAgeMax > 0 {
+		checks++
+		middle := float64(candidate.PreferredAgeMin+candidate.PreferredAgeMax) / 2
+		halfRange := float64(candidate.PreferredAgeMax-candidate.PreferredAgeMin)/2 + 1
+		total += math.Max(0, 1-math.Abs(float64(viewerAge)-middle)/halfRange)
+	}
+
+	if candidate.PreferredDistanceMax > 0 && distanceKm != nil {
+		checks++
+		total += math.Max(0, 1-*distanceKm/float64(candidate.PreferredDistanceMax))
+	}
+
+	if checks == 0 {
+		return 1
+	}
+
+	return total / float64(checks)
+}
+
+// inboundLikeSignal estimates how likely the candidate is to like someone they are shown,
+// from their like rate smoothed towards 25% so new users aren't scored at the extremes
+func inboundLikeSignal(likesSent, viewsMade int) float64 {
+	return (float64(likesSent) + 1) / (float64(viewsMade) + 4)
+}
+
+// filterMatchSignal is the fraction of the viewer's nice-to-have filters that the candidate matches
+func filterMatchSignal(filters *schemas.DiscoveryFilters, candidate schemas.Profile) float64 {
+	if filters == nil {
+		return 0
+	}
+
+	checks, matched := 0, 0
+
+	if values := splitFilterValues(filters.Education); len(values) > 0 && !filters.EducationRequired {
+		checks++
+		if matchesEducation(values, candidate.Education) {
+			matched++
+		}
+	}
+
+	if values := splitFilterValues(filters.Occupation); len(values) > 0 && !filters.OccupationRequired {
+		checks++
+		if matchesOccupation(values, candidate.Occupation) {
+			matched++
+		}
+	}
+
+	if values := splitFilterValues(filters.Interests); len(values) > 0 && !filters.InterestsRequired {
+		checks++
+		if matchesInterests(values, candidate.InterestTags) {
+			matched++
+		}
+	}
+
+	for _, filter := range filters.Attributes {
+		if filter.Required || !attributes.Usable(filter) {
+			continue
+		}
+		checks++
+		if attributes.Matches(filter, candidate.Attributes) {
+			matched++
+		}
+	}
+
+	if checks == 0 {
+		return 0
+	}
+
+	return float64(matched) / float64(checks)
+}
+
+// completenessSignal is the candidate's profile completeness score scaled to 0..1, so fuller profiles rank higher
+func completenessSignal(candidate Candidate) float64 {
+	result := completeness.Compute(candidate.Profile, completeness.Counts{
+		Photos:    candidate.PhotoCount,
+		Interests: len(candidate.InterestTags),
+		Prompts:   candidate.PromptCount,
+	})
+	return float64(result.Score) / 100
+}
+
+func matchesEducation(values []string, education string) bool {
+	education = strings.ToLower(strings.TrimSpace(education))
+	for _, value := range values {
+		if value == education {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesOccupation(values []string, occupation string) bool {
+	occupation = strings.ToLower(occupation)
+	for _, value := range values {
+		if strings.Contains(occupation, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesInterests(values []string, candidateInterests []schemas.Interest) bool {
+	for _, interest := range candidateInterests {
+		for _, value := range values {
+			if value == interest.Slug {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sharedInterests returns the names of the candidate's interests that the viewer also has
+func sharedInterests(viewerInterests, candidateInterests []schemas.Interest) []string {
+	viewerHas := make(map[uint]bool, len(viewerInterests))
+	for _, interest := range viewerInterests {
+		viewerHas[interest.ID] = true
+	}
+
+	shared := []string{}
+	for _, interest := range candidateInterests {
+		if viewerHas[interest.ID] {
+			shared = append(shared, interest.Name)
+		}
+	}
+
+	return shared
+}
+
+// DuoCandidate is a pair of friends that can be liked together. Target is the higher ranked of the two.
+type DuoCandidate struct {
+	Target schemas.Profile `json:"target"`
+	Friend schemas.Profile `json:"friend"`
+	Score  float64         `json:"score"`
+}
+
+// DiscoverDuoPairs returns pairs of accepted friends where both people pass the viewer's discovery filters,
+// ranked by the average score of the two. At most limit pairs are returned and each profile appears in one pair.
+func DiscoverDuoPairs(viewer schemas.Profile, limit int, db *gorm.DB, rdb *redis.Client) ([]DuoCandidate, error) {
+	weights, err := GetWeights(db)
+	if err != nil {
+		return nil, fmt.Errorf("error loading discovery weights: %w", err)
+	}
+
+	filters, err := GetActiveFilters(viewer.UserID, db)
+	if err != nil {
+		return nil, fmt.Errorf("error loading discovery filters: %w", err)
+	}
+
+	candidates, err := FetchCandidates(viewer, filters, CandidatePoolSize, db, rdb)
+	if err != nil {
+		return nil, err
+	}
+
+	if viewer.InterestTags == nil {
+		if viewer.InterestTags, err = interests.List(viewer.UserID, db); err != nil {
+			return nil, err
+		}
+	}
+
+	ranked := Rank(viewer, candidates, weights, filters, time.Now())
+	if len(ranked) < 2 {
+		return []DuoCandidate{}, nil
+	}
+
+	byID := make(map[uint]RankedCandidate, len(ranked))
+	ids := make([]uint, 0, len(ranked))
+	for _, candidate := range ranked {
+		byID[candidate.Profile.UserID] = candidate
+		ids = append(ids, candidate.Profile.UserID)
+	}
+
+	var friendships []schemas.Friendship
+	if err := db.Where("accepted = ? AND profile_id IN ? AND friend_id IN ?", true, ids, ids).Find(&friendships).Error; err != nil {
+		return nil, fmt.Errorf("error loading friend pairs: %w", err)
+	}
+
+	// Pairs the viewer already sent a duo like to, in either order
+	var existingMatches []schemas.Matches
+	if err := db.Where("is_duo = ? AND (profile1_id = ? OR profile2_id = ?) AND profile3_id IN ?", true, viewer.UserID, viewer.UserID, ids).
+		Find(&existingMatches).Error; err != nil {
+		return nil, fmt.Errorf("error loading existing duo matches: %w", err)
+	}
+
+	alreadyLiked := make(map[[2]uint]bool, len(existingMatches))
+	for _, match := range existingMatches {
+		if match.Profile4ID != nil {
+			alreadyLiked[pairKey(match.Profile3ID, *match.Profile4ID)] = true
+		}
+	}
+
+	// Pairs that sent the viewer a duo like. VisibleSQL lets an incognito profile through when it liked the viewer
+	// alongside any friend, so it's only paired with the friend it actually liked the viewer with.
+	var incomingMatches []schemas.Matches
+	if err := db.Where("is_duo = ? AND profile1_id IN ? AND profile2_id IN ? AND (profile3_id = ? OR profile4_id = ?)", true, ids, ids, viewer.UserID, viewer.UserID).
+		Find(&incomingMatches).Error; err != nil {
+		return nil, fmt.Errorf("error loading incoming duo matches: %w", err)
+	}
+
+	likedViewer := make(map[[2]uint]bool, len(incomingMatches))
+	for _, match := range incomingMatches {
+		if match.Profile2ID != nil {
+			likedViewer[pairKey(match.Profile1ID, *match.Profile2ID)] = true
+		}
+	}
+
+	pairs := make([]DuoCandidate, 0, len(friendships))
+	for _, friendship := range friendships {
+		key := pairKey(friendship.ProfileID, friendship.FriendID)
+		if alreadyLiked[key] {
+			continue
+		}
+
+		first, second := byID[friendship.ProfileID], byID[friendship.FriendID]
+		incognito := first.Profile.Visibility == schemas.VisibilityIncognito || second.Profile.Visibility == schemas.VisibilityIncognito
+		if incognito && !likedViewer[key] {
+			continue
+		}
+
+		if second.Score > first.Score {
+			first, second = second, first
+		}
+
+		pairs = append(pairs, DuoCandidate{
+			Target: first.Profile,
+			Friend: second.Profile,
+			Score:  (first.Score + second.Score) / 2,
+		})
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Score > pairs[j].Score
+	})
+
+	used := make(map[uint]bool)
+	result := []DuoCandidate{}
+	for _, pair := range pairs {
+		if used[pair.Target.UserID] || used[pair.Friend.UserID] {
+			continue
+		}
+
+		used[pair.Target.UserID] = true
+		used[pair.Friend.UserID] = true
+		result = append(result, pair)
+
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+
+	return result, nil
+}
+
+func pairKey(a, b uint) [2]uint {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]uint{a, b}
+}
+
+// GetFilters returns the user's stored discovery filters, or empty filters if none are stored
+func GetFilters(profileID uint, db *gorm.DB) (*schemas.DiscoveryFilters, error) {
+	var filters schemas.DiscoveryFilters
+	if err := preloadAttributeFilters(db).Where("profile_id = ?", profileID).First(&filters).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &schemas.DiscoveryFilters{ProfileID: profileID, Attributes: []schemas.DiscoveryAttributeFilter{}}, nil
+		}
+		return nil, err
+	}
+
+	setAttributeFilterKeys(&filters)
+	return &filters, nil
+}
+
+// GetActiveFilters returns the filters that should be applied for a user. Filters stay stored when a
+// subscription lapses but only apply while the user is pro, so nil is returned otherwise.
+func GetActiveFilters(profileID uint, db *gorm.DB) (*schemas.DiscoveryFilters, error) {
+	isPro, err := subscription.IsUserPro(profileID, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isPro {
+		return nil, nil
+	}
+
+	var filters schemas.DiscoveryFilters
+	if err := preloadAttributeFilters(db).Where("profile_id = ?", profileID).First(&filters).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	setAttributeFilterKeys(&filters)
+	return &filters, nil
+}
+
+func preloadAttributeFilters(db *gorm.DB) *gorm.DB {
+	return db.Preload("Attributes").Preload("Attributes.Attribute")
+}
+
+// setAttributeFilterKeys fills in the key of each attribute filter, which clients send filters by
+func setAttributeFilterKeys(filters *schemas.DiscoveryFilters) {
+	for i := range filters.Attributes {
+		filters.Attributes[i].Key = filters.Attributes[i].Attribute.Key
+	}
+}
+
+// UpdateFilters normalises and stores a user's discovery filters
+func UpdateFilters(profileID uint, request types.UpdateDiscoveryFiltersRequest, db *gorm.DB) (*schemas.DiscoveryFilters, error) {
+	filters := schemas.DiscoveryFilters{
+		ProfileID:          profileID,
+		EducationRequired:  request.EducationRequired,
+		OccupationRequired: request.OccupationRequired,
+		InterestsRequired:  request.InterestsRequired,
+		VerifiedOnly:       request.VerifiedOnly,
+	}
+
+	for _, field := range []struct {
+		name  string
+		value string
+		dest  *string
+	}{
+		{"education", request.Education, &filters.Education},
+		{"occupation", request.Occupation, &filters.Occupation},
+		{"interests", request.Interests, &filters.Interests},
+	} {
+		values := splitFilterValues(field.value)
+
+		if len(values) > MaxFilterValues {
+			return nil, fmt.Errorf("%s can have at most %d values", field.name, MaxFilterValues)
+		}
+
+		for _, value := range values {
+			if len(value) > MaxFilterValueLength {
+				return nil, fmt.Errorf("%s values must be less than %d characters", field.name, MaxFilterValueLength)
+			}
+		}
+
+		*field.dest = strings.Join(values, ",")
+	}
+
+	attributeFilters, err := attributes.Filters(profileID, request.Attributes, db)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Attributes").Save(&filters).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("profile_id = ?", profileID).Delete(&schemas.DiscoveryAttributeFilter{}).Error; err != nil {
+			return err
+		}
+
+		if len(attributeFilters) == 0 {
+			return nil
+		}
+
+		return tx.Omit("Attribute").Create(&attributeFilters).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetFilters(profileID, db)
+}
+
+// RequiredFilterSQL returns a condition that only keeps candidates (rows of profiles under alias) matching
+// every required filter. It is always a valid condition, even when nothing is required.
+func RequiredFilterSQL(filters schemas.DiscoveryFilters, alias string) (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+
+	if values := splitFilterValues(filters.Education); len(values) > 0 && filters.EducationRequired {
+		conditions = append(conditions, fmt.Sprintf("LOWER(TRIM(%s.education)) IN ?", alias))
+		args = append(args, values)
+	}
+
+	if values := splitFilterValues(filters.Occupation); len(values) > 0 && filters.OccupationRequired {
+		var likes []string
+		for _, value := range values {
+			likes = append(likes, fmt.Sprintf("LOWER(%s.occupation) LIKE ?", alias))
+			args = append(args, "%"+escapeLike(value)+"%")
+		}
+		conditions = append(conditions, "("+strings.Join(likes, " OR ")+")")
+	}
+
+	if values := splitFilterValues(filters.Interests); len(values) > 0 && filters.InterestsRequired {
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM profile_interests pi JOIN interests i ON i.id = pi.interest_id "+
+			"WHERE pi.profile_id = %s.user_id AND i.slug IN ?)", alias))
+		args = append(args, values)
+	}
+
+	if filters.VerifiedOnly {
+		conditions = append(conditions, fmt.Sprintf("%s.verified = TRUE", alias))
+	}
+
+	for _, filter := range filters.Attributes {
+		if !filter.Required || !attributes.Usable(filter) {
+			continue
+		}
+		filterSQL, filterArgs := attributes.FilterSQL(filter, alias)
+		conditions = append(conditions, filterSQL)
+		args = append(args, filterArgs...)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// splitFilterValues splits a comma separated filter into normalised, de-duplicated values, which for interests are
+// their slugs
+func splitFilterValues(value string) []string {
+	values := interests.Split(value)
+	for i := range values {
+		values[i] = interests.Normalize(values[i])
+	}
+	return values
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -0,0 +1,145 @@
+package discovery_test
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"twoman/handlers/helpers/database"
+	"twoman/handlers/helpers/discovery"
+	"twoman/schemas"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// benchLat and benchLon are where benchmark profiles are seeded and searched from
+const (
+	benchLat = 34.0549
+	benchLon = -118.2426
+)
+
+// These benchmarks run against a development database. They are skipped unless MARIADB_DSN is set, e.g.
+//
+//	MARIADB_DSN="user:pass@tcp(localhost:3306)/twoman?parseTime=true" REDIS_URL=redis://localhost:6379 \
+//		BENCH_USER=1 go test ./handlers/helpers/discovery -run '^$' -bench .
+
+func benchDB(b *testing.B) *gorm.DB {
+	b.Helper()
+
+	dsn := os.Getenv("MARIADB_DSN")
+	if dsn == "" {
+		b.Skip("MARIADB_DSN not set")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		b.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	return db
+}
+
+func benchRedis(b *testing.B) *redis.Client {
+	b.Helper()
+
+	redisUrl := os.Getenv("REDIS_URL")
+	if redisUrl == "" {
+		b.Skip("REDIS_URL not set")
+	}
+
+	options, err := redis.ParseURL(redisUrl)
+	if err != nil {
+		b.Fatalf("Failed to parse redis URL: %v", err)
+	}
+
+	return redis.NewClient(options)
+}
+
+// BenchmarkFetchCandidates times pulling the candidate pool for BENCH_USER, including the seen bitmap lookup
+func BenchmarkFetchCandidates(b *testing.B) {
+	db := benchDB(b)
+	rdb := benchRedis(b)
+
+	userID, err := strconv.ParseUint(os.Getenv("BENCH_USER"), 10, 64)
+	if err != nil {
+		b.Skip("BENCH_USER not set")
+	}
+
+	var viewer schemas.Profile
+	if err := db.First(&viewer, userID).Error; err != nil {
+		b.Fatalf("Failed to load profile %d: %v", userID, err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := discovery.FetchCandidates(viewer, nil, discovery.CandidatePoolSize, db, rdb); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDistanceFilter times counting the profiles within BENCH_DISTANCE_KM (default 50) with only the exact
+// distance check and with the bounding box prefilter in front of it. Profiles are seeded until there are
+// BENCH_PROFILES (default 100000). Both must find the same profiles, otherwise the bounding box is too small.
+func BenchmarkDistanceFilter(b *testing.B) {
+	db := benchDB(b)
+
+	profiles := envInt(b, "BENCH_PROFILES", 100000)
+	distanceKm := float64(envInt(b, "BENCH_DISTANCE_KM", 50))
+
+	var profileCount int64
+	if err := db.Model(&schemas.Profile{}).Count(&profileCount).Error; err != nil {
+		b.Fatalf("Failed to count profiles: %v", err)
+	}
+
+	if missing := profiles - int(profileCount); missing > 0 {
+		b.Logf("Seeding %d benchmark profiles...", missing)
+		if err := database.CreateBenchmarkProfiles(db, missing, benchLat, benchLon, 1500); err != nil {
+			b.Fatalf("Failed to seed benchmark profiles: %v", err)
+		}
+	}
+
+	location := *schemas.NewPoint(benchLat, benchLon)
+	distanceSQL := "ST_Distance_Sphere(" + discovery.EffectiveLocationSQL("profiles") + ", ST_GeomFromText(?)) <= ?"
+	nearbySQL, nearbyArgs := discovery.NearbySQL(location, distanceKm, "profiles")
+
+	var exactCount, boxCount int64
+
+	b.Run("exact", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := db.Table("profiles").Where(distanceSQL, location.String(), distanceKm*1000).Count(&exactCount).Error; err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("bounding_box", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := db.Table("profiles").Where(nearbySQL, nearbyArgs...).Where(distanceSQL, location.String(), distanceKm*1000).Count(&boxCount).Error; err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	if exactCount != boxCount {
+		b.Fatalf("bounding box found %d profiles but the exact distance found %d", boxCount, exactCount)
+	}
+}
+
+func envInt(b *testing.B, name string, fallback int) int {
+	b.Helper()
+
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		b.Fatalf("Invalid %s: %v", name, err)
+	}
+
+	return n
+}
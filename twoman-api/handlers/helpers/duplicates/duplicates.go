@@ -0,0 +1,213 @@
+package duplicates
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	"twoman/handlers/helpers/file"
+	"twoman/handlers/helpers/photos"
+	"twoman/schemas"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxHashDistance is how many of the 64 perceptual hash bits two photos can differ in and still be flagged
+	MaxHashDistance = 6
+	// maxFlagsPerUpload stops a very common image, e.g. a meme, flooding the queue
+	maxFlagsPerUpload = 5
+)
+
+var (
+	ErrFlagNotFound  = errors.New("duplicate photo flag not found")
+	ErrInvalidStatus = errors.New("status must be dismissed or confirmed")
+)
+
+// Review is a flagged upload with both accounts, for admins to compare side by side
+type Review struct {
+	Flag           schemas.DuplicatePhotoFlag `json:"flag"`
+	URL            string                     `json:"url"`
+	MatchedURL     string                     `json:"matched_url"`
+	Profile        *schemas.Profile           `json:"profile"`
+	MatchedProfile *schemas.Profile           `json:"matched_profile"`
+}
+
+// Check flags an upload whose perceptual hash is close to images uploaded by other accounts. Hamming distance
+// can't use an index, so candidates are found by their hash chunks first: with MaxHashDistance bits spread over
+// four chunks, a near copy has at least one chunk within a bit of the upload's.
+func Check(upload *schemas.FileMetadata, db *gorm.DB) ([]schemas.DuplicatePhotoFlag, error) {
+	// Flat images, e.g. a solid color, all hash to zeros or ones and would match each other
+	if upload.PerceptualHash == nil || *upload.PerceptualHash == 0 || *upload.PerceptualHash == ^uint64(0) {
+		return nil, nil
+	}
+
+	var matches []struct {
+		ID       uint
+		UserID   uint
+		Distance int
+	}
+	chunks := file.HashChunks(*upload.PerceptualHash)
+	if err := db.Model(&schemas.FileMetadata{}).
+		Select("id, user_id, BIT_COUNT(perceptual_hash ^ ?) AS distance", *upload.PerceptualHash).
+		Where("user_id != ? AND perceptual_hash IS NOT NULL", upload.UserID).
+		Where("hash_chunk1 IN ? OR hash_chunk2 IN ? OR hash_chunk3 IN ? OR hash_chunk4 IN ?",
+			nearChunks(chunks[0]), nearChunks(chunks[1]), nearChunks(chunks[2]), nearChunks(chunks[3])).
+		Where("BIT_COUNT(perceptual_hash ^ ?) <= ?", *upload.PerceptualHash, MaxHashDistance).
+		Order("distance").
+		Limit(maxFlagsPerUpload * 4).
+		Scan(&matches).Error; err != nil {
+		return nil, fmt.Errorf("error finding duplicate photos: %w", err)
+	}
+
+	// One flag per other account, for its closest photo
+	flags := []schemas.DuplicatePhotoFlag{}
+	flagged := make(map[uint]bool)
+	for _, match := range matches {
+		if flagged[match.UserID] || len(flags) >= maxFlagsPerUpload {
+			continue
+		}
+		flagged[match.UserID] = true
+
+		flags = append(flags, schemas.DuplicatePhotoFlag{
+			FileID:        upload.ID,
+			UserID:        upload.UserID,
+			MatchedFileID: match.ID,
+			MatchedUserID: match.UserID,
+			Distance:      match.Distance,
+			Status:        schemas.DuplicateFlagPending,
+		})
+	}
+
+	if len(flags) == 0 {
+		return flags, nil
+	}
+
+	if err := db.Create(&flags).Error; err != nil {
+		return nil, fmt.Errorf("error flagging duplicate photos: %w", err)
+	}
+
+	return flags, nil
+}
+
+// nearChunks returns chunk and every value one bit away from it
+func nearChunks(chunk uint16) []uint16 {
+	near := []uint16{chunk}
+	for bit := 0; bit < 16; bit++ {
+		near = append(near, chunk^(1<<bit))
+	}
+	return near
+}
+
+// ListPending returns the flags waiting for review, oldest first
+func ListPending(db *gorm.DB) ([]Review, error) {
+	var flags []schemas.DuplicatePhotoFlag
+	if err := db.Where("status = ?", schemas.DuplicateFlagPending).Order("created_at").Find(&flags).Error; err != nil {
+		return nil, err
+	}
+
+	fileIDs := []uint{}
+	profileIDs := []uint{}
+	for _, flag := range flags {
+		fileIDs = append(fileIDs, flag.FileID, flag.MatchedFileID)
+		profileIDs = append(profileIDs, flag.UserID, flag.MatchedUserID)
+	}
+
+	var files []schemas.FileMetadata
+	if len(fileIDs) > 0 {
+		if err := db.Where("id IN ?", fileIDs).Find(&files).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	var profiles []schemas.Profile
+	if len(profileIDs) > 0 {
+		if err := db.Where("user_id IN ?", profileIDs).Find(&profiles).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	withPhotos := make([]*schemas.Profile, 0, len(profiles))
+	profilesByID := make(map[uint]*schemas.Profile, len(profiles))
+	for i := range profiles {
+		withPhotos = append(withPhotos, &profiles[i])
+		profilesByID[profiles[i].UserID] = &profiles[i]
+	}
+
+	if err := photos.Attach(withPhotos, db); err != nil {
+		return nil, err
+	}
+
+	urls := make(map[uint]string, len(files))
+	for _, file := range files {
+		urls[file.ID] = os.Getenv("AWS_PUBLIC_URL") + "/" + file.Filename
+	}
+
+	reviews := make([]Review, 0, len(flags))
+	for _, flag := range flags {
+		reviews = append(reviews, Review{
+			Flag:           flag,
+			URL:            urls[flag.FileID],
+			MatchedURL:     urls[flag.MatchedFileID],
+			Profile:        profilesByID[flag.UserID],
+			MatchedProfile: profilesByID[flag.MatchedUserID],
+		})
+	}
+
+	return reviews, nil
+}
+
+// Resolve records an admin's decision on a flag. Confirming it rejects the uploader's copy of the photo.
+func Resolve(flagID uint, status string, adminID uint, db *gorm.DB) (*schemas.DuplicatePhotoFlag, error) {
+	if status != schemas.DuplicateFlagDismissed && status != schemas.DuplicateFlagConfirmed {
+		return nil, ErrInvalidStatus
+	}
+
+	var flag schemas.DuplicatePhotoFlag
+	if err := db.First(&flag, flagID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFlagNotFound
+		}
+		return nil, err
+	}
+
+	if status == schemas.DuplicateFlagConfirmed {
+		var upload schemas.FileMetadata
+		if err := db.Where("id = ?", flag.FileID).Limit(1).Find(&upload).Error; err != nil {
+			return nil, err
+		}
+
+		var duplicates []schemas.ProfilePhoto
+		if upload.ID != 0 {
+			if err := db.Where("profile_id = ? AND filename = ?", flag.UserID, upload.Filename).Find(&duplicates).Error; err != nil {
+				return nil, err
+			}
+		}
+
+		for _, photo := range duplicates {
+			if _, err := photos.SetModerationStatus(photo.ID, schemas.PhotoModerationRejected, db); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	now := time.Now()
+	flag.Status = status
+	flag.ReviewedBy = &adminID
+	flag.ReviewedAt = &now
+
+	if err := db.Model(&flag).Updates(map[string]interface{}{
+		"status":      status,
+		"reviewed_by": adminID,
+		"reviewed_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &flag, nil
+}
+
+// DeleteForUser removes every flag involving userID, e.g. when their account is deleted
+func DeleteForUser(userID uint, db *gorm.DB) error {
+	return db.Where("user_id = ? OR matched_user_id = ?", userID, userID).Delete(&schemas.DuplicatePhotoFlag{}).Error
+}
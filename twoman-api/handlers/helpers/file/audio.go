@@ -0,0 +1,402 @@
+package file
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+	"twoman/schemas"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxAudioSize     = 5 * 1024 * 1024 // 5MB
+	MaxAudioDuration = 30 * time.Second
+	MinAudioDuration = 1 * time.Second
+)
+
+var (
+	ErrUnsupportedAudio = errors.New("audio must be an m4a, aac or opus clip")
+	ErrAudioTooLarge    = fmt.Errorf("audio must be smaller than %dMB", MaxAudioSize/1024/1024)
+	ErrAudioTooLong     = fmt.Errorf("audio must be shorter than %d seconds", int(MaxAudioDuration.Seconds()))
+	ErrAudioTooShort    = fmt.Errorf("audio must be at least %d second long", int(MinAudioDuration.Seconds()))
+)
+
+// AudioMimeTypes are the content types clients upload audio clips with. The container is sniffed from the data
+// rather than trusted from the header.
+var AudioMimeTypes = map[string]bool{
+	"audio/mp4":   true,
+	"audio/m4a":   true,
+	"audio/x-m4a": true,
+	"audio/aac":   true,
+	"audio/x-aac": true,
+	"audio/ogg":   true,
+	"audio/opus":  true,
+}
+
+// audioFormat is a supported audio container, with what it's stored as
+type audioFormat struct {
+	extension   string
+	contentType string
+	duration    func(data []byte) (time.Duration, error)
+}
+
+var (
+	formatMP4  = audioFormat{extension: ".m4a", contentType: "audio/mp4", duration: mp4Duration}
+	formatADTS = audioFormat{extension: ".aac", contentType: "audio/aac", duration: adtsDuration}
+	formatOpus = audioFormat{extension: ".opus", contentType: "audio/ogg", duration: opusDuration}
+)
+
+type ProcessedAudio struct {
+	Data        []byte
+	Extension   string
+	ContentType string
+	Duration    time.Duration
+}
+
+// ProcessAudio reads an audio upload, works out its container from the data and checks its size and duration
+func ProcessAudio(file io.Reader) (*ProcessedAudio, error) {
+	data, err := io.ReadAll(io.LimitReader(file, MaxAudioSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxAudioSize {
+		return nil, ErrAudioTooLarge
+	}
+
+	format, ok := sniffAudio(data)
+	if !ok {
+		return nil, ErrUnsupportedAudio
+	}
+
+	duration, err := format.duration(data)
+	if err != nil {
+		return nil, err
+	}
+	if duration > MaxAudioDuration {
+		return nil, ErrAudioTooLong
+	}
+	if duration < MinAudioDuration {
+		return nil, ErrAudioTooShort
+	}
+
+	return &ProcessedAudio{
+		Data:        data,
+		Extension:   format.extension,
+		ContentType: format.contentType,
+		Duration:    duration,
+	}, nil
+}
+
+// GenerateUniqueAudioFilename is GenerateUniqueFilename for audio, which keeps its container's extension
+func GenerateUniqueAudioFilename(processed *ProcessedAudio) string {
+	return uuid.New().String() + processed.Extension
+}
+
+// StoreAudioMetadata records an uploaded audio clip
+func StoreAudioMetadata(filename string, processed *ProcessedAudio, userID uint, db *gorm.DB) (*schemas.FileMetadata, error) {
+	metadata := schemas.FileMetadata{
+		Filename:    filename,
+		Size:        int64(len(processed.Data)),
+		ContentType: processed.ContentType,
+		DurationMs:  int(processed.Duration.Milliseconds()),
+		UserID:      userID,
+	}
+
+	if err := db.Create(&metadata).Error; err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
+func sniffAudio(data []byte) (audioFormat, bool) {
+	switch {
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		return formatMP4, true
+	case len(data) >= 4 && string(data[:4]) == "OggS":
+		return formatOpus, true
+	case len(data) >= 3 && string(data[:3]) == "ID3", len(data) >= 2 && data[0] == 0xFF && data[1]&0xF6 == 0xF0:
+		return formatADTS, true
+	}
+
+	return audioFormat{}, false
+}
+
+// mp4Duration adds up the sample durations of the longest sound track of an MP4/M4A file. The movie header's
+// duration is written by the uploader and can say anything, so it isn't used. The file must have no video track, so
+// short videos can't be uploaded as voice clips, and its samples must fit in the file.
+func mp4Duration(data []byte) (time.Duration, error) {
+	moov, ok := findBox(data, "moov")
+	if !ok {
+		return 0, ErrUnsupportedAudio
+	}
+
+	var longest time.Duration
+	hasSound := false
+	for _, trak := range findBoxes(moov, "trak") {
+		mdia, ok := findBox(trak, "mdia")
+		if !ok {
+			continue
+		}
+		hdlr, ok := findBox(mdia, "hdlr")
+		if !ok || len(hdlr) < 12 {
+			continue
+		}
+		switch string(hdlr[8:12]) {
+		case "vide":
+			return 0, ErrUnsupportedAudio
+		case "soun":
+			duration, err := mp4TrackDuration(mdia, len(data))
+			if err != nil {
+				return 0, err
+			}
+			hasSound = true
+			longest = max(longest, duration)
+		}
+	}
+	if !hasSound {
+		return 0, ErrUnsupportedAudio
+	}
+
+	return longest, nil
+}
+
+// mp4TrackDuration reads a track's timescale from its media header and its length from the sample table. The
+// sample sizes must add up to no more than fileSize, so a header can't claim audio the file doesn't contain.
+func mp4TrackDuration(mdia []byte, fileSize int) (time.Duration, error) {
+	mdhd, ok := findBox(mdia, "mdhd")
+	if !ok || len(mdhd) < 16 {
+		return 0, ErrUnsupportedAudio
+	}
+
+	var timescale uint64
+	if mdhd[0] == 1 {
+		if len(mdhd) < 24 {
+			return 0, ErrUnsupportedAudio
+		}
+		timescale = uint64(binary.BigEndian.Uint32(mdhd[20:24]))
+	} else {
+		timescale = uint64(binary.BigEndian.Uint32(mdhd[12:16]))
+	}
+
+	minf, ok := findBox(mdia, "minf")
+	if !ok {
+		return 0, ErrUnsupportedAudio
+	}
+	stbl, ok := findBox(minf, "stbl")
+	if !ok {
+		return 0, ErrUnsupportedAudio
+	}
+
+	// Time to sample entries are a sample count and the duration of each of those samples
+	stts, ok := findBox(stbl, "stts")
+	if !ok || len(stts) < 8 {
+		return 0, ErrUnsupportedAudio
+	}
+	entries := uint64(binary.BigEndian.Uint32(stts[4:8]))
+	if entries > uint64(len(stts)-8)/8 {
+		return 0, ErrUnsupportedAudio
+	}
+
+	var samples, units uint64
+	for i := uint64(0); i < entries; i++ {
+		entry := stts[8+i*8:]
+		count := uint64(binary.BigEndian.Uint32(entry[:4]))
+		delta := uint64(binary.BigEndian.Uint32(entry[4:8]))
+		samples += count
+		if units+count*delta < units {
+			return 0, ErrUnsupportedAudio
+		}
+		units += count * delta
+	}
+	if samples == 0 {
+		return 0, ErrUnsupportedAudio
+	}
+
+	// Sample sizes are either one size for every sample or listed per sample
+	stsz, ok := findBox(stbl, "stsz")
+	if !ok || len(stsz) < 12 {
+		return 0, ErrUnsupportedAudio
+	}
+	sampleSize := uint64(binary.BigEndian.Uint32(stsz[4:8]))
+	sampleCount := uint64(binary.BigEndian.Uint32(stsz[8:12]))
+	if sampleCount != samples {
+		return 0, ErrUnsupportedAudio
+	}
+
+	totalSize := sampleSize * sampleCount
+	if sampleSize == 0 {
+		if sampleCount > uint64(len(stsz)-12)/4 {
+			return 0, ErrUnsupportedAudio
+		}
+		for i := uint64(0); i < sampleCount; i++ {
+			totalSize += uint64(binary.BigEndian.Uint32(stsz[12+i*4:]))
+		}
+	}
+	if totalSize > uint64(fileSize) {
+		return 0, ErrUnsupportedAudio
+	}
+
+	return samplesDuration(units, timescale)
+}
+
+// findBox returns the payload of the first box of the given type directly inside data
+func findBox(data []byte, boxType string) ([]byte, bool) {
+	boxes := findBoxes(data, boxType)
+	if len(boxes) == 0 {
+		return nil, false
+	}
+	return boxes[0], true
+}
+
+// findBoxes returns the payloads of every box of the given type directly inside data
+func findBoxes(data []byte, boxType string) [][]byte {
+	boxes := [][]byte{}
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[:4]))
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return boxes
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			header = 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return boxes
+		}
+
+		if string(data[4:8]) == boxType {
+			boxes = append(boxes, data[header:size])
+		}
+		data = data[size:]
+	}
+
+	return boxes
+}
+
+// adtsSampleRates are indexed by the sampling frequency index of an ADTS header
+var adtsSampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// adtsDuration counts the AAC frames of a raw ADTS stream, each of which holds 1024 samples per raw data block
+func adtsDuration(data []byte) (time.Duration, error) {
+	// Skip an ID3v2 tag, whose size is stored as four 7 bit bytes
+	if len(data) >= 10 && string(data[:3]) == "ID3" {
+		tagSize := int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F)
+		if 10+tagSize > len(data) {
+			return 0, ErrUnsupportedAudio
+		}
+		data = data[10+tagSize:]
+	}
+
+	// An ID3v1 tag can be appended after the last frame
+	if len(data) >= 128 && string(data[len(data)-128:len(data)-125]) == "TAG" {
+		data = data[:len(data)-128]
+	}
+
+	sampleRate := 0
+	samples := 0
+	for len(data) > 0 {
+		// Anything that isn't a whole frame could be other data hiding behind a short clip, so the upload is
+		// rejected rather than cut off there
+		if len(data) < 7 || data[0] != 0xFF || data[1]&0xF6 != 0xF0 {
+			return 0, ErrUnsupportedAudio
+		}
+
+		rateIndex := int(data[2]>>2) & 0x0F
+		if rateIndex >= len(adtsSampleRates) {
+			return 0, ErrUnsupportedAudio
+		}
+		sampleRate = adtsSampleRates[rateIndex]
+
+		frameLength := int(data[3]&0x03)<<11 | int(data[4])<<3 | int(data[5]>>5)
+		if frameLength < 7 || frameLength > len(data) {
+			return 0, ErrUnsupportedAudio
+		}
+
+		samples += 1024 * (int(data[6]&0x03) + 1)
+		data = data[frameLength:]
+	}
+
+	if sampleRate == 0 {
+		return 0, ErrUnsupportedAudio
+	}
+
+	return samplesDuration(uint64(samples), uint64(sampleRate))
+}
+
+// oggEndOfStream is the header type flag set on the last page of an Ogg logical stream
+const oggEndOfStream = 0x04
+
+// opusDuration reads an Ogg Opus stream. Opus granule positions count 48kHz samples, so the duration is the last
+// page's granule position less the pre-skip from the OpusHead header. The file must be nothing but whole pages of
+// a single stream, ending with its end of stream page.
+func opusDuration(data []byte) (time.Duration, error) {
+	var serial uint32
+	var preSkip, lastGranule int64
+	var headerType byte
+	first := true
+
+	for len(data) > 0 {
+		if len(data) < 27 || string(data[:4]) != "OggS" || data[4] != 0 {
+			return 0, ErrUnsupportedAudio
+		}
+
+		headerType = data[5]
+		granule := int64(binary.LittleEndian.Uint64(data[6:14]))
+		pageSerial := binary.LittleEndian.Uint32(data[14:18])
+		segments := int(data[26])
+		if len(data) < 27+segments {
+			return 0, ErrUnsupportedAudio
+		}
+
+		bodyLength := 0
+		for _, lacing := range data[27 : 27+segments] {
+			bodyLength += int(lacing)
+		}
+		if len(data) < 27+segments+bodyLength {
+			return 0, ErrUnsupportedAudio
+		}
+		body := data[27+segments : 27+segments+bodyLength]
+
+		if first {
+			if len(body) < 19 || !bytes.Equal(body[:8], []byte("OpusHead")) {
+				return 0, ErrUnsupportedAudio
+			}
+			serial = pageSerial
+			preSkip = int64(binary.LittleEndian.Uint16(body[10:12]))
+			first = false
+		} else if pageSerial != serial {
+			return 0, ErrUnsupportedAudio
+		} else if granule > 0 {
+			lastGranule = granule
+		}
+
+		data = data[27+segments+bodyLength:]
+	}
+
+	if first || headerType&oggEndOfStream == 0 || lastGranule <= preSkip {
+		return 0, ErrUnsupportedAudio
+	}
+
+	return samplesDuration(uint64(lastGranule-preSkip), 48000)
+}
+
+// samplesDuration converts a sample count at the given rate to a duration. The counts come from the upload, so
+// absurd values are rejected instead of overflowing.
+func samplesDuration(samples uint64, rate uint64) (time.Duration, error) {
+	if rate == 0 || samples/rate > uint64(math.MaxInt32) {
+		return 0, ErrUnsupportedAudio
+	}
+
+	return time.Duration(samples/rate)*time.Second + time.Duration(samples%rate*uint64(time.Second)/rate), nil
+}
@@ -0,0 +1,34 @@
+package file
+
+import (
+	"image"
+
+	"golang.org/x/image/draw"
+)
+
+// DHash is a 64 bit difference hash of img. Each bit records whether a pixel of a 9x8 grayscale thumbnail is
+// brighter than its right neighbour, so re-encoded, resized or slightly edited copies of a photo hash to values
+// a few bits apart.
+func DHash(img image.Image) uint64 {
+	small := image.NewGray(image.Rect(0, 0, 9, 8))
+	draw.CatmullRom.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if small.GrayAt(x, y).Y > small.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+
+	return hash
+}
+
+// HashChunks splits a hash into the four 16 bit chunks stored next to it, highest bits first. Hashes within a few
+// bits of each other share a chunk, or have one within a bit of it, so near copies can be found with indexed
+// lookups instead of comparing every hash.
+func HashChunks(hash uint64) [4]uint16 {
+	return [4]uint16{uint16(hash >> 48), uint16(hash >> 32), uint16(hash >> 16), uint16(hash)}
+}
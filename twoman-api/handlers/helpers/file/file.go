@@ -5,11 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	_ "image/gif"
 	"image/jpeg"
+	_ "image/png"
+	"io"
 	"log"
 	"math"
-	"mime/multipart"
 	"os"
+	"path"
+	"strings"
 	"twoman/schemas"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -26,46 +30,124 @@ const (
 	JPEGQuality = 85
 )
 
-func ProcessImage(file multipart.File) ([]byte, error) {
+// Rendition is one size uploaded images are stored in. Images are scaled down to fit within MaxWidth x MaxHeight,
+// never up.
+type Rendition struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int
+}
+
+// Renditions are the sizes every uploaded image is stored in, smallest first. The full rendition keeps the
+// upload's file name so existing image URLs keep working.
+var Renditions = []Rendition{
+	{Name: "thumbnail", MaxWidth: 320, MaxHeight: 320},
+	{Name: "medium", MaxWidth: 960, MaxHeight: 960},
+	{Name: "full", MaxWidth: MaxWidth, MaxHeight: MaxHeight},
+}
+
+type ProcessedRendition struct {
+	Name   string
+	Data   []byte
+	Width  int
+	Height int
+}
+
+type ProcessedImage struct {
+	Renditions     []ProcessedRendition
+	Blurhash       string
+	PerceptualHash uint64
+	Width          int
+	Height         int
+}
+
+// ProcessImage decodes an upload, turns it upright using its EXIF orientation and encodes every rendition as a
+// JPEG. Only decoded pixels are re-encoded, so EXIF, GPS and any other metadata are dropped.
+func ProcessImage(file io.Reader) (*ProcessedImage, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
 	// Decode the image
-	img, _, err := image.Decode(file)
+	img, _, err := image.Decode(bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
 
+	img = ApplyOrientation(img, Orientation(data))
+
+	processed := &ProcessedImage{
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}
+
+	for _, rendition := range Renditions {
+		resized := resize(img, rendition.MaxWidth, rendition.MaxHeight)
+
+		// Encode to JPEG
+		var buffer bytes.Buffer
+		if err := jpeg.Encode(&buffer, resized, &jpeg.Options{Quality: JPEGQuality}); err != nil {
+			return nil, err
+		}
+
+		processed.Renditions = append(processed.Renditions, ProcessedRendition{
+			Name:   rendition.Name,
+			Data:   buffer.Bytes(),
+			Width:  resized.Bounds().Dx(),
+			Height: resized.Bounds().Dy(),
+		})
+
+		// The hashes only need a small image, so compute them from the thumbnail
+		if processed.Blurhash == "" {
+			processed.Blurhash = Blurhash(resized)
+			processed.PerceptualHash = DHash(resized)
+		}
+	}
+
+	return processed, nil
+}
+
+// resize scales img down to fit within maxWidth x maxHeight, keeping its aspect ratio
+func resize(img image.Image, maxWidth int, maxHeight int) image.Image {
 	// Get original dimensions
 	bounds := img.Bounds()
 	origWidth := bounds.Dx()
 	origHeight := bounds.Dy()
 
 	// Calculate scaling factor
-	widthScale := float64(MaxWidth) / float64(origWidth)
-	heightScale := float64(MaxHeight) / float64(origHeight)
+	widthScale := float64(maxWidth) / float64(origWidth)
+	heightScale := float64(maxHeight) / float64(origHeight)
 	scale := math.Min(widthScale, heightScale)
-	scale = math.Min(scale, 1.0)
 
-	if scale < 1.0 {
-		// Calculate new dimensions
-		newWidth := int(float64(origWidth) * scale)
-		newHeight := int(float64(origHeight) * scale)
+	if scale >= 1.0 {
+		return img
+	}
 
-		// Create a new image with the calculated dimensions
-		newImg := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	// Calculate new dimensions
+	newWidth := int(math.Max(1, math.Round(float64(origWidth)*scale)))
+	newHeight := int(math.Max(1, math.Round(float64(origHeight)*scale)))
 
-		// Resize the image
-		draw.NearestNeighbor.Scale(newImg, newImg.Bounds(), img, bounds, draw.Over, nil)
+	// CatmullRom is slower than NearestNeighbor but keeps downscaled photos sharp without aliasing
+	newImg := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	draw.CatmullRom.Scale(newImg, newImg.Bounds(), img, bounds, draw.Src, nil)
 
-		img = newImg
-	}
+	return newImg
+}
 
-	// Encode to JPEG
-	var buffer bytes.Buffer
-	err = jpeg.Encode(&buffer, img, &jpeg.Options{Quality: JPEGQuality})
-	if err != nil {
-		return nil, err
+// RenditionFilename is the object key of a rendition of the upload stored as filename
+func RenditionFilename(filename string, rendition string) string {
+	if rendition == "full" {
+		return filename
 	}
 
-	return buffer.Bytes(), nil
+	return fmt.Sprintf("%s_%s%s", strings.TrimSuffix(filename, path.Ext(filename)), rendition, path.Ext(filename))
+}
+
+// RenditionURL swaps the file name at the end of an uploaded image's URL for one of its renditions
+func RenditionURL(url string, rendition string) string {
+	filename := url[strings.LastIndex(url, "/")+1:]
+	return strings.TrimSuffix(url, filename) + RenditionFilename(filename, rendition)
 }
 
 func SaveProgressiveJPEG(img image.Image) ([]byte, error) {
@@ -101,6 +183,41 @@ func StoreFileMetadata(filename string, size int64, userID uint, db *gorm.DB) (*
 	return &metadata, nil
 }
 
+// StoreImageMetadata records an uploaded image and its renditions. Size is the size of the full rendition.
+func StoreImageMetadata(filename string, processed *ProcessedImage, userID uint, db *gorm.DB) (*schemas.FileMetadata, error) {
+	metadata := schemas.FileMetadata{
+		Filename:    filename,
+		ContentType: "image/jpeg",
+		UserID:      userID,
+		Blurhash:    processed.Blurhash,
+		Width:       processed.Width,
+		Height:      processed.Height,
+	}
+
+	perceptualHash := processed.PerceptualHash
+	metadata.PerceptualHash = &perceptualHash
+	chunks := HashChunks(perceptualHash)
+	metadata.HashChunk1, metadata.HashChunk2, metadata.HashChunk3, metadata.HashChunk4 = &chunks[0], &chunks[1], &chunks[2], &chunks[3]
+
+	renditions := []string{}
+	for _, rendition := range processed.Renditions {
+		if rendition.Name == "full" {
+			metadata.Size = int64(len(rendition.Data))
+			metadata.Width = rendition.Width
+			metadata.Height = rendition.Height
+			continue
+		}
+		renditions = append(renditions, RenditionFilename(filename, rendition.Name))
+	}
+	metadata.Renditions = strings.Join(renditions, ",")
+
+	if err := db.Create(&metadata).Error; err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
 func DeleteFileByName(filename string, db *gorm.DB, s3Client *s3.S3) error {
 	log.Println("Deleting file by name: ", filename)
 	var metadata schemas.FileMetadata
@@ -114,14 +231,21 @@ func DeleteFileByName(filename string, db *gorm.DB, s3Client *s3.S3) error {
 		}
 	}
 
-	// Delete from S3
-	_, err := s3Client.DeleteObject(&s3.DeleteObjectInput{
-		Key:    aws.String(filename),
-		Bucket: aws.String(os.Getenv("AWS_BUCKET_NAME")),
-	})
+	// Delete from S3, along with any smaller renditions of an image
+	keys := []string{filename}
+	if metadata.Renditions != "" {
+		keys = append(keys, strings.Split(metadata.Renditions, ",")...)
+	}
 
-	if err != nil {
-		return fmt.Errorf("failed to delete file from S3: %w", err)
+	for _, key := range keys {
+		_, err := s3Client.DeleteObject(&s3.DeleteObjectInput{
+			Key:    aws.String(key),
+			Bucket: aws.String(os.Getenv("AWS_BUCKET_NAME")),
+		})
+
+		if err != nil {
+			return fmt.Errorf("failed to delete file from S3: %w", err)
+		}
 	}
 
 	// If we found metadata earlier, delete it from the database
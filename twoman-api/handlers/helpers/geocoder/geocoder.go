@@ -0,0 +1,35 @@
+package geocoder
+
+import "errors"
+
+// Backends that New accepts
+const (
+	BackendOffline = "offline"
+	BackendGoogle  = "google"
+)
+
+var ErrNoCity = errors.New("no city found near location")
+
+// Geocoder turns a coordinate into the name of the city it's in or near
+type Geocoder interface {
+	City(lat, lon float64) (string, error)
+}
+
+// CityOrEmpty looks up the city at a coordinate. Places that aren't near a city, like the open sea or remote areas,
+// are still valid locations and get an empty city instead of ErrNoCity.
+func CityOrEmpty(g Geocoder, lat, lon float64) (string, error) {
+	city, err := g.City(lat, lon)
+	if errors.Is(err, ErrNoCity) {
+		return "", nil
+	}
+	return city, err
+}
+
+// New returns the geocoder for a backend. The bundled offline dataset is used unless backend is BackendGoogle, so
+// local development works without an API key.
+func New(backend, googleAPIKey string) (Geocoder, error) {
+	if backend == BackendGoogle {
+		return NewGoogle(googleAPIKey)
+	}
+	return NewOffline()
+}
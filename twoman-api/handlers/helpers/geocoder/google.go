@@ -0,0 +1,42 @@
+package geocoder
+
+import (
+	"context"
+
+	"googlemaps.github.io/maps"
+)
+
+// Google looks up cities with the Google Maps reverse geocoding API
+type Google struct {
+	client *maps.Client
+}
+
+func NewGoogle(apiKey string) (*Google, error) {
+	client, err := maps.NewClient(maps.WithAPIKey(apiKey))
+	if err != nil {
+		return nil, err
+	}
+	return &Google{client: client}, nil
+}
+
+// City returns the locality Google places the coordinate in
+func (g *Google) City(lat, lon float64) (string, error) {
+	resp, err := g.client.ReverseGeocode(context.Background(), &maps.GeocodingRequest{
+		LatLng: &maps.LatLng{Lat: lat, Lng: lon},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, result := range resp {
+		for _, component := range result.AddressComponents {
+			for _, t := range component.Types {
+				if t == "locality" {
+					return component.LongName, nil
+				}
+			}
+		}
+	}
+
+	return "", ErrNoCity
+}
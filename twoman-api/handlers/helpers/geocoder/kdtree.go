@@ -0,0 +1,109 @@
+package geocoder
+
+import "math"
+
+type kdPoint struct {
+	pos  [3]float64
+	city int32
+}
+
+// kdTree is a 3-d tree stored in one slice. The node for a range is the point at its middle, points before it are
+// on the low side of the split and points after it on the high side. Nodes split on x, y and z in turn.
+type kdTree struct {
+	points []kdPoint
+}
+
+func newKDTree(points []kdPoint) kdTree {
+	build(points, 0)
+	return kdTree{points: points}
+}
+
+func build(points []kdPoint, depth int) {
+	if len(points) <= 1 {
+		return
+	}
+
+	mid := len(points) / 2
+	selectNth(points, mid, depth%3)
+	build(points[:mid], depth+1)
+	build(points[mid+1:], depth+1)
+}
+
+// selectNth partially sorts points on axis so the nth is where it would be fully sorted, with nothing larger before
+// it and nothing smaller after it
+func selectNth(points []kdPoint, n, axis int) {
+	lo, hi := 0, len(points)-1
+	for lo < hi {
+		pivot := points[(lo+hi)/2].pos[axis]
+		i, j := lo, hi
+		for i <= j {
+			for points[i].pos[axis] < pivot {
+				i++
+			}
+			for points[j].pos[axis] > pivot {
+				j--
+			}
+			if i <= j {
+				points[i], points[j] = points[j], points[i]
+				i++
+				j--
+			}
+		}
+
+		switch {
+		case n <= j:
+			hi = j
+		case n >= i:
+			lo = i
+		default:
+			return
+		}
+	}
+}
+
+// nearest returns the closest point to target and its straight line distance
+func (t kdTree) nearest(target [3]float64) (kdPoint, float64, bool) {
+	if len(t.points) == 0 {
+		return kdPoint{}, 0, false
+	}
+
+	best := -1
+	bestDist := math.Inf(1)
+	t.search(target, 0, len(t.points), 0, &best, &bestDist)
+
+	return t.points[best], math.Sqrt(bestDist), true
+}
+
+// search walks the range lo..hi, keeping the closest point found so far. Distances are squared.
+func (t kdTree) search(target [3]float64, lo, hi, depth int, best *int, bestDist *float64) {
+	if lo >= hi {
+		return
+	}
+
+	mid := (lo + hi) / 2
+	node := t.points[mid].pos
+
+	dist := 0.0
+	for axis := range node {
+		d := target[axis] - node[axis]
+		dist += d * d
+	}
+	if dist < *bestDist {
+		*best = mid
+		*bestDist = dist
+	}
+
+	// Search the side of the split the target is on first, then the other side only if it could hold something closer
+	diff := target[depth%3] - node[depth%3]
+	if diff < 0 {
+		t.search(target, lo, mid, depth+1, best, bestDist)
+		if diff*diff < *bestDist {
+			t.search(target, mid+1, hi, depth+1, best, bestDist)
+		}
+	} else {
+		t.search(target, mid+1, hi, depth+1, best, bestDist)
+		if diff*diff < *bestDist {
+			t.search(target, lo, mid, depth+1, best, bestDist)
+		}
+	}
+}
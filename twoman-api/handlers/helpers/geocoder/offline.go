@@ -0,0 +1,99 @@
+package geocoder
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxDistanceKm is how far the nearest city can be before a location is treated as being nowhere in particular
+const MaxDistanceKm = 100
+
+const earthRadiusKm = 6371.0
+
+// cities.tsv.gz lists every populated place with at least 1,000 people as name, lat, lon and country code, one per
+// line. It comes from GeoNames (geonames.org) by way of github.com/lutangar/cities.json and is licensed under
+// CC BY 4.0.
+//
+//go:embed cities.tsv.gz
+var citiesData []byte
+
+// Offline finds the nearest city in the bundled GeoNames dataset, without any network calls
+type Offline struct {
+	names []string
+	tree  kdTree
+}
+
+// NewOffline loads the bundled dataset and indexes it. It takes a moment, so build one and share it.
+func NewOffline() (*Offline, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(citiesData))
+	if err != nil {
+		return nil, fmt.Errorf("error opening cities dataset: %w", err)
+	}
+	defer reader.Close()
+
+	o := &Offline{}
+	var points []kdPoint
+
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("cities dataset line %d: expected name, lat and lon", line)
+		}
+
+		lat, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("cities dataset line %d: %w", line, err)
+		}
+		lon, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("cities dataset line %d: %w", line, err)
+		}
+
+		points = append(points, kdPoint{pos: toVector(lat, lon), city: int32(len(o.names))})
+		o.names = append(o.names, fields[0])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading cities dataset: %w", err)
+	}
+
+	o.tree = newKDTree(points)
+	return o, nil
+}
+
+// City returns the nearest city within MaxDistanceKm
+func (o *Offline) City(lat, lon float64) (string, error) {
+	if math.IsNaN(lat) || math.IsNaN(lon) || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return "", fmt.Errorf("invalid coordinate %f, %f", lat, lon)
+	}
+
+	nearest, chord, ok := o.tree.nearest(toVector(lat, lon))
+	if !ok || chordToKm(chord) > MaxDistanceKm {
+		return "", ErrNoCity
+	}
+
+	return o.names[nearest.city], nil
+}
+
+// toVector places a coordinate on the unit sphere. Straight line distance between these points orders the same way
+// as distance over the earth's surface, with no special cases at the poles or the antimeridian.
+func toVector(lat, lon float64) [3]float64 {
+	latRad := lat * math.Pi / 180
+	lonRad := lon * math.Pi / 180
+	return [3]float64{
+		math.Cos(latRad) * math.Cos(lonRad),
+		math.Cos(latRad) * math.Sin(lonRad),
+		math.Sin(latRad),
+	}
+}
+
+// chordToKm turns a straight line distance between two points on the unit sphere into km along the surface
+func chordToKm(chord float64) float64 {
+	return 2 * earthRadiusKm * math.Asin(math.Min(chord/2, 1))
+}
@@ -0,0 +1,445 @@
+package interests
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"twoman/schemas"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// MaxInterests caps how many interests a profile can pick
+	MaxInterests = 10
+	// MaxNameLength caps how long an interest name can be
+	MaxNameLength = 64
+	// OtherCategory holds interests typed in by users rather than picked from the catalog
+	OtherCategory = "Other"
+)
+
+var (
+	ErrTooManyInterests = fmt.Errorf("a profile can have at most %d interests", MaxInterests)
+	ErrUnknownInterest  = errors.New("interest is not in the catalog")
+	ErrInterestNotFound = errors.New("interest not found")
+	ErrCategoryNotFound = errors.New("interest category not found")
+	ErrDuplicateName    = errors.New("an interest with this name already exists")
+	ErrInvalidName      = fmt.Errorf("interest names must be between 1 and %d characters", MaxNameLength)
+)
+
+// DefaultCatalog is the catalog a new database starts with, in display order
+var DefaultCatalog = []struct {
+	Category  string
+	Interests []string
+}{
+	{"Sports", []string{"Sports", "Running", "Gym", "Football", "Basketball", "Tennis", "Yoga", "Hiking", "Climbing", "Cycling", "Swimming"}},
+	{"Music", []string{"Music", "Concerts", "Festivals", "Hip Hop", "Rock", "Jazz", "Electronic", "Country"}},
+	{"Food & Drink", []string{"Cooking", "Baking", "Coffee", "Wine", "Craft Beer", "Brunch", "Vegan"}},
+	{"Travel", []string{"Travel", "Road Trips", "Camping", "Beaches", "Backpacking"}},
+	{"Arts & Culture", []string{"Reading", "Photography", "Art", "Museums", "Theatre", "Writing", "Fashion"}},
+	{"Entertainment", []string{"Movies", "TV Shows", "Video Games", "Board Games", "Anime", "Podcasts", "Comedy"}},
+	{"Lifestyle", []string{"Dogs", "Cats", "Gardening", "Volunteering", "Meditation", "Dancing", "Nightlife"}},
+}
+
+// SeedCatalog adds the default catalog, leaving interests that already exist (by slug) where they are
+func SeedCatalog(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for position, entry := range DefaultCatalog {
+			parent := schemas.InterestCategory{Name: entry.Category}
+			if err := tx.Where(schemas.InterestCategory{Name: entry.Category}).
+				Attrs(schemas.InterestCategory{Position: position}).FirstOrCreate(&parent).Error; err != nil {
+				return fmt.Errorf("error seeding interest category %s: %w", entry.Category, err)
+			}
+
+			for _, name := range entry.Interests {
+				var interest schemas.Interest
+				if err := tx.Where(schemas.Interest{Slug: Normalize(name)}).
+					Attrs(schemas.Interest{CategoryID: parent.ID, Name: name, Active: true}).FirstOrCreate(&interest).Error; err != nil {
+					return fmt.Errorf("error seeding interest %s: %w", name, err)
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// Normalize turns an interest name into its slug: lower case with single spaces, so "Rock  Climbing " and
+// "rock climbing" are the same interest
+func Normalize(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// Split splits a comma separated list of interests into trimmed names, dropping blanks and repeats of the same slug
+func Split(value string) []string {
+	seen := make(map[string]bool)
+	names := []string{}
+
+	for _, name := range strings.Split(value, ",") {
+		name = strings.Join(strings.Fields(name), " ")
+		slug := Normalize(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// SameNames reports whether two comma separated lists name the same interests, ignoring case, spacing and order
+func SameNames(a, b string) bool {
+	aNames, bNames := Split(a), Split(b)
+	if len(aNames) != len(bNames) {
+		return false
+	}
+
+	inA := make(map[string]bool, len(aNames))
+	for _, name := range aNames {
+		inA[Normalize(name)] = true
+	}
+	for _, name := range bNames {
+		if !inA[Normalize(name)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Names joins interests the way Profile.Interests stores them
+func Names(interests []schemas.Interest) string {
+	names := make([]string, 0, len(interests))
+	for _, interest := range interests {
+		names = append(names, interest.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Catalog returns the categories with the interests users can pick, in display order. Admins also see inactive
+// interests.
+func Catalog(includeInactive bool, db *gorm.DB) ([]schemas.InterestCategory, error) {
+	categories := []schemas.InterestCategory{}
+	err := db.Preload("Interests", func(tx *gorm.DB) *gorm.DB {
+		if !includeInactive {
+			tx = tx.Where("active = ?", true)
+		}
+		return tx.Order("name")
+	}).Order("position, name").Find(&categories).Error
+	if err != nil {
+		return nil, fmt.Errorf("error loading interest catalog: %w", err)
+	}
+
+	return categories, nil
+}
+
+// List returns a profile's interests in alphabetical order
+func List(profileID uint, db *gorm.DB) ([]schemas.Interest, error) {
+	interests := []schemas.Interest{}
+	if err := db.Joins("JOIN profile_interests pi ON pi.interest_id = interests.id").
+		Where("pi.profile_id = ?", profileID).Order("interests.name").Find(&interests).Error; err != nil {
+		return nil, fmt.Errorf("error loading profile interests: %w", err)
+	}
+
+	return interests, nil
+}
+
+// Attach fills InterestTags on each profile, using a single query
+func Attach(profiles []*schemas.Profile, db *gorm.DB) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(profiles))
+	for _, profile := range profiles {
+		ids = append(ids, profile.UserID)
+	}
+
+	var rows []struct {
+		schemas.Interest
+		ProfileID uint
+	}
+	if err := db.Table("interests").Select("interests.*, pi.profile_id").
+		Joins("JOIN profile_interests pi ON pi.interest_id = interests.id").
+		Where("pi.profile_id IN ?", ids).Order("interests.name").Scan(&rows).Error; err != nil {
+		return fmt.Errorf("error loading profile interests: %w", err)
+	}
+
+	byProfile := make(map[uint][]schemas.Interest, len(profiles))
+	for _, row := range rows {
+		byProfile[row.ProfileID] = append(byProfile[row.ProfileID], row.Interest)
+	}
+
+	for _, profile := range profiles {
+		profile.InterestTags = byProfile[profile.UserID]
+	}
+
+	return nil
+}
+
+// Set replaces a profile's interests with interests picked from the catalog
+func Set(profileID uint, interestIDs []uint, db *gorm.DB) ([]schemas.Interest, error) {
+	unique := make(map[uint]bool, len(interestIDs))
+	for _, id := range interestIDs {
+		unique[id] = true
+	}
+	if len(unique) > MaxInterests {
+		return nil, ErrTooManyInterests
+	}
+
+	picked := []schemas.Interest{}
+	if len(unique) > 0 {
+		if err := db.Where("id IN ? AND active = ?", interestIDs, true).Order("name").Find(&picked).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(picked) != len(unique) {
+		return nil, ErrUnknownInterest
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return replace(profileID, picked, tx)
+	}); err != nil {
+		return nil, err
+	}
+
+	return picked, nil
+}
+
+// SetFromNames replaces a profile's interests from a comma separated list, the way clients that predate the
+// catalog send them. Names that aren't in the catalog are added to it as inactive Other interests.
+func SetFromNames(profileID uint, value string, db *gorm.DB) ([]schemas.Interest, error) {
+	names := Split(value)
+	if len(names) > MaxInterests {
+		return nil, ErrTooManyInterests
+	}
+
+	var picked []schemas.Interest
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if picked, err = Resolve(names, tx); err != nil {
+			return err
+		}
+
+		return replace(profileID, picked, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return picked, nil
+}
+
+// Resolve finds the catalog interest for each name by slug, adding the ones it doesn't know as inactive Other
+// interests
+func Resolve(names []string, db *gorm.DB) ([]schemas.Interest, error) {
+	if len(names) == 0 {
+		return []schemas.Interest{}, nil
+	}
+
+	slugs := make([]string, 0, len(names))
+	for _, name := range names {
+		if len(name) > MaxNameLength {
+			return nil, ErrInvalidName
+		}
+		slugs = append(slugs, Normalize(name))
+	}
+
+	var known []schemas.Interest
+	if err := db.Where("slug IN ?", slugs).Find(&known).Error; err != nil {
+		return nil, err
+	}
+
+	bySlug := make(map[string]schemas.Interest, len(known))
+	for _, interest := range known {
+		bySlug[interest.Slug] = interest
+	}
+
+	resolved := make([]schemas.Interest, 0, len(names))
+	for i, name := range names {
+		if interest, ok := bySlug[slugs[i]]; ok {
+			resolved = append(resolved, interest)
+			continue
+		}
+
+		other, err := category(OtherCategory, db)
+		if err != nil {
+			return nil, err
+		}
+
+		interest := schemas.Interest{CategoryID: other.ID, Name: name, Slug: slugs[i], Active: false}
+		if err := db.Select("CategoryID", "Name", "Slug", "Active").Create(&interest).Error; err != nil {
+			return nil, err
+		}
+		bySlug[interest.Slug] = interest
+		resolved = append(resolved, interest)
+	}
+
+	return resolved, nil
+}
+
+// DeleteAll removes a profile's interests, e.g. when the account is deleted
+func DeleteAll(profileID uint, db *gorm.DB) error {
+	return db.Where("profile_id = ?", profileID).Delete(&schemas.ProfileInterest{}).Error
+}
+
+// CreateInterest adds an active interest to a catalog category, creating the category if needed
+func CreateInterest(name string, categoryName string, db *gorm.DB) (*schemas.Interest, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || len(name) > MaxNameLength || strings.TrimSpace(categoryName) == "" {
+		return nil, ErrInvalidName
+	}
+
+	var existing int64
+	if err := db.Model(&schemas.Interest{}).Where("slug = ?", Normalize(name)).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrDuplicateName
+	}
+
+	parent, err := category(strings.TrimSpace(categoryName), db)
+	if err != nil {
+		return nil, err
+	}
+
+	interest := schemas.Interest{CategoryID: parent.ID, Name: name, Slug: Normalize(name), Active: true}
+	if err := db.Create(&interest).Error; err != nil {
+		return nil, err
+	}
+
+	return &interest, nil
+}
+
+// UpdateInterest renames, moves or (de)activates a catalog interest. Nil fields are left unchanged. Profiles that
+// picked it keep it either way.
+func UpdateInterest(interestID uint, name *string, categoryID *uint, active *bool, db *gorm.DB) (*schemas.Interest, error) {
+	var interest schemas.Interest
+	if err := db.First(&interest, interestID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInterestNotFound
+		}
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if name != nil {
+		trimmed := strings.Join(strings.Fields(*name), " ")
+		if trimmed == "" || len(trimmed) > MaxNameLength {
+			return nil, ErrInvalidName
+		}
+
+		var duplicates int64
+		if err := db.Model(&schemas.Interest{}).Where("slug = ? AND id != ?", Normalize(trimmed), interestID).Count(&duplicates).Error; err != nil {
+			return nil, err
+		}
+		if duplicates > 0 {
+			return nil, ErrDuplicateName
+		}
+
+		interest.Name = trimmed
+		interest.Slug = Normalize(trimmed)
+		updates["name"] = interest.Name
+		updates["slug"] = interest.Slug
+	}
+	if categoryID != nil {
+		var parent schemas.InterestCategory
+		if err := db.Where("id = ?", *categoryID).Limit(1).Find(&parent).Error; err != nil {
+			return nil, err
+		}
+		if parent.ID == 0 {
+			return nil, ErrCategoryNotFound
+		}
+		interest.CategoryID = parent.ID
+		updates["category_id"] = parent.ID
+	}
+	if active != nil {
+		interest.Active = *active
+		updates["active"] = *active
+	}
+
+	if len(updates) == 0 {
+		return &interest, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&interest).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		if name == nil {
+			return nil
+		}
+
+		// Keep the names mirrored into Profile.Interests up to date
+		var profileIDs []uint
+		if err := tx.Model(&schemas.ProfileInterest{}).Where("interest_id = ?", interestID).Pluck("profile_id", &profileIDs).Error; err != nil {
+			return err
+		}
+		for _, profileID := range profileIDs {
+			if err := syncNames(profileID, tx); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &interest, nil
+}
+
+// category returns the category with the given name, creating it at the end of the catalog if needed
+func category(name string, db *gorm.DB) (*schemas.InterestCategory, error) {
+	var found schemas.InterestCategory
+	if err := db.Where("name = ?", name).Limit(1).Find(&found).Error; err != nil {
+		return nil, err
+	}
+	if found.ID != 0 {
+		return &found, nil
+	}
+
+	var last int
+	if err := db.Model(&schemas.InterestCategory{}).Select("COALESCE(MAX(position), -1)").Scan(&last).Error; err != nil {
+		return nil, err
+	}
+
+	found = schemas.InterestCategory{Name: name, Position: last + 1}
+	if err := db.Create(&found).Error; err != nil {
+		return nil, err
+	}
+
+	return &found, nil
+}
+
+// replace swaps a profile's interest links for picked and mirrors their names into Profile.Interests
+func replace(profileID uint, picked []schemas.Interest, tx *gorm.DB) error {
+	if err := DeleteAll(profileID, tx); err != nil {
+		return err
+	}
+
+	if len(picked) > 0 {
+		links := make([]schemas.ProfileInterest, 0, len(picked))
+		for _, interest := range picked {
+			links = append(links, schemas.ProfileInterest{ProfileID: profileID, InterestID: interest.ID})
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+			return err
+		}
+	}
+
+	return syncNames(profileID, tx)
+}
+
+func syncNames(profileID uint, tx *gorm.DB) error {
+	current, err := List(profileID, tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&schemas.Profile{}).Where("user_id = ?", profileID).Update("interests", Names(current)).Error
+}
@@ -71,6 +71,49 @@ func CreateDuoMatchWithStandout(profileID uint, friendProfileID uint, targetProf
 	return nil
 }
 
+// CreateDuoPairMatch creates a duo match where the liker picked both targets up front,
+// so the friend doesn't need to choose the second target with update_target
+func CreateDuoPairMatch(profileID uint, friendProfileID uint, targetProfileID uint, targetFriendProfileID uint, db *gorm.DB) (*schemas.Matches, error) {
+	ids := map[uint]bool{profileID: true, friendProfileID: true, targetProfileID: true, targetFriendProfileID: true}
+	if len(ids) != 4 {
+		return nil, errors.New("a duo match needs four different profiles")
+	}
+
+	for _, pair := range [][2]uint{{profileID, friendProfileID}, {targetProfileID, targetFriendProfileID}} {
+		validFriendship, err := friendship.VerifyFriendship(pair[0], pair[1], db)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !validFriendship {
+			return nil, errors.New("the provided profiles are not friends")
+		}
+	}
+
+	var existingMatch schemas.Matches
+	db.Where("is_duo = ? AND ((profile1_id = ? AND profile2_id = ?) OR (profile1_id = ? AND profile2_id = ?)) AND ((profile3_id = ? AND profile4_id = ?) OR (profile3_id = ? AND profile4_id = ?))",
+		true, profileID, friendProfileID, friendProfileID, profileID, targetProfileID, targetFriendProfileID, targetFriendProfileID, targetProfileID).First(&existingMatch)
+	if existingMatch.ID != 0 {
+		return nil, errors.New("a duo match already exists with the given profile combination")
+	}
+
+	newMatch := schemas.Matches{
+		Profile1ID: profileID,
+		Profile2ID: &friendProfileID,
+		Profile3ID: targetProfileID,
+		Profile4ID: &targetFriendProfileID,
+		IsDuo:      true,
+		Status:     "pending",
+	}
+
+	if err := db.Create(&newMatch).Error; err != nil {
+		return nil, err
+	}
+
+	return GetMatchByID(newMatch.ID, db)
+}
+
 func CreateFriendMatch(profileID uint, friendProfileID uint, db *gorm.DB) (*schemas.Matches, error) {
 	validFriendship, err := friendship.VerifyFriendship(profileID, friendProfileID, db)
 
@@ -315,6 +358,7 @@ func GetPendingMatches(profileId uint, db *gorm.DB) ([]schemas.Matches, error) {
 		Preload("Profile2").
 		Preload("Profile3").
 		Preload("Profile4").
+		Preload("PromptAnswer.Prompt").
 		Find(&pendingMatches).Error
 
 	if err != nil {
@@ -327,7 +371,7 @@ func GetPendingMatches(profileId uint, db *gorm.DB) ([]schemas.Matches, error) {
 func GetAcceptedMatches(profileId uint, db *gorm.DB) ([]schemas.Matches, error) {
 	var pendingMatches []schemas.Matches
 
-	err := db.Preload("Profile1").Preload("Profile2").Preload("Profile3").Preload("Profile4").
+	err := db.Preload("Profile1").Preload("Profile2").Preload("Profile3").Preload("Profile4").Preload("PromptAnswer.Prompt").
 		Where("status = 'accepted' AND (profile1_id = ? OR profile2_id = ? OR profile3_id = ? OR profile4_id = ?)", profileId, profileId, profileId, profileId).
 		Order("updated_at desc").
 		Find(&pendingMatches).Error
@@ -345,7 +389,7 @@ func GetAllMatches(profileId uint, db *gorm.DB) ([]schemas.Matches, error) {
 	err := db.
 		Where("(profile1_id = ? OR profile2_id = ? OR profile3_id = ? OR profile4_id = ?)", profileId, profileId, profileId, profileId).
 		Order("created_at desc").
-		Preload("Profile1").Preload("Profile2").Preload("Profile3").Preload("Profile4").
+		Preload("Profile1").Preload("Profile2").Preload("Profile3").Preload("Profile4").Preload("PromptAnswer.Prompt").
 		Find(&pendingMatches).Error
 
 	if err != nil {
@@ -359,7 +403,7 @@ func GetMatchByID(matchId uint, db *gorm.DB) (*schemas.Matches, error) {
 
 	var match schemas.Matches
 
-	err := db.Preload("Profile1").Preload("Profile2").Preload("Profile3").Preload("Profile4").
+	err := db.Preload("Profile1").Preload("Profile2").Preload("Profile3").Preload("Profile4").Preload("PromptAnswer.Prompt").
 		Where(schemas.Matches{ID: matchId}).
 		First(&match).Error
 
@@ -381,6 +425,7 @@ func GetSoloMatchByProfileIDs(profileID uint, targetProfileID uint, db *gorm.DB)
 	err := db.
 		Preload("Profile1").
 		Preload("Profile3").
+		Preload("PromptAnswer.Prompt").
 		Where("is_duo = ? AND ((profile1_id = ? AND profile3_id = ?) OR (profile1_id = ? AND profile3_id = ?))",
 			false, profileID, targetProfileID, targetProfileID, profileID).First(&match).Error
 
@@ -399,6 +444,7 @@ func GetDuoMatchByProfileIDs(profileID uint, friendProfileID uint, targetProfile
 		Preload("Profile2").
 		Preload("Profile3").
 		Preload("Profile4").
+		Preload("PromptAnswer.Prompt").
 		Where("is_duo = ? AND ((profile1_id = ? AND profile2_id = ? AND profile3_id = ?) OR (profile2_id = ? AND profile1_id = ? AND profile3_id = ?))",
 			true, profileID, friendProfileID, targetProfileID, profileID, friendProfileID, targetProfileID).First(&match).Error
 
@@ -506,6 +552,10 @@ func DeleteAllProfileMatches(profileId uint, db *gorm.DB) error {
 			return err
 		}
 
+		if err := deleteMatchDatePlans(match.ID, db); err != nil {
+			return err
+		}
+
 		if err := db.Delete(&match).Error; err != nil {
 			return err
 		}
@@ -514,6 +564,16 @@ func DeleteAllProfileMatches(profileId uint, db *gorm.DB) error {
 	return nil
 }
 
+func deleteMatchDatePlans(matchId uint, db *gorm.DB) error {
+	datePlanIDs := db.Model(&schemas.DatePlan{}).Select("id").Where("match_id = ?", matchId)
+
+	if err := db.Where("date_plan_id IN (?)", datePlanIDs).Delete(&schemas.DateRSVP{}).Error; err != nil {
+		return err
+	}
+
+	return db.Where("match_id = ?", matchId).Delete(&schemas.DatePlan{}).Error
+}
+
 func GetFriendshipMatch(profileId uint, friendId uint, db *gorm.DB) (*schemas.Matches, error) {
 	var match schemas.Matches
 
@@ -541,3 +601,39 @@ func DeleteMatch(matchID uint, db *gorm.DB) error {
 	// Commit the transaction
 	return tx.Commit().Error
 }
+
+// SetPromptAnswer records the prompt answer a like was sent on, so the match opens with it as context
+func SetPromptAnswer(match *schemas.Matches, answer *schemas.PromptAnswer, db *gorm.DB) error {
+	if err := db.Model(match).Update("prompt_answer_id", answer.ID).Error; err != nil {
+		return err
+	}
+
+	match.PromptAnswerID = &answer.ID
+	match.PromptAnswer = answer
+	return nil
+}
+
+// GetParticipantIDs returns the profile IDs of everyone in a match, skipping unset duo slots
+func GetParticipantIDs(match schemas.Matches) []uint {
+	participantIDs := []uint{match.Profile1ID}
+	if match.Profile2ID != nil {
+		participantIDs = append(participantIDs, *match.Profile2ID)
+	}
+	participantIDs = append(participantIDs, match.Profile3ID)
+	if match.Profile4ID != nil {
+		participantIDs = append(participantIDs, *match.Profile4ID)
+	}
+
+	return participantIDs
+}
+
+// IsMatchParticipant reports whether the profile is one of the match participants
+func IsMatchParticipant(match schemas.Matches, profileID uint) bool {
+	for _, participantID := range GetParticipantIDs(match) {
+		if participantID == profileID {
+			return true
+		}
+	}
+
+	return false
+}
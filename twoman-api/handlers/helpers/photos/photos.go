@@ -0,0 +1,572 @@
+package photos
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"twoman/handlers/helpers/file"
+	"twoman/schemas"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultMaxPhotos is used when MAX_PROFILE_PHOTOS isn't set
+	DefaultMaxPhotos = 6
+	// MaxCaptionLength caps how long a photo caption can be
+	MaxCaptionLength = 100
+	// legacyImageSlots is how many photos are mirrored into Profile.Image1..Image4
+	legacyImageSlots = 4
+)
+
+var (
+	ErrTooManyPhotos  = errors.New("profile has too many photos")
+	ErrPhotoNotFound  = errors.New("photo not found")
+	ErrLastPhoto      = errors.New("profile must have at least one photo")
+	ErrFileNotOwned   = errors.New("file was not uploaded by this user")
+	ErrInvalidOrder   = errors.New("order must contain every photo exactly once")
+	ErrInvalidStatus  = errors.New("invalid moderation status")
+	ErrNoVisiblePhoto = errors.New("profile must have an image")
+	ErrInvalidPhoto   = errors.New("file is not an image")
+	ErrDuplicatePhoto = errors.New("photo is already in the gallery")
+)
+
+// MaxPhotos is how many photos a profile can have, configured with MAX_PROFILE_PHOTOS
+func MaxPhotos() int {
+	max, err := strconv.Atoi(os.Getenv("MAX_PROFILE_PHOTOS"))
+	if err != nil || max < 1 {
+		return DefaultMaxPhotos
+	}
+	return max
+}
+
+// List returns a profile's photos in order. Rejected photos are only included for the owner.
+func List(profileID uint, includeRejected bool, db *gorm.DB) ([]schemas.ProfilePhoto, error) {
+	query := db.Where("profile_id = ?", profileID)
+	if !includeRejected {
+		query = query.Where("moderation_status != ?", schemas.PhotoModerationRejected)
+	}
+
+	photos := []schemas.ProfilePhoto{}
+	if err := query.Order("position").Find(&photos).Error; err != nil {
+		return nil, fmt.Errorf("error loading profile photos: %w", err)
+	}
+
+	return photos, nil
+}
+
+// Attach fills Photos on each profile with its photos that haven't been rejected, using a single query
+func Attach(profiles []*schemas.Profile, db *gorm.DB) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(profiles))
+	for _, profile := range profiles {
+		ids = append(ids, profile.UserID)
+	}
+
+	var all []schemas.ProfilePhoto
+	if err := db.Where("profile_id IN ? AND moderation_status != ?", ids, schemas.PhotoModerationRejected).
+		Order("profile_id, position").Find(&all).Error; err != nil {
+		return fmt.Errorf("error loading profile photos: %w", err)
+	}
+
+	byProfile := make(map[uint][]schemas.ProfilePhoto, len(profiles))
+	for _, photo := range all {
+		byProfile[photo.ProfileID] = append(byProfile[photo.ProfileID], photo)
+	}
+
+	for _, profile := range profiles {
+		profile.Photos = byProfile[profile.UserID]
+	}
+
+	return nil
+}
+
+// Add appends a photo the user has already uploaded to the end of their gallery
+func Add(profileID uint, url string, caption string, db *gorm.DB) (*schemas.ProfilePhoto, error) {
+	filename := FilenameFromURL(url)
+
+	if err := checkImageFile(profileID, filename, db); err != nil {
+		return nil, err
+	}
+
+	var photo schemas.ProfilePhoto
+	err := db.Transaction(func(tx *gorm.DB) error {
+		existing, err := List(profileID, true, tx)
+		if err != nil {
+			return err
+		}
+		if len(existing) >= MaxPhotos() {
+			return ErrTooManyPhotos
+		}
+		for _, photo := range existing {
+			if photo.Filename == filename {
+				return ErrDuplicatePhoto
+			}
+		}
+
+		photo = schemas.ProfilePhoto{
+			ProfileID:        profileID,
+			URL:              url,
+			Filename:         filename,
+			Position:         len(existing),
+			Caption:          caption,
+			IsPrimary:        len(existing) == 0,
+			ModerationStatus: schemas.PhotoModerationPending,
+		}
+		if err := setRenditions(&photo, tx); err != nil {
+			return err
+		}
+		if err := tx.Create(&photo).Error; err != nil {
+			return err
+		}
+
+		if err := resetVerification(profileID, tx); err != nil {
+			return err
+		}
+
+		return sync(profileID, true, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &photo, nil
+}
+
+// Remove deletes a photo and its file. The next photo becomes primary when the primary one is removed.
+func Remove(profileID uint, photoID uint, db *gorm.DB, s3Client *s3.S3) error {
+	var removed schemas.ProfilePhoto
+	err := db.Transaction(func(tx *gorm.DB) error {
+		existing, err := List(profileID, true, tx)
+		if err != nil {
+			return err
+		}
+
+		remaining := make([]schemas.ProfilePhoto, 0, len(existing))
+		for _, photo := range existing {
+			if photo.ID == photoID {
+				removed = photo
+				continue
+			}
+			remaining = append(remaining, photo)
+		}
+
+		if removed.ID == 0 {
+			return ErrPhotoNotFound
+		}
+		if len(remaining) == 0 {
+			return ErrLastPhoto
+		}
+
+		if err := tx.Delete(&removed).Error; err != nil {
+			return err
+		}
+
+		if err := saveOrder(remaining, tx); err != nil {
+			return err
+		}
+
+		if removed.ModerationStatus != schemas.PhotoModerationRejected {
+			if err := resetVerification(profileID, tx); err != nil {
+				return err
+			}
+		}
+
+		return sync(profileID, true, tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	deleteFile(profileID, removed.Filename, db, s3Client)
+	return nil
+}
+
+// Reorder puts the gallery in the order of photoIDs. The first photo becomes the primary one.
+func Reorder(profileID uint, photoIDs []uint, db *gorm.DB) ([]schemas.ProfilePhoto, error) {
+	var ordered []schemas.ProfilePhoto
+	err := db.Transaction(func(tx *gorm.DB) error {
+		existing, err := List(profileID, true, tx)
+		if err != nil {
+			return err
+		}
+		if len(photoIDs) != len(existing) {
+			return ErrInvalidOrder
+		}
+
+		byID := make(map[uint]schemas.ProfilePhoto, len(existing))
+		for _, photo := range existing {
+			byID[photo.ID] = photo
+		}
+
+		ordered = make([]schemas.ProfilePhoto, 0, len(photoIDs))
+		for _, id := range photoIDs {
+			photo, ok := byID[id]
+			if !ok {
+				return ErrInvalidOrder
+			}
+			delete(byID, id)
+			ordered = append(ordered, photo)
+		}
+
+		if err := saveOrder(ordered, tx); err != nil {
+			return err
+		}
+
+		return sync(profileID, true, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ordered, nil
+}
+
+// Update changes a photo's caption and, when makePrimary is set, moves it to the front of the gallery
+func Update(profileID uint, photoID uint, caption *string, makePrimary bool, db *gorm.DB) (*schemas.ProfilePhoto, error) {
+	var updated schemas.ProfilePhoto
+	err := db.Transaction(func(tx *gorm.DB) error {
+		existing, err := List(profileID, true, tx)
+		if err != nil {
+			return err
+		}
+
+		index := -1
+		for i, photo := range existing {
+			if photo.ID == photoID {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			return ErrPhotoNotFound
+		}
+
+		if caption != nil {
+			existing[index].Caption = *caption
+			if err := tx.Model(&existing[index]).Update("caption", *caption).Error; err != nil {
+				return err
+			}
+		}
+
+		if makePrimary && index != 0 {
+			photo := existing[index]
+			copy(existing[1:index+1], existing[:index])
+			existing[0] = photo
+			index = 0
+
+			if err := saveOrder(existing, tx); err != nil {
+				return err
+			}
+		}
+
+		updated = existing[index]
+		return sync(profileID, true, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// SetModerationStatus records a moderator's decision on a photo. Rejected photos are hidden from other users.
+func SetModerationStatus(photoID uint, status string, db *gorm.DB) (*schemas.ProfilePhoto, error) {
+	if status != schemas.PhotoModerationPending && status != schemas.PhotoModerationApproved && status != schemas.PhotoModerationRejected {
+		return nil, ErrInvalidStatus
+	}
+
+	var photo schemas.ProfilePhoto
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&photo, photoID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPhotoNotFound
+			}
+			return err
+		}
+
+		photo.ModerationStatus = status
+		if err := tx.Model(&photo).Update("moderation_status", status).Error; err != nil {
+			return err
+		}
+
+		return sync(photo.ProfileID, false, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &photo, nil
+}
+
+// SetImages applies an Image1..Image4 update from a client that predates the gallery. The images replace the
+// photos mirrored in those columns, in order; photos past the fourth and rejected photos are kept after them. New
+// images must have been uploaded by the user. It returns the files of the replaced photos, which the caller removes
+// with DeleteFiles once its transaction has committed.
+func SetImages(profileID uint, images []string, db *gorm.DB) ([]string, error) {
+	return setImages(profileID, images, false, db)
+}
+
+// AdminSetImages is SetImages for admins, who can point a profile at images they didn't upload as that user
+func AdminSetImages(profileID uint, images []string, db *gorm.DB) ([]string, error) {
+	return setImages(profileID, images, true, db)
+}
+
+// DeleteFiles removes the files SetImages replaced. Files the profile didn't upload are left alone.
+func DeleteFiles(profileID uint, filenames []string, db *gorm.DB, s3Client *s3.S3) {
+	for _, filename := range filenames {
+		deleteFile(profileID, filename, db, s3Client)
+	}
+}
+
+func setImages(profileID uint, images []string, byAdmin bool, db *gorm.DB) ([]string, error) {
+	// The same file can be reached through different URLs, so images are told apart by filename
+	urls := []string{}
+	added := []string{}
+	for _, image := range images {
+		if image != "" && !slices.Contains(added, FilenameFromURL(image)) {
+			urls = append(urls, image)
+			added = append(added, FilenameFromURL(image))
+		}
+	}
+	if len(urls) == 0 {
+		return nil, ErrNoVisiblePhoto
+	}
+
+	var removed []schemas.ProfilePhoto
+	err := db.Transaction(func(tx *gorm.DB) error {
+		existing, err := List(profileID, true, tx)
+		if err != nil {
+			return err
+		}
+
+		byFilename := make(map[string]schemas.ProfilePhoto, len(existing))
+		mirrored := make(map[uint]bool)
+		for _, photo := range existing {
+			byFilename[photo.Filename] = photo
+			if photo.ModerationStatus != schemas.PhotoModerationRejected && len(mirrored) < legacyImageSlots {
+				mirrored[photo.ID] = true
+			}
+		}
+
+		ordered := make([]schemas.ProfilePhoto, 0, len(existing)+len(urls))
+		kept := make(map[uint]bool)
+		for _, url := range urls {
+			if photo, ok := byFilename[FilenameFromURL(url)]; ok {
+				ordered = append(ordered, photo)
+				kept[photo.ID] = true
+				continue
+			}
+
+			if !byAdmin {
+				if err := checkImageFile(profileID, FilenameFromURL(url), tx); err != nil {
+					return err
+				}
+			}
+
+			photo := schemas.ProfilePhoto{
+				ProfileID:        profileID,
+				URL:              url,
+				Filename:         FilenameFromURL(url),
+				ModerationStatus: schemas.PhotoModerationPending,
+			}
+			if err := setRenditions(&photo, tx); err != nil {
+				return err
+			}
+			if err := tx.Create(&photo).Error; err != nil {
+				return err
+			}
+			ordered = append(ordered, photo)
+		}
+
+		for _, photo := range existing {
+			if kept[photo.ID] {
+				continue
+			}
+			if mirrored[photo.ID] {
+				removed = append(removed, photo)
+				continue
+			}
+			ordered = append(ordered, photo)
+		}
+
+		if len(ordered) > MaxPhotos() {
+			return ErrTooManyPhotos
+		}
+
+		for _, photo := range removed {
+			if err := tx.Delete(&photo).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := saveOrder(ordered, tx); err != nil {
+			return err
+		}
+
+		// New photos are pending and removed ones were mirrored, so both change what other users see
+		if len(removed) > 0 || len(kept) != len(urls) {
+			if err := resetVerification(profileID, tx); err != nil {
+				return err
+			}
+		}
+
+		changed := len(removed) > 0 || len(kept) != len(urls)
+		for i, photo := range existing {
+			if i >= len(ordered) || ordered[i].ID != photo.ID {
+				changed = true
+				break
+			}
+		}
+
+		return sync(profileID, changed, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	filenames := make([]string, 0, len(removed))
+	for _, photo := range removed {
+		filenames = append(filenames, photo.Filename)
+	}
+
+	return filenames, nil
+}
+
+// DeleteAll removes a profile's photo rows. Their files are removed with the rest of the user's files.
+func DeleteAll(profileID uint, db *gorm.DB) error {
+	return db.Where("profile_id = ?", profileID).Delete(&schemas.ProfilePhoto{}).Error
+}
+
+// FilenameFromURL returns the stored file name of an uploaded file's public URL
+func FilenameFromURL(url string) string {
+	parts := strings.Split(url, "/")
+	return parts[len(parts)-1]
+}
+
+// setRenditions fills the smaller rendition URLs and placeholder from the upload's metadata. Images uploaded
+// before renditions existed only have their full size URL.
+func setRenditions(photo *schemas.ProfilePhoto, db *gorm.DB) error {
+	var metadata schemas.FileMetadata
+	if err := db.Where("filename = ?", photo.Filename).Limit(1).Find(&metadata).Error; err != nil {
+		return err
+	}
+
+	photo.Blurhash = metadata.Blurhash
+	if metadata.Renditions != "" {
+		photo.ThumbnailURL = file.RenditionURL(photo.URL, "thumbnail")
+		photo.MediumURL = file.RenditionURL(photo.URL, "medium")
+	}
+
+	return nil
+}
+
+// saveOrder writes positions in slice order and makes the first photo primary
+func saveOrder(photos []schemas.ProfilePhoto, tx *gorm.DB) error {
+	for i := range photos {
+		if photos[i].Position == i && photos[i].IsPrimary == (i == 0) {
+			continue
+		}
+
+		photos[i].Position = i
+		photos[i].IsPrimary = i == 0
+		if err := tx.Model(&photos[i]).Updates(map[string]interface{}{
+			"position":   i,
+			"is_primary": i == 0,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sync mirrors the first photos that aren't rejected into Image1..Image4. contentChanged marks the profile as
+// updated so passed profiles can come back sooner.
+func sync(profileID uint, contentChanged bool, tx *gorm.DB) error {
+	visible, err := List(profileID, false, tx)
+	if err != nil {
+		return err
+	}
+
+	images := make([]string, legacyImageSlots)
+	for i := 0; i < len(visible) && i < legacyImageSlots; i++ {
+		images[i] = visible[i].URL
+	}
+
+	updates := map[string]interface{}{
+		"image1": images[0],
+		"image2": images[1],
+		"image3": images[2],
+		"image4": images[3],
+	}
+	if contentChanged {
+		updates["content_updated_at"] = time.Now()
+	}
+
+	return tx.Model(&schemas.Profile{}).Where("user_id = ?", profileID).Updates(updates).Error
+}
+
+// resetVerification clears the verified badge when the photos other users see change, since the selfie was only
+// compared against the old ones. The user can verify again with a new selfie.
+func resetVerification(profileID uint, tx *gorm.DB) error {
+	if err := tx.Model(&schemas.Profile{}).Where("user_id = ? AND verified = ?", profileID, true).Update("verified", false).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&schemas.User{}).Where("id = ? AND verified = ?", profileID, true).Update("verified", false).Error
+}
+
+// ownsFile reports whether the user uploaded the file stored as filename
+func ownsFile(profileID uint, filename string, db *gorm.DB) (bool, error) {
+	var owned int64
+	if err := db.Model(&schemas.FileMetadata{}).Where("filename = ? AND user_id = ?", filename, profileID).Count(&owned).Error; err != nil {
+		return false, err
+	}
+	return owned > 0, nil
+}
+
+// checkImageFile makes sure the profile uploaded filename and that it is an image. Images uploaded before audio was
+// supported have no content type.
+func checkImageFile(profileID uint, filename string, db *gorm.DB) error {
+	var metadata schemas.FileMetadata
+	if err := db.Where("filename = ? AND user_id = ?", filename, profileID).Limit(1).Find(&metadata).Error; err != nil {
+		return err
+	}
+	if metadata.ID == 0 {
+		return ErrFileNotOwned
+	}
+	if metadata.ContentType != "" && !strings.HasPrefix(metadata.ContentType, "image/") {
+		return ErrInvalidPhoto
+	}
+	return nil
+}
+
+// deleteFile removes a photo's file if the profile uploaded it. Admins can point photos at other users' files,
+// which must not be deleted with the photo.
+func deleteFile(profileID uint, filename string, db *gorm.DB, s3Client *s3.S3) {
+	if filename == "" {
+		return
+	}
+
+	owned, err := ownsFile(profileID, filename, db)
+	if err != nil {
+		log.Printf("Warning: failed to check owner of old image %s: %v", filename, err)
+		return
+	}
+	if !owned {
+		return
+	}
+	if err := file.DeleteFileByName(filename, db, s3Client); err != nil {
+		log.Printf("Warning: failed to delete old image %s: %v", filename, err)
+	}
+}
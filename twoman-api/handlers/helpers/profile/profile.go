@@ -1,36 +1,38 @@
 package profile
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"log"
-	"strings"
 	"time"
+	"twoman/handlers/helpers/attributes"
+	"twoman/handlers/helpers/birthdate"
+	"twoman/handlers/helpers/discovery"
+	"twoman/handlers/helpers/duplicates"
 	"twoman/handlers/helpers/file"
 	"twoman/handlers/helpers/friendship"
+	"twoman/handlers/helpers/geocoder"
+	"twoman/handlers/helpers/interests"
 	"twoman/handlers/helpers/matches"
 	"twoman/handlers/helpers/notifications"
+	"twoman/handlers/helpers/photos"
+	"twoman/handlers/helpers/prompts"
+	"twoman/handlers/helpers/seen"
+	"twoman/handlers/helpers/usernames"
+	"twoman/handlers/helpers/verification"
 	"twoman/schemas"
 	"twoman/types"
-	"twoman/utils"
 
 	"github.com/aws/aws-sdk-go/service/s3"
-	"googlemaps.github.io/maps"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func CheckUsernameAvailability(username string, db *gorm.DB) (bool, error) {
-	var user schemas.Profile
-	err := db.Where("username = ?", username).First(&user).Error
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return true, nil
-		}
-		return false, err
-	}
-	return false, nil
-
+// CheckUsernameAvailability reports whether a user can take a username. Usernames other profiles gave up recently
+// are still reserved for them.
+func CheckUsernameAvailability(username string, userId uint, db *gorm.DB) (bool, error) {
+	return usernames.Available(username, userId, db)
 }
 
 func CreateProfile(userId uint, db *gorm.DB) error {
@@ -56,9 +58,6 @@ func UpdateProfile(request types.UpdateProfileRequest, userID uint, db *gorm.DB,
 	if request.Name == "" {
 		return errors.New("profile must have a name")
 	}
-	if request.Image1 == "" {
-		return errors.New("profile must have an image")
-	}
 	if request.Gender != "male" && request.Gender != "female" {
 		return errors.New("gender is not valid")
 	}
@@ -68,64 +67,56 @@ func UpdateProfile(request types.UpdateProfileRequest, userID uint, db *gorm.DB,
 		return err
 	}
 
-	// Get all old images
-	oldImages := []string{oldProfile.Image1, oldProfile.Image2, oldProfile.Image3, oldProfile.Image4}
-
-	// Get new images and compact them (shift forward to fill empty slots)
-	newImagesRaw := []string{request.Image1, request.Image2, request.Image3, request.Image4}
-	newImages := utils.CompactImages(newImagesRaw)
-
-	// Update the request with the compacted images
-	request.Image1 = newImages[0]
-	request.Image2 = newImages[1]
-	request.Image3 = newImages[2]
-	request.Image4 = newImages[3]
-
-	// Find images to delete (as in current code)
-	for _, oldURL := range oldImages {
-		if oldURL == "" {
-			continue
-		}
-
-		found := false
-		for _, newURL := range newImages {
-			if oldURL == newURL {
-				found = true
-				break
-			}
-		}
-
-		if !found {
-			// This image is no longer used, delete it
-			oldFileName := getFileNameFromURL(oldURL)
-			if err := file.DeleteFileByName(oldFileName, db, s3); err != nil {
-				log.Printf("Warning: failed to delete old image %s: %v", oldFileName, err)
-			}
-		}
-	}
-
-	// Update profile (same as current code)
-	return db.Model(&schemas.Profile{}).Where("user_id = ?", userID).Updates(map[string]interface{}{
+	updates := map[string]interface{}{
 		"name":                   request.Name,
 		"bio":                    request.Bio,
-		"image1":                 request.Image1,
-		"image2":                 request.Image2,
-		"image3":                 request.Image3,
-		"image4":                 request.Image4,
 		"gender":                 request.Gender,
 		"education":              request.Education,
 		"occupation":             request.Occupation,
-		"interests":              request.Interests,
 		"preferred_gender":       request.PreferredGender,
 		"preferred_age_min":      request.PreferredAgeMin,
 		"preferred_age_max":      request.PreferredAgeMax,
 		"preferred_distance_max": request.PreferredDistanceMax,
-	}).Error
-}
+	}
+
+	// Passed profiles come back sooner once their bio changes, photos are handled by the gallery
+	if request.Bio != oldProfile.Bio {
+		updates["content_updated_at"] = time.Now()
+	}
 
-func getFileNameFromURL(url string) string {
-	parts := strings.Split(url, "/")
-	return parts[len(parts)-1]
+	var replaced []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&schemas.Profile{}).Where("user_id = ?", userID).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		// Clients that predate the catalog send interests as text, which is matched against the catalog
+		if !interests.SameNames(request.Interests, oldProfile.Interests) {
+			if _, err := interests.SetFromNames(userID, request.Interests, tx); err != nil {
+				return err
+			}
+		}
+
+		// Attribute values are checked against their definitions, so an invalid one rolls back the whole update
+		if err := attributes.Set(userID, request.Attributes, tx); err != nil {
+			return err
+		}
+
+		// Image1..Image4 only come from clients that predate the photo gallery
+		if request.Image1 == "" {
+			return nil
+		}
+
+		var err error
+		replaced, err = photos.SetImages(userID, []string{request.Image1, request.Image2, request.Image3, request.Image4}, tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	photos.DeleteFiles(userID, replaced, db, s3)
+	return nil
 }
 
 func GetProfileById(profileId uint, db *gorm.DB) (*schemas.Profile, error) {
@@ -141,154 +132,62 @@ func GetProfileById(profileId uint, db *gorm.DB) (*schemas.Profile, error) {
 	return profile, nil
 }
 
-// func DiscoverNewProfile(userProfile schemas.Profile, db *gorm.DB) (schemas.Profile, error) {
-// 	var profile schemas.Profile
-
-// 	// Subquery for blocked profiles
-// 	blockedSubquery := db.Table("blocks").
-// 		Select("CASE WHEN profile_id = ? THEN blocked_profile_id ELSE profile_id END", userProfile.UserID).
-// 		Where("profile_id = ? OR blocked_profile_id = ?", userProfile.UserID, userProfile.UserID)
-
-// 	// Main query
-// 	query := db.Where("user_id != ?", userProfile.UserID).
-// 		Where("user_id NOT IN (?)",
-// 			db.Table("profile_views").
-// 				Select("profile_id").
-// 				Where("user_id = ?", userProfile.UserID)).
-// 		Where("user_id NOT IN (?)", blockedSubquery)
-
-// 	// Filter by Preferred Gender
-// 	if userProfile.PreferredGender != "" {
-// 		query = query.Where("gender = ?", userProfile.PreferredGender)
-// 	}
-
-// 	// Filter by Preferred Age Range
-// 	if userProfile.PreferredAgeMin > 0 && userProfile.PreferredAgeMax > 0 {
-// 		query = query.Where(`
-//             (YEAR(CURDATE()) - YEAR(date_of_birth))
-//              - (DATE_FORMAT(CURDATE(), '%m%d') < DATE_FORMAT(date_of_birth, '%m%d')) >= ?
-//             AND
-//             (YEAR(CURDATE()) - YEAR(date_of_birth))
-//              - (DATE_FORMAT(CURDATE(), '%m%d') < DATE_FORMAT(date_of_birth, '%m%d')) <= ?
-//         `,
-// 			userProfile.PreferredAgeMin,
-// 			userProfile.PreferredAgeMax,
-// 		)
-// 	}
-
-// 	// --- Prioritizing by distance (without filtering) ---
-// 	// Make sure the user actually has a valid location before calling ST_Distance_Sphere.
-
-// 	if userProfile.LocationPoint.Valid() && userProfile.LocationPoint.Point != nil {
-// 		query = query.Order(
-// 			gorm.Expr(
-// 				"ST_Distance_Sphere(location_point, ST_GeomFromText(?)) ASC, RAND()",
-// 				userProfile.LocationPoint.String(),
-// 			),
-// 		)
-// 	} else {
-// 		query = query.Order("RAND()")
-// 	}
-
-// 	// Limit to 1 to get just the top-priority record
-// 	err := query.Limit(1).First(&profile).Error
-// 	if err != nil {
-// 		if errors.Is(err, gorm.ErrRecordNotFound) {
-// 			return schemas.Profile{}, fmt.Errorf("no matching profiles found")
-// 		}
-// 		return schemas.Profile{}, fmt.Errorf("error executing query: %w", err)
-// 	}
-
-// 	fmt.Printf("Fetched profile ID: %d\n", profile.UserID)
-// 	return profile, nil
-// }
-
-func DiscoverNewProfile(userProfile schemas.Profile, db *gorm.DB) (schemas.Profile, error) {
-	var profile schemas.Profile
-
-	log.Println("Profile Latitude: ", userProfile.LocationPoint.Point.Y(), " Longitude: ", userProfile.LocationPoint.Point.X())
-	log.Println("Profile Point Valid: ", userProfile.LocationPoint.Valid())
-
-	// Subquery to get the IDs of profiles that are blocked or have blocked the user
-	blockedSubquery := db.Table("blocks").
-		Select("CASE WHEN profile_id = ? THEN blocked_profile_id ELSE profile_id END", userProfile.UserID).
-		Where("profile_id = ? OR blocked_profile_id = ?", userProfile.UserID, userProfile.UserID)
-
-	query := db.Where("user_id != ?", userProfile.UserID).
-		Where("user_id NOT IN (?)",
-			db.Table("profile_views").
-				Select("profile_id").
-				Where("user_id = ?", userProfile.UserID)).
-		Where("user_id NOT IN (?)", blockedSubquery)
+// DiscoverNewProfile returns the highest ranked candidate from the discovery pipeline
+func DiscoverNewProfile(userProfile schemas.Profile, db *gorm.DB, rdb *redis.Client) (schemas.Profile, error) {
+	ranked, err := discovery.Discover(userProfile, 1, db, rdb)
 
-	if userProfile.PreferredGender != "" {
-		query = query.Where("gender = ?", userProfile.PreferredGender)
+	if err != nil {
+		return schemas.Profile{}, err
 	}
 
-	if userProfile.PreferredAgeMin > 0 && userProfile.PreferredAgeMax > 0 {
-		query = query.Where("(YEAR(CURDATE()) - YEAR(date_of_birth)) - (DATE_FORMAT(CURDATE(), '%m%d') < DATE_FORMAT(date_of_birth, '%m%d')) >= ? AND "+
-			"(YEAR(CURDATE()) - YEAR(date_of_birth)) - (DATE_FORMAT(CURDATE(), '%m%d') < DATE_FORMAT(date_of_birth, '%m%d')) <= ?",
-			userProfile.PreferredAgeMin, userProfile.PreferredAgeMax)
+	if len(ranked) == 0 {
+		return schemas.Profile{}, fmt.Errorf("no matching profiles found")
 	}
 
-	if userProfile.PreferredDistanceMax > 0 {
-		if userProfile.LocationPoint.Point == nil {
-			return schemas.Profile{}, fmt.Errorf("invalid user location point")
-		}
+	log.Printf("Fetched profile ID: %d (score %.3f)\n", ranked[0].Profile.UserID, ranked[0].Score)
 
-		query = query.Where("ST_Distance_Sphere(location_point, ST_GeomFromText(?)) <= ?",
-			userProfile.LocationPoint.String(),
-			userProfile.PreferredDistanceMax*1000*4) // TODO: Remove this *4 multiplier (The 1000 is for meters, the 4 is for the distance multiplier)
-	}
-
-	query = query.Order("RAND()").Limit(1)
-
-	err := query.First(&profile).Error
-
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return schemas.Profile{}, fmt.Errorf("no matching profiles found")
-		}
-		return schemas.Profile{}, fmt.Errorf("error executing query: %w", err)
-	}
-
-	fmt.Printf("Fetched profile ID: %d\n", profile.UserID)
+	return ranked[0].Profile, nil
+}
 
-	return profile, nil
+// RecordLike counts a like userID sent. Likes are kept as matches, so only the count is stored.
+func RecordLike(userID uint, db *gorm.DB) error {
+	return incrementSwipeStats(schemas.SwipeStats{UserID: userID, Likes: 1}, "likes", db)
 }
 
-func CreateProfileView(userID uint, targetProfileID uint, db *gorm.DB) error {
-	// Check if the profiles exist
-	var userProfile, targetProfile schemas.Profile
-	if err := db.First(&userProfile, userID).Error; err != nil {
-		return fmt.Errorf("user profile not found: %w", err)
+// RecordPass records that userID passed on targetProfileID. Passing on the same profile again after it was
+// recycled moves the pass forward without counting it twice.
+func RecordPass(userID uint, targetProfileID uint, db *gorm.DB) error {
+	var target schemas.Profile
+	if err := db.Select("user_id").Where("user_id = ?", targetProfileID).Limit(1).Find(&target).Error; err != nil {
+		return fmt.Errorf("error loading target profile: %w", err)
 	}
-	if err := db.First(&targetProfile, targetProfileID).Error; err != nil {
-		return fmt.Errorf("target profile not found: %w", err)
+	if target.UserID == 0 {
+		return errors.New("target profile not found")
 	}
 
-	// Check if a view already exists
-	var existingView schemas.ProfileView
-	err := db.Where("user_id = ? AND profile_id = ?", userID, targetProfileID).First(&existingView).Error
-
-	if err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("error checking for existing profile view: %w", err)
-		}
-		// If no view exists, create a new one
-		newView := schemas.ProfileView{
-			UserID:    userID,
-			ProfileID: targetProfileID,
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{
+			DoUpdates: clause.AssignmentColumns([]string{"passed_at"}),
+		}).Create(&schemas.ProfilePass{UserID: userID, ProfileID: targetProfileID, PassedAt: time.Now()})
+		if result.Error != nil {
+			return fmt.Errorf("error saving profile pass: %w", result.Error)
 		}
-		if err := db.Create(&newView).Error; err != nil {
-			return fmt.Errorf("error creating new profile view: %w", err)
-		}
-	} else {
-		// If a view already exists, update its timestamp
-		existingView.UpdatedAt = time.Now()
-		if err := db.Save(&existingView).Error; err != nil {
-			return fmt.Errorf("error updating existing profile view: %w", err)
+
+		// MySQL reports one affected row for an insert and two for an update
+		if result.RowsAffected != 1 {
+			return nil
 		}
+
+		return incrementSwipeStats(schemas.SwipeStats{UserID: userID, Passes: 1}, "passes", tx)
+	})
+}
+
+// incrementSwipeStats creates stats for a user's first swipe, or adds one to column
+func incrementSwipeStats(stats schemas.SwipeStats, column string, db *gorm.DB) error {
+	if err := db.Clauses(clause.OnConflict{
+		DoUpdates: clause.Set{{Column: clause.Column{Name: column}, Value: gorm.Expr(column + " + 1")}},
+	}).Create(&stats).Error; err != nil {
+		return fmt.Errorf("error updating swipe stats: %w", err)
 	}
 
 	return nil
@@ -300,34 +199,6 @@ func GetAllProfiles(db *gorm.DB) ([]schemas.Profile, error) {
 	return profiles, err
 }
 
-func GetCity(lat, lon float64, mapsClient *maps.Client) (string, error) {
-	r := &maps.GeocodingRequest{
-		LatLng: &maps.LatLng{
-			Lat: lat,
-			Lng: lon,
-		},
-	}
-	resp, err := mapsClient.ReverseGeocode(context.Background(), r)
-	if err != nil {
-		return "", err
-	}
-	if len(resp) == 0 {
-		return "", errors.New("no results found")
-	}
-
-	for _, result := range resp {
-		for _, component := range result.AddressComponents {
-			for _, t := range component.Types {
-				if t == "locality" {
-					return component.LongName, nil
-				}
-			}
-		}
-	}
-
-	return "", errors.New("city not found in response")
-}
-
 func UpdateProfileLocation(data types.UpdateProfileLocationRequest, userId uint, city string, db *gorm.DB) error {
 	profile, err := GetProfileById(userId, db)
 	if err != nil {
@@ -344,17 +215,39 @@ func UpdateProfileLocation(data types.UpdateProfileLocationRequest, userId uint,
 	return nil
 }
 
-func FindProfileByUsername(username string, db *gorm.DB) (*schemas.Profile, error) {
-	var profile *schemas.Profile
-	if err := db.Where("username = ?", username).First(&profile).Error; err != nil {
+// SetTravelLocation overrides the user's discovery location until expiresAt. Their real location is kept.
+func SetTravelLocation(userId uint, lat, lon float64, city string, expiresAt time.Time, db *gorm.DB) (*schemas.Profile, error) {
+	err := db.Model(&schemas.Profile{}).Where("user_id = ?", userId).Updates(map[string]interface{}{
+		"travel_location_point": schemas.NewPoint(lat, lon),
+		"travel_city":           city,
+		"travel_expires_at":     expiresAt,
+	}).Error
+
+	if err != nil {
 		return nil, err
 	}
 
-	if profile == nil {
-		return nil, errors.New("profile not found")
-	}
+	return GetProfileById(userId, db)
+}
 
-	return profile, nil
+// ClearTravelLocation ends travel mode, returning discovery to the user's real location
+func ClearTravelLocation(userId uint, db *gorm.DB) error {
+	return db.Model(&schemas.Profile{}).Where("user_id = ?", userId).Updates(map[string]interface{}{
+		"travel_location_point": nil,
+		"travel_city":           "",
+		"travel_expires_at":     nil,
+	}).Error
+}
+
+// UpdateVisibility switches the profile between visible, incognito and paused
+func UpdateVisibility(userId uint, visibility string, db *gorm.DB) error {
+	return db.Model(&schemas.Profile{}).Where("user_id = ?", userId).Update("visibility", visibility).Error
+}
+
+// FindProfileByUsername follows recently changed usernames to the profile that gave them up, so invites sent to an
+// old username still reach the right person
+func FindProfileByUsername(username string, db *gorm.DB) (*schemas.Profile, error) {
+	return usernames.Resolve(username, db)
 }
 
 func SearchProfilesByUsername(username string, userId uint, db *gorm.DB) ([]*schemas.Profile, error) {
@@ -369,6 +262,7 @@ func SearchProfilesByUsername(username string, userId uint, db *gorm.DB) ([]*sch
 	err := db.Where("username LIKE ?", username+"%").
 		Not("user_id = ?", userId).
 		Not("user_id IN (?)", blockedSubquery).
+		Not("visibility = ?", schemas.VisibilityPaused).
 		Limit(5).
 		Find(&profiles).Error
 
@@ -444,6 +338,36 @@ func DeleteProfileViews(userId uint, db *gorm.DB) error {
 	return nil
 }
 
+// ResetPasses forgets every profile the user passed on so they can be shown again straight away. It returns
+// the profiles that were reset so they can be cleared from the user's seen profiles.
+func ResetPasses(userId uint, db *gorm.DB) ([]uint, error) {
+	var profileIDs []uint
+	if err := db.Model(&schemas.ProfilePass{}).
+		Where("user_id = ?", userId).
+		Pluck("profile_id", &profileIDs).Error; err != nil {
+		return nil, err
+	}
+
+	if len(profileIDs) == 0 {
+		return profileIDs, nil
+	}
+
+	if err := db.Where("user_id = ?", userId).Delete(&schemas.ProfilePass{}).Error; err != nil {
+		return nil, err
+	}
+
+	return profileIDs, nil
+}
+
+// DeleteProfilePasses removes the passes a user made or received and their swipe counts
+func DeleteProfilePasses(userId uint, db *gorm.DB) error {
+	if err := db.Where("user_id = ? OR profile_id = ?", userId, userId).Delete(&schemas.ProfilePass{}).Error; err != nil {
+		return err
+	}
+
+	return db.Where("user_id = ?", userId).Delete(&schemas.SwipeStats{}).Error
+}
+
 func DeleteProfileBlocks(userId uint, db *gorm.DB) error {
 	if err := db.Where("profile_id = ? OR blocked_profile_id = ?", userId, userId).Delete(&schemas.Block{}).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -456,7 +380,7 @@ func DeleteProfileBlocks(userId uint, db *gorm.DB) error {
 	return nil
 }
 
-func DeleteProfile(userId uint, db *gorm.DB, s3Client *s3.S3) error {
+func DeleteProfile(userId uint, db *gorm.DB, s3Client *s3.S3, rdb *redis.Client) error {
 
 	var profile *schemas.Profile
 	if err := db.Where("user_id = ?", userId).First(&profile).Error; err != nil {
@@ -491,6 +415,11 @@ func DeleteProfile(userId uint, db *gorm.DB, s3Client *s3.S3) error {
 		return err
 	}
 
+	if err := DeleteProfilePasses(profile.UserID, db); err != nil {
+		log.Println("Error deleting profile passes: ", err)
+		return err
+	}
+
 	if err := DeleteProfileBlocks(userId, db); err != nil {
 		log.Println("Error deleting profile blocks: ", err)
 		return err
@@ -501,6 +430,61 @@ func DeleteProfile(userId uint, db *gorm.DB, s3Client *s3.S3) error {
 		return err
 	}
 
+	if err := db.Where("profile_id = ?", userId).Delete(&schemas.DiscoveryFilters{}).Error; err != nil {
+		log.Println("Error deleting discovery filters: ", err)
+		return err
+	}
+
+	if err := seen.Delete(userId, db, rdb); err != nil {
+		log.Println("Error deleting seen profiles: ", err)
+		return err
+	}
+
+	if err := photos.DeleteAll(userId, db); err != nil {
+		log.Println("Error deleting profile photos: ", err)
+		return err
+	}
+
+	if err := prompts.DeleteAll(userId, db); err != nil {
+		log.Println("Error deleting prompt answers: ", err)
+		return err
+	}
+
+	if err := interests.DeleteAll(userId, db); err != nil {
+		log.Println("Error deleting profile interests: ", err)
+		return err
+	}
+
+	if err := attributes.DeleteAll(userId, db); err != nil {
+		log.Println("Error deleting profile attributes: ", err)
+		return err
+	}
+
+	if err := db.Where("profile_id = ?", userId).Delete(&schemas.CompletenessNudge{}).Error; err != nil {
+		log.Println("Error deleting completeness nudges: ", err)
+		return err
+	}
+
+	if err := birthdate.DeleteAll(userId, db); err != nil {
+		log.Println("Error deleting date of birth changes: ", err)
+		return err
+	}
+
+	if err := usernames.DeleteAll(userId, db); err != nil {
+		log.Println("Error deleting username history: ", err)
+		return err
+	}
+
+	if err := duplicates.DeleteForUser(userId, db); err != nil {
+		log.Println("Error deleting duplicate photo flags: ", err)
+		return err
+	}
+
+	if err := verification.DeleteAll(userId, db, s3Client); err != nil {
+		log.Println("Error deleting verification requests: ", err)
+		return err
+	}
+
 	if err := db.Where("user_id = ?", userId).Delete(&schemas.Profile{}).Error; err != nil {
 		log.Println("Error deleting profile: ", err)
 		return err
@@ -509,12 +493,7 @@ func DeleteProfile(userId uint, db *gorm.DB, s3Client *s3.S3) error {
 	return nil
 }
 
-func UpdateDateOfBirth(userId uint, dateOfBirth time.Time, db *gorm.DB) error {
-	// TODO: Implement functionality
-	return nil
-}
-
-func AdminUpdateProfile(request types.AdminUpdateProfileRequest, userID uint, db *gorm.DB, s3 *s3.S3, mapsclient *maps.Client) error {
+func AdminUpdateProfile(request types.AdminUpdateProfileRequest, userID uint, adminID uint, db *gorm.DB, s3 *s3.S3, geo geocoder.Geocoder) error {
 	if userID == 0 {
 		return errors.New("no user id")
 	}
@@ -528,84 +507,30 @@ func AdminUpdateProfile(request types.AdminUpdateProfileRequest, userID uint, db
 		return errors.New("gender is not valid")
 	}
 
-	var oldProfile schemas.Profile
-	if err := db.First(&oldProfile, "user_id = ?", userID).Error; err != nil {
-		log.Println("could not find oldProfile")
-		return err
-	}
-
-	// Get all old images
-	oldImages := []string{oldProfile.Image1, oldProfile.Image2, oldProfile.Image3, oldProfile.Image4}
-
-	// Get new images and compact them
-	newImagesRaw := []string{request.Image1, request.Image2, request.Image3, request.Image4}
-	newImages := utils.CompactImages(newImagesRaw)
-
-	// Update the request with the compacted images
-	request.Image1 = newImages[0]
-	request.Image2 = newImages[1]
-	request.Image3 = newImages[2]
-	request.Image4 = newImages[3]
-
-	// Find images to delete
-	for _, oldURL := range oldImages {
-		if oldURL == "" {
-			continue
-		}
-
-		found := false
-		for _, newURL := range newImages {
-			if oldURL == newURL {
-				found = true
-				break
-			}
-		}
-
-		if !found {
-			// This image is no longer used, delete it
-			oldFileName := getFileNameFromURL(oldURL)
-			if err := file.DeleteFileByName(oldFileName, db, s3); err != nil {
-				// Log the error but don't stop the update process
-				log.Printf("Warning: failed to delete old image %s: %v", oldFileName, err)
-			}
-		}
-	}
-
 	parsedDateOfBirth, err := time.Parse(time.RFC3339, request.DateOfBirth)
 
 	if err != nil {
 		return err
 	}
 
-	minAge := 18
-	minBirthDate := time.Now().AddDate(-minAge, 0, 0)
-	if parsedDateOfBirth.After(minBirthDate) {
-		return err
-	}
-
 	updateData := map[string]interface{}{
 		"name":                   request.Name,
-		"username":               request.Username,
 		"bio":                    request.Bio,
 		"gender":                 request.Gender,
 		"date_of_birth":          parsedDateOfBirth,
 		"education":              request.Education,
 		"occupation":             request.Occupation,
-		"interests":              request.Interests,
-		"image1":                 request.Image1,
-		"image2":                 request.Image2,
-		"image3":                 request.Image3,
-		"image4":                 request.Image4,
 		"preferred_gender":       request.PreferredGender,
 		"preferred_age_min":      request.PreferredAgeMin,
 		"preferred_age_max":      request.PreferredAgeMax,
 		"preferred_distance_max": request.PreferredDistanceMax,
 	}
 
+	// Looked up before the transaction so it isn't held open while the geocoder is called
 	if request.Lat != 0 && request.Lon != 0 {
 		log.Println("LAT: ", request.Lat, " | LON: ", request.Lon)
 
-		city, err := GetCity(request.Lat, request.Lon, mapsclient)
+		city, err := geocoder.CityOrEmpty(geo, request.Lat, request.Lon)
 
 		if err != nil {
 			log.Println("no city found")
@@ -617,7 +542,46 @@ func AdminUpdateProfile(request types.AdminUpdateProfileRequest, userID uint, db
 	}
 
 	log.Println(userID)
-	return db.Model(&schemas.Profile{}).Where("user_id = ?", userID).Updates(updateData).Error
+	var replaced []string
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var oldProfile schemas.Profile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&oldProfile, "user_id = ?", userID).Error; err != nil {
+			log.Println("could not find oldProfile")
+			return err
+		}
+
+		// Admin changes skip the review queue, but still have to be a valid age and are recorded
+		if err := birthdate.AdminSet(userID, oldProfile.DateOfBirth, parsedDateOfBirth, adminID, tx); err != nil {
+			return err
+		}
+
+		// Admins skip the cooldown, but the change is still recorded and the old username reserved
+		if request.Username != "" && request.Username != oldProfile.Username {
+			if err := usernames.AdminChange(userID, request.Username, tx); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&schemas.Profile{}).Where("user_id = ?", userID).Updates(updateData).Error; err != nil {
+			return err
+		}
+
+		if !interests.SameNames(request.Interests, oldProfile.Interests) {
+			if _, err := interests.SetFromNames(userID, request.Interests, tx); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		replaced, err = photos.AdminSetImages(userID, []string{request.Image1, request.Image2, request.Image3, request.Image4}, tx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	photos.DeleteFiles(userID, replaced, db, s3)
+	return nil
 }
 
 func DeleteProfileViewsBetweenUsers(userID1 uint, userID2 uint, db *gorm.DB) error {
@@ -631,7 +595,8 @@ func DeleteProfileViewsBetweenUsers(userID1 uint, userID2 uint, db *gorm.DB) err
 	return nil
 }
 
-func DeleteProfileViewsForMatch(match schemas.Matches, db *gorm.DB) error {
+// DeletePassesForMatch removes any passes between the profiles of a match so they can see each other again
+func DeletePassesForMatch(match schemas.Matches, db *gorm.DB) error {
 	profileIDs := []uint{match.Profile1ID, match.Profile3ID}
 	if match.Profile2ID != nil {
 		profileIDs = append(profileIDs, *match.Profile2ID)
@@ -640,29 +605,9 @@ func DeleteProfileViewsForMatch(match schemas.Matches, db *gorm.DB) error {
 		profileIDs = append(profileIDs, *match.Profile4ID)
 	}
 
-	for i, id1 := range profileIDs {
-		for j, id2 := range profileIDs {
-			if i != j {
-				if err := db.Where("(user_id = ? AND profile_id = ?) OR (user_id = ? AND profile_id = ?)",
-					id1, id2, id2, id1).Delete(&schemas.ProfileView{}).Error; err != nil {
-					return fmt.Errorf("error deleting profile views: %w", err)
-				}
-			}
-		}
+	if err := db.Where("user_id IN ? AND profile_id IN ?", profileIDs, profileIDs).Delete(&schemas.ProfilePass{}).Error; err != nil {
+		return fmt.Errorf("error deleting profile passes: %w", err)
 	}
 
 	return nil
 }
-
-func updateImage(oldURL, newURL string, db *gorm.DB, s3 *s3.S3) (string, error) {
-	if newURL == "" || newURL == oldURL {
-		return oldURL, nil
-	}
-	if oldURL != "" {
-		oldFileName := getFileNameFromURL(oldURL)
-		if err := file.DeleteFileByName(oldFileName, db, s3); err != nil {
-			log.Printf("Warning: failed to delete old image %s: %v", oldFileName, err)
-		}
-	}
-	return newURL, nil
-}
@@ -0,0 +1,391 @@
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+	"twoman/schemas"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultMaxAnswers is used when MAX_PROMPT_ANSWERS isn't set
+	DefaultMaxAnswers = 3
+	// MaxAnswerLength caps how long an answer can be
+	MaxAnswerLength = 150
+	// MaxPromptLength caps how long a catalog prompt can be
+	MaxPromptLength = 255
+)
+
+var (
+	ErrPromptNotFound   = errors.New("prompt not found")
+	ErrPromptInactive   = errors.New("prompt is no longer available")
+	ErrPromptInUse      = errors.New("prompt has answers, deactivate it instead")
+	ErrAnswerNotFound   = errors.New("prompt answer not found")
+	ErrTooManyAnswers   = errors.New("profile has too many prompt answers")
+	ErrAlreadyAnswered  = errors.New("prompt has already been answered")
+	ErrInvalidOrder     = errors.New("order must contain every answer exactly once")
+	ErrDuplicatePrompt  = errors.New("a prompt with this text already exists")
+	ErrEmptyPromptText  = errors.New("prompt text is required")
+	ErrPromptTextLength = fmt.Errorf("prompt text must be less than %d characters", MaxPromptLength)
+)
+
+// MaxAnswers is how many prompts a profile can answer, configured with MAX_PROMPT_ANSWERS
+func MaxAnswers() int {
+	max, err := strconv.Atoi(os.Getenv("MAX_PROMPT_ANSWERS"))
+	if err != nil || max < 1 {
+		return DefaultMaxAnswers
+	}
+	return max
+}
+
+// Catalog returns the prompts users can pick from, grouped by category. Admins also see inactive prompts.
+func Catalog(includeInactive bool, db *gorm.DB) ([]schemas.Prompt, error) {
+	query := db.Model(&schemas.Prompt{})
+	if !includeInactive {
+		query = query.Where("active = ?", true)
+	}
+
+	prompts := []schemas.Prompt{}
+	if err := query.Order("category, id").Find(&prompts).Error; err != nil {
+		return nil, fmt.Errorf("error loading prompts: %w", err)
+	}
+
+	return prompts, nil
+}
+
+// CreatePrompt adds an active prompt to the catalog
+func CreatePrompt(text string, category string, db *gorm.DB) (*schemas.Prompt, error) {
+	if err := validatePromptText(text, 0, db); err != nil {
+		return nil, err
+	}
+
+	prompt := schemas.Prompt{
+		Text:     text,
+		Category: category,
+		Active:   true,
+	}
+	if err := db.Create(&prompt).Error; err != nil {
+		return nil, err
+	}
+
+	return &prompt, nil
+}
+
+// UpdatePrompt edits a catalog prompt. Nil fields are left unchanged.
+func UpdatePrompt(promptID uint, text *string, category *string, active *bool, db *gorm.DB) (*schemas.Prompt, error) {
+	prompt, err := getPrompt(promptID, db)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if text != nil {
+		if err := validatePromptText(*text, promptID, db); err != nil {
+			return nil, err
+		}
+		prompt.Text = *text
+		updates["text"] = *text
+	}
+	if category != nil {
+		prompt.Category = *category
+		updates["category"] = *category
+	}
+	if active != nil {
+		prompt.Active = *active
+		updates["active"] = *active
+	}
+
+	if len(updates) == 0 {
+		return prompt, nil
+	}
+
+	if err := db.Model(prompt).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	return prompt, nil
+}
+
+// DeletePrompt removes a prompt nobody has answered. Answered prompts have to be deactivated so the answers stay.
+func DeletePrompt(promptID uint, db *gorm.DB) error {
+	prompt, err := getPrompt(promptID, db)
+	if err != nil {
+		return err
+	}
+
+	var answers, voiceAnswers int64
+	if err := db.Model(&schemas.PromptAnswer{}).Where("prompt_id = ?", promptID).Count(&answers).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&schemas.VoicePrompt{}).Where("prompt_id = ?", promptID).Count(&voiceAnswers).Error; err != nil {
+		return err
+	}
+	if answers > 0 || voiceAnswers > 0 {
+		return ErrPromptInUse
+	}
+
+	return db.Delete(prompt).Error
+}
+
+// List returns a profile's answers in order, with their prompts
+func List(profileID uint, db *gorm.DB) ([]schemas.PromptAnswer, error) {
+	answers := []schemas.PromptAnswer{}
+	if err := db.Preload("Prompt").Where("profile_id = ?", profileID).Order("position").Find(&answers).Error; err != nil {
+		return nil, fmt.Errorf("error loading prompt answers: %w", err)
+	}
+
+	return answers, nil
+}
+
+// Attach fills Prompts on each profile with its answers, and VoicePrompt with its voice prompt
+func Attach(profiles []*schemas.Profile, db *gorm.DB) error {
+	if len(profiles) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(profiles))
+	for _, profile := range profiles {
+		ids = append(ids, profile.UserID)
+	}
+
+	var all []schemas.PromptAnswer
+	if err := db.Preload("Prompt").Where("profile_id IN ?", ids).Order("profile_id, position").Find(&all).Error; err != nil {
+		return fmt.Errorf("error loading prompt answers: %w", err)
+	}
+
+	byProfile := make(map[uint][]schemas.PromptAnswer, len(profiles))
+	for _, answer := range all {
+		byProfile[answer.ProfileID] = append(byProfile[answer.ProfileID], answer)
+	}
+
+	for _, profile := range profiles {
+		profile.Prompts = byProfile[profile.UserID]
+	}
+
+	return attachVoice(profiles, ids, db)
+}
+
+// Answer adds an answer to an active prompt at the end of the profile's answers
+func Answer(profileID uint, promptID uint, text string, db *gorm.DB) (*schemas.PromptAnswer, error) {
+	prompt, err := getPrompt(promptID, db)
+	if err != nil {
+		return nil, err
+	}
+	if !prompt.Active {
+		return nil, ErrPromptInactive
+	}
+
+	var answer schemas.PromptAnswer
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Locking the profile stops two answers both passing the checks below and taking the same position
+		var profile schemas.Profile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("user_id").
+			Where("user_id = ?", profileID).Limit(1).Find(&profile).Error; err != nil {
+			return err
+		}
+
+		existing, err := List(profileID, tx)
+		if err != nil {
+			return err
+		}
+		if len(existing) >= MaxAnswers() {
+			return ErrTooManyAnswers
+		}
+		for _, other := range existing {
+			if other.PromptID == promptID {
+				return ErrAlreadyAnswered
+			}
+		}
+
+		answer = schemas.PromptAnswer{
+			ProfileID: profileID,
+			PromptID:  promptID,
+			Prompt:    *prompt,
+			Answer:    text,
+			Position:  len(existing),
+		}
+		if err := tx.Omit("Prompt").Create(&answer).Error; err != nil {
+			return err
+		}
+
+		return touchContent(profileID, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &answer, nil
+}
+
+// UpdateAnswer changes the text of one of the profile's answers
+func UpdateAnswer(profileID uint, answerID uint, text string, db *gorm.DB) (*schemas.PromptAnswer, error) {
+	answer, err := getAnswer(profileID, answerID, db)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(answer).Update("answer", text).Error; err != nil {
+			return err
+		}
+
+		return touchContent(profileID, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	answer.Answer = text
+	return answer, nil
+}
+
+// Reorder sets the order of a profile's answers. answerIDs must list every answer exactly once.
+func Reorder(profileID uint, answerIDs []uint, db *gorm.DB) ([]schemas.PromptAnswer, error) {
+	var ordered []schemas.PromptAnswer
+	err := db.Transaction(func(tx *gorm.DB) error {
+		existing, err := List(profileID, tx)
+		if err != nil {
+			return err
+		}
+		if len(answerIDs) != len(existing) {
+			return ErrInvalidOrder
+		}
+
+		byID := make(map[uint]schemas.PromptAnswer, len(existing))
+		for _, answer := range existing {
+			byID[answer.ID] = answer
+		}
+
+		ordered = make([]schemas.PromptAnswer, 0, len(answerIDs))
+		for _, id := range answerIDs {
+			answer, ok := byID[id]
+			if !ok {
+				return ErrInvalidOrder
+			}
+			delete(byID, id)
+			ordered = append(ordered, answer)
+		}
+
+		return saveOrder(ordered, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ordered, nil
+}
+
+// Remove deletes one of the profile's answers and closes the gap it leaves
+func Remove(profileID uint, answerID uint, db *gorm.DB) error {
+	answer, err := getAnswer(profileID, answerID, db)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(answer).Error; err != nil {
+			return err
+		}
+
+		remaining, err := List(profileID, tx)
+		if err != nil {
+			return err
+		}
+
+		return saveOrder(remaining, tx)
+	})
+}
+
+// DeleteAll removes every answer and the voice prompt of a profile, e.g. when the account is deleted. The voice
+// prompt's audio goes with the rest of the user's files.
+func DeleteAll(profileID uint, db *gorm.DB) error {
+	if err := db.Where("profile_id = ?", profileID).Delete(&schemas.VoicePrompt{}).Error; err != nil {
+		return err
+	}
+
+	return db.Where("profile_id = ?", profileID).Delete(&schemas.PromptAnswer{}).Error
+}
+
+// FindForProfiles returns an answer that belongs to one of profileIDs, so a like can only target an answer on the
+// profiles it was sent to
+func FindForProfiles(answerID uint, profileIDs []uint, db *gorm.DB) (*schemas.PromptAnswer, error) {
+	var answer schemas.PromptAnswer
+	if err := db.Preload("Prompt").Where("id = ? AND profile_id IN ?", answerID, profileIDs).Limit(1).Find(&answer).Error; err != nil {
+		return nil, err
+	}
+
+	if answer.ID == 0 {
+		return nil, ErrAnswerNotFound
+	}
+
+	return &answer, nil
+}
+
+func getPrompt(promptID uint, db *gorm.DB) (*schemas.Prompt, error) {
+	var prompt schemas.Prompt
+	if err := db.First(&prompt, promptID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromptNotFound
+		}
+		return nil, err
+	}
+
+	return &prompt, nil
+}
+
+func getAnswer(profileID uint, answerID uint, db *gorm.DB) (*schemas.PromptAnswer, error) {
+	var answer schemas.PromptAnswer
+	if err := db.Preload("Prompt").Where("id = ? AND profile_id = ?", answerID, profileID).Limit(1).Find(&answer).Error; err != nil {
+		return nil, err
+	}
+
+	if answer.ID == 0 {
+		return nil, ErrAnswerNotFound
+	}
+
+	return &answer, nil
+}
+
+// validatePromptText checks text is usable and not already in the catalog under another id
+func validatePromptText(text string, promptID uint, db *gorm.DB) error {
+	if text == "" {
+		return ErrEmptyPromptText
+	}
+	if len(text) > MaxPromptLength {
+		return ErrPromptTextLength
+	}
+
+	var duplicates int64
+	if err := db.Model(&schemas.Prompt{}).Where("text = ? AND id != ?", text, promptID).Count(&duplicates).Error; err != nil {
+		return err
+	}
+	if duplicates > 0 {
+		return ErrDuplicatePrompt
+	}
+
+	return nil
+}
+
+func saveOrder(answers []schemas.PromptAnswer, tx *gorm.DB) error {
+	for i := range answers {
+		if answers[i].Position == i {
+			continue
+		}
+		answers[i].Position = i
+		if err := tx.Model(&schemas.PromptAnswer{}).Where("id = ?", answers[i].ID).Update("position", i).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// touchContent lets profiles that were passed on come back around in discovery after they answer something new
+func touchContent(profileID uint, tx *gorm.DB) error {
+	return tx.Model(&schemas.Profile{}).Where("user_id = ?", profileID).Update("content_updated_at", time.Now()).Error
+}
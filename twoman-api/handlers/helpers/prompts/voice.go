@@ -0,0 +1,132 @@
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"twoman/handlers/helpers/file"
+	"twoman/handlers/helpers/photos"
+	"twoman/schemas"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAudioNotOwned      = errors.New("audio was not uploaded by this user")
+	ErrVoicePromptMissing = errors.New("profile has no voice prompt")
+)
+
+// GetVoice returns a profile's voice prompt, or nil when it hasn't recorded one
+func GetVoice(profileID uint, db *gorm.DB) (*schemas.VoicePrompt, error) {
+	var voice schemas.VoicePrompt
+	if err := db.Preload("Prompt").Where("profile_id = ?", profileID).Limit(1).Find(&voice).Error; err != nil {
+		return nil, fmt.Errorf("error loading voice prompt: %w", err)
+	}
+
+	if voice.ID == 0 {
+		return nil, nil
+	}
+
+	return &voice, nil
+}
+
+// SetVoice makes an audio clip the user has already uploaded their voice prompt, replacing and deleting any
+// previous one. promptID optionally picks the catalog prompt the clip answers.
+func SetVoice(profileID uint, url string, promptID *uint, db *gorm.DB, s3Client *s3.S3) (*schemas.VoicePrompt, error) {
+	filename := photos.FilenameFromURL(url)
+
+	var audio schemas.FileMetadata
+	if err := db.Where("filename = ? AND user_id = ? AND duration_ms > 0", filename, profileID).Limit(1).Find(&audio).Error; err != nil {
+		return nil, err
+	}
+	if audio.ID == 0 {
+		return nil, ErrAudioNotOwned
+	}
+
+	var prompt *schemas.Prompt
+	if promptID != nil {
+		var err error
+		if prompt, err = getPrompt(*promptID, db); err != nil {
+			return nil, err
+		}
+		if !prompt.Active {
+			return nil, ErrPromptInactive
+		}
+	}
+
+	existing, err := GetVoice(profileID, db)
+	if err != nil {
+		return nil, err
+	}
+
+	voice := schemas.VoicePrompt{ProfileID: profileID}
+	if existing != nil {
+		voice = *existing
+	}
+	voice.PromptID = promptID
+	voice.Prompt = prompt
+	voice.URL = url
+	voice.Filename = filename
+	voice.DurationMs = audio.DurationMs
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Prompt").Save(&voice).Error; err != nil {
+			return err
+		}
+
+		return touchContent(profileID, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil && existing.Filename != filename {
+		deleteAudio(existing.Filename, db, s3Client)
+	}
+
+	return &voice, nil
+}
+
+// RemoveVoice deletes a profile's voice prompt and its audio
+func RemoveVoice(profileID uint, db *gorm.DB, s3Client *s3.S3) error {
+	voice, err := GetVoice(profileID, db)
+	if err != nil {
+		return err
+	}
+	if voice == nil {
+		return ErrVoicePromptMissing
+	}
+
+	if err := db.Delete(voice).Error; err != nil {
+		return err
+	}
+
+	deleteAudio(voice.Filename, db, s3Client)
+	return nil
+}
+
+// attachVoice fills VoicePrompt on each profile that has recorded one
+func attachVoice(profiles []*schemas.Profile, ids []uint, db *gorm.DB) error {
+	var all []schemas.VoicePrompt
+	if err := db.Preload("Prompt").Where("profile_id IN ?", ids).Find(&all).Error; err != nil {
+		return fmt.Errorf("error loading voice prompts: %w", err)
+	}
+
+	byProfile := make(map[uint]*schemas.VoicePrompt, len(all))
+	for i := range all {
+		byProfile[all[i].ProfileID] = &all[i]
+	}
+
+	for _, profile := range profiles {
+		profile.VoicePrompt = byProfile[profile.UserID]
+	}
+
+	return nil
+}
+
+func deleteAudio(filename string, db *gorm.DB, s3Client *s3.S3) {
+	if err := file.DeleteFileByName(filename, db, s3Client); err != nil {
+		log.Printf("Warning: failed to delete old voice prompt %s: %v", filename, err)
+	}
+}
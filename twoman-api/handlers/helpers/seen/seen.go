@@ -0,0 +1,187 @@
+package seen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"twoman/schemas"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SEEN_TTL is how long an untouched seen bitmap stays cached before it is rebuilt from seen_profiles
+const SEEN_TTL = 7 * 24 * time.Hour
+
+// chunkBits is how many profile IDs a single seen_profiles row covers
+const chunkBits = 64
+
+// loadedBit is set once a cached bitmap holds everything in seen_profiles. Profile ID 0 is never used, so its
+// bit is free. Mark can create the key before that, and Bitmap merges the stored rows into it.
+const loadedBit = 0
+
+// setBits sets bits on the cached bitmap, creating it if needed. ARGV[1] is the TTL in seconds, the rest
+// are bit offsets.
+var setBits = redis.NewScript(`
+for i = 2, #ARGV do
+	redis.call("SETBIT", KEYS[1], ARGV[i], 1)
+end
+redis.call("EXPIRE", KEYS[1], ARGV[1])
+return 1
+`)
+
+// mergeBitmap ORs a bitmap loaded from seen_profiles (ARGV[1]) into the cached one, so bits Mark set while it
+// was loading are kept. KEYS[2] is scratch space. ARGV[2] is the TTL in seconds.
+var mergeBitmap = redis.NewScript(`
+redis.call("SET", KEYS[2], ARGV[1])
+redis.call("BITOP", "OR", KEYS[1], KEYS[1], KEYS[2])
+redis.call("DEL", KEYS[2])
+redis.call("EXPIRE", KEYS[1], ARGV[2])
+return redis.call("GET", KEYS[1])
+`)
+
+func seenKey(userID uint) string {
+	return fmt.Sprintf("user:%d:seen", userID)
+}
+
+func loadingKey(userID uint) string {
+	return fmt.Sprintf("user:%d:seen:loading", userID)
+}
+
+// Mark records that userID has swiped on profileIDs
+func Mark(userID uint, profileIDs []uint, db *gorm.DB, rdb *redis.Client) error {
+	if len(profileIDs) == 0 {
+		return nil
+	}
+
+	chunks := make(map[uint]uint64)
+	for _, id := range profileIDs {
+		chunks[id/chunkBits] |= 1 << (id % chunkBits)
+	}
+
+	rows := make([]schemas.SeenProfiles, 0, len(chunks))
+	for chunk, bits := range chunks {
+		rows = append(rows, schemas.SeenProfiles{UserID: userID, Chunk: chunk, Bits: bits})
+	}
+
+	if err := db.Clauses(clause.OnConflict{
+		DoUpdates: clause.Set{{Column: clause.Column{Name: "bits"}, Value: gorm.Expr("bits | VALUES(bits)")}},
+	}).Create(&rows).Error; err != nil {
+		return fmt.Errorf("error saving seen profiles: %w", err)
+	}
+
+	args := []interface{}{int(SEEN_TTL.Seconds())}
+	for _, id := range profileIDs {
+		args = append(args, id)
+	}
+
+	if err := setBits.Run(context.Background(), rdb, []string{seenKey(userID)}, args...).Err(); err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("error caching seen profiles: %w", err)
+	}
+
+	return nil
+}
+
+// Unmark forgets that userID has swiped on profileIDs so they can be shown again
+func Unmark(userID uint, profileIDs []uint, db *gorm.DB, rdb *redis.Client) error {
+	if len(profileIDs) == 0 {
+		return nil
+	}
+
+	chunks := make(map[uint]uint64)
+	for _, id := range profileIDs {
+		chunks[id/chunkBits] |= 1 << (id % chunkBits)
+	}
+
+	for chunk, bits := range chunks {
+		if err := db.Model(&schemas.SeenProfiles{}).
+			Where("user_id = ? AND chunk = ?", userID, chunk).
+			Update("bits", gorm.Expr("bits & ~?", bits)).Error; err != nil {
+			return fmt.Errorf("error removing seen profiles: %w", err)
+		}
+	}
+
+	// Clearing is rare, so let the next read rebuild the bitmap instead of patching it
+	return rdb.Del(context.Background(), seenKey(userID)).Err()
+}
+
+// Delete removes everything userID has seen, e.g. when their account is deleted
+func Delete(userID uint, db *gorm.DB, rdb *redis.Client) error {
+	if err := db.Where("user_id = ?", userID).Delete(&schemas.SeenProfiles{}).Error; err != nil {
+		return err
+	}
+
+	return rdb.Del(context.Background(), seenKey(userID)).Err()
+}
+
+// Bitmap returns the profiles userID has seen as a bitmap in Redis bit order: profile ID n is bit
+// (7 - n%8) of byte n/8. It is loaded from seen_profiles and cached when the cached copy isn't complete.
+func Bitmap(userID uint, db *gorm.DB, rdb *redis.Client) ([]byte, error) {
+	ctx := context.Background()
+
+	cached, err := rdb.Get(ctx, seenKey(userID)).Bytes()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to get seen profiles: %v", err)
+	}
+	if len(cached) > 0 && isSet(cached, loadedBit) {
+		return cached, nil
+	}
+
+	var rows []schemas.SeenProfiles
+	if err := db.Where("user_id = ? AND bits != 0", userID).Order("chunk").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("error loading seen profiles: %w", err)
+	}
+
+	bitmap := []byte{0}
+	if len(rows) > 0 {
+		bitmap = make([]byte, (rows[len(rows)-1].Chunk+1)*chunkBits/8)
+	}
+
+	for _, row := range rows {
+		for i := uint(0); i < chunkBits; i++ {
+			if row.Bits&(1<<i) != 0 {
+				Set(bitmap, row.Chunk*chunkBits+i, true)
+			}
+		}
+	}
+	Set(bitmap, loadedBit, true)
+
+	merged, err := mergeBitmap.Run(ctx, rdb, []string{seenKey(userID), loadingKey(userID)}, bitmap, int(SEEN_TTL.Seconds())).Text()
+	if err != nil {
+		return nil, fmt.Errorf("failed to cache seen profiles: %v", err)
+	}
+
+	return []byte(merged), nil
+}
+
+func isSet(bitmap []byte, profileID uint) bool {
+	index := profileID / 8
+	return index < uint(len(bitmap)) && bitmap[index]&(0x80>>(profileID%8)) != 0
+}
+
+// Set sets or clears the bit for profileID in bitmap. Bits past the end of bitmap are already clear.
+func Set(bitmap []byte, profileID uint, value bool) {
+	index := profileID / 8
+	if index >= uint(len(bitmap)) {
+		return
+	}
+
+	mask := byte(0x80 >> (profileID % 8))
+	if value {
+		bitmap[index] |= mask
+	} else {
+		bitmap[index] &^= mask
+	}
+}
+
+// UnseenSQL returns a condition that keeps rows of profiles under alias whose bit isn't set in bitmap.
+// The bitmap is sent as a single parameter so checking a candidate doesn't touch another table.
+func UnseenSQL(bitmap []byte, alias string) (string, []interface{}) {
+	if len(bitmap) == 0 {
+		return "1 = 1", nil
+	}
+
+	return fmt.Sprintf("(ORD(SUBSTRING(?, %[1]s.user_id DIV 8 + 1, 1)) & (128 >> (%[1]s.user_id %% 8))) = 0", alias), []interface{}{bitmap}
+}
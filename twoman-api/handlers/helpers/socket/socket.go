@@ -9,9 +9,12 @@ import (
 	"reflect"
 	"time"
 	"twoman/handlers/helpers/chat"
+	"twoman/handlers/helpers/deck"
 	"twoman/handlers/helpers/matches"
 	"twoman/handlers/helpers/notifications"
 	"twoman/handlers/helpers/profile"
+	"twoman/handlers/helpers/prompts"
+	"twoman/handlers/helpers/seen"
 	"twoman/handlers/helpers/standouts"
 	"twoman/handlers/helpers/user"
 	"twoman/schemas"
@@ -26,7 +29,8 @@ type Handler struct {
 	UserChannels map[uint]chan []byte
 }
 
-func BroadcastToUser[T schemas.Matches | schemas.Message | schemas.Friendship | types.SocketProfileResponseData](userID uint, message types.SocketMessage[*T], rdb *redis.Client, db *gorm.DB) {
+// PublishToUser sends a message to the user's open websocket connections without a push notification
+func PublishToUser[T schemas.Matches | schemas.Message | schemas.Friendship | schemas.DatePlan | types.SocketProfileResponseData](userID uint, message types.SocketMessage[*T], rdb *redis.Client) {
 
 	if userID == 0 {
 		log.Println("Invalid user ID")
@@ -51,6 +55,16 @@ func BroadcastToUser[T schemas.Matches | schemas.Message | schemas.Friendship |
 			log.Println("Publish error:", err)
 		}
 	}
+}
+
+func BroadcastToUser[T schemas.Matches | schemas.Message | schemas.Friendship | schemas.DatePlan | types.SocketProfileResponseData](userID uint, message types.SocketMessage[*T], rdb *redis.Client, db *gorm.DB) {
+
+	if userID == 0 {
+		log.Println("Invalid user ID")
+		return
+	}
+
+	PublishToUser(userID, message, rdb)
 
 	pushTokens, err := notifications.GetPushTokensByUserId(userID, db)
 	if err != nil {
@@ -216,6 +230,27 @@ func BroadcastToUser[T schemas.Matches | schemas.Message | schemas.Friendship |
 		body = data.Message
 	case reflect.TypeOf(message.Data).Elem() == reflect.TypeOf(types.SocketProfileResponseData{}):
 		return
+	case reflect.TypeOf(message.Data).Elem() == reflect.TypeOf(schemas.DatePlan{}):
+
+		data := any(message.Data).(*schemas.DatePlan)
+
+		switch data.Status {
+		case schemas.DateStatusProposed:
+			// The proposer already knows about their own plan
+			if data.ProposedByID == userID {
+				return
+			}
+			title = "New Date Plan"
+			body = fmt.Sprintf("%s suggested meeting at %s", data.ProposedBy.Name, data.VenueName)
+		case schemas.DateStatusConfirmed:
+			title = "It's a Date!"
+			body = fmt.Sprintf("Everyone is going to %s", data.VenueName)
+		case schemas.DateStatusCancelled:
+			title = "Date Cancelled"
+			body = fmt.Sprintf("The plans at %s were cancelled", data.VenueName)
+		default:
+			return
+		}
 	case reflect.TypeOf(message.Data).Elem() == reflect.TypeOf(schemas.Friendship{}):
 
 		data := any(message.Data).(*schemas.Friendship)
@@ -464,6 +499,28 @@ func (s Handler) HandleProfile(socketMessage types.SocketMessage[types.SocketPro
 		profileData := socketMessage.Data
 
 		if profileData.Decision == "like" {
+			// A like can be sent on one of the target's prompt answers. Standout likes don't create a match to open it with.
+			var promptAnswer *schemas.PromptAnswer
+			if profileData.PromptAnswerID != 0 && !profileData.IsStandout {
+				targets := []uint{profileData.TargetProfile}
+				if profileData.TargetFriendProfile != 0 {
+					targets = append(targets, profileData.TargetFriendProfile)
+				}
+
+				var err error
+				promptAnswer, err = prompts.FindForProfiles(profileData.PromptAnswerID, targets, db)
+				if err != nil {
+					if errors.Is(err, prompts.ErrAnswerNotFound) {
+						sendErrorResponse(userId, "Prompt answer not found", rdb, db)
+						return
+					}
+					log.Println("Error getting prompt answer:", err)
+					sendErrorResponse(userId, "Error processing like", rdb, db)
+					sentry.CaptureException(err)
+					return
+				}
+			}
+
 			// Handle standout likes differently - they don't count towards daily limits and require star payment
 			if profileData.IsStandout {
 				// Check if user has enough stars from local database
@@ -552,23 +609,37 @@ func (s Handler) HandleProfile(socketMessage types.SocketMessage[types.SocketPro
 				}
 			}
 
-			// Only create profile views and matches for non-standout likes
+			// Only mark profiles seen and create matches for non-standout likes
 			// Standout likes are now tracked only in Redis for display filtering
 			if !profileData.IsStandout {
-				if err := profile.CreateProfileView(userId, profileData.TargetProfile, db); err != nil {
-					log.Println("Error creating profile view:", err)
-					sendErrorResponse(userId, "Error processing like", rdb, db)
-					sentry.CaptureException(err)
-					return
-				}
+				if profileData.IsDuo && profileData.TargetFriendProfile != 0 {
+					// Duo discovery pairs come with both targets, so the match goes straight to them
+					match, err := matches.CreateDuoPairMatch(userId, profileData.FriendProfile, profileData.TargetProfile, profileData.TargetFriendProfile, db)
 
-				if err := profile.CreateProfileView(profileData.TargetProfile, userId, db); err != nil {
-					sendErrorResponse(userId, "Error processing like", rdb, db)
-					sentry.CaptureException(err)
-					return
-				}
+					if err != nil {
+						log.Println("Error creating duo pair match:", err)
+						sendErrorResponse(userId, "Error processing like", rdb, db)
+						sentry.CaptureException(err)
+						return
+					}
 
-				if profileData.IsDuo {
+					setPromptAnswer(match, promptAnswer, db)
+
+					matchSocketMessage := types.SocketMessage[*schemas.Matches]{
+						Type: "match",
+						Data: match,
+					}
+
+					BroadcastToUser(profileData.FriendProfile, matchSocketMessage, rdb, db)
+					BroadcastToUser(profileData.TargetProfile, matchSocketMessage, rdb, db)
+					BroadcastToUser(profileData.TargetFriendProfile, matchSocketMessage, rdb, db)
+
+					markSeen(userId, profileData.TargetFriendProfile, db, rdb)
+
+					if err := deck.ConsumeCandidate(userId, profileData.TargetFriendProfile, rdb); err != nil {
+						log.Println("Error consuming discovery deck candidate:", err)
+					}
+				} else if profileData.IsDuo {
 					if err := matches.CreateDuoMatch(userId, profileData.FriendProfile, profileData.TargetProfile, db); err != nil {
 						log.Println("Error creating duo match:", err)
 						sendErrorResponse(userId, "Error processing like", rdb, db)
@@ -585,6 +656,8 @@ func (s Handler) HandleProfile(socketMessage types.SocketMessage[types.SocketPro
 						return
 					}
 
+					setPromptAnswer(match, promptAnswer, db)
+
 					matchSocketMessage := types.SocketMessage[*schemas.Matches]{
 						Type: "match",
 						Data: match,
@@ -607,6 +680,8 @@ func (s Handler) HandleProfile(socketMessage types.SocketMessage[types.SocketPro
 						return
 					}
 
+					setPromptAnswer(match, promptAnswer, db)
+
 					matchSocketMessage := types.SocketMessage[*schemas.Matches]{
 						Type: "match",
 						Data: match,
@@ -614,17 +689,40 @@ func (s Handler) HandleProfile(socketMessage types.SocketMessage[types.SocketPro
 
 					BroadcastToUser(profileData.TargetProfile, matchSocketMessage, rdb, db)
 				}
+
+				// The match exists now, so the like can't be rejected any more
+				if err := profile.RecordLike(userId, db); err != nil {
+					log.Println("Error recording like:", err)
+					sentry.CaptureException(err)
+				}
+
+				markSeen(userId, profileData.TargetProfile, db, rdb)
+			}
+
+			if !profileData.IsStandout {
+				if err := deck.ConsumeCandidate(userId, profileData.TargetProfile, rdb); err != nil {
+					log.Println("Error consuming discovery deck candidate:", err)
+				}
 			}
 
 			sendSuccessResponse(userId, "Successfully processed like", rdb, db)
 
 		} else {
-			if err := profile.CreateProfileView(userId, profileData.TargetProfile, db); err != nil {
-				log.Println("Error creating profile view:", err)
+			if err := profile.RecordPass(userId, profileData.TargetProfile, db); err != nil {
+				log.Println("Error recording pass:", err)
 				sentry.CaptureException(err)
 				return
 			}
 
+			if err := seen.Mark(userId, []uint{profileData.TargetProfile}, db, rdb); err != nil {
+				log.Println("Error marking profile as seen:", err)
+				sentry.CaptureException(err)
+			}
+
+			if err := deck.ConsumeCandidate(userId, profileData.TargetProfile, rdb); err != nil {
+				log.Println("Error consuming discovery deck candidate:", err)
+			}
+
 			sendSuccessResponse(userId, "Successfully processed dislike", rdb, db)
 		}
 
@@ -653,3 +751,41 @@ func sendSuccessResponse(userId uint, message string, rdb *redis.Client, db *gor
 	}
 	BroadcastToUser(userId, response, rdb, db)
 }
+
+// setPromptAnswer opens a new match with the prompt answer the like was sent on. The match already exists, so a
+// failure here is logged rather than failing the like.
+func setPromptAnswer(match *schemas.Matches, answer *schemas.PromptAnswer, db *gorm.DB) {
+	if answer == nil {
+		return
+	}
+
+	if err := matches.SetPromptAnswer(match, answer, db); err != nil {
+		log.Println("Error setting match prompt answer:", err)
+		sentry.CaptureException(err)
+	}
+}
+
+// markSeen hides a liked profile from the liker's discovery, and the liker from theirs while the like is pending.
+// Incognito likers stay discoverable to the people they liked, since that's the only way those people can see them.
+func markSeen(userId uint, targetId uint, db *gorm.DB, rdb *redis.Client) {
+	if err := seen.Mark(userId, []uint{targetId}, db, rdb); err != nil {
+		log.Println("Error marking profile as seen:", err)
+		sentry.CaptureException(err)
+	}
+
+	var liker schemas.Profile
+	if err := db.Select("visibility").Where("user_id = ?", userId).Limit(1).Find(&liker).Error; err != nil {
+		log.Println("Error loading liker visibility:", err)
+		sentry.CaptureException(err)
+		return
+	}
+
+	if liker.Visibility == schemas.VisibilityIncognito {
+		return
+	}
+
+	if err := seen.Mark(targetId, []uint{userId}, db, rdb); err != nil {
+		log.Println("Error marking profile as seen:", err)
+		sentry.CaptureException(err)
+	}
+}
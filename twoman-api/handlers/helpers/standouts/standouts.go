@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"log"
 	"time"
+	"twoman/handlers/helpers/discovery"
 	"twoman/schemas"
+	"twoman/utils"
 
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
@@ -282,10 +284,48 @@ func generateAndCacheDuoStandouts(userID uint, limit int, db *gorm.DB, rdb *redi
 	// Log gender preference for debugging
 	log.Printf("Generating duo standouts for user %d with gender preference: '%s'", userID, userProfile.PreferredGender)
 
-	// Get user's location point for distance calculation
+	// Get user's location point for distance calculation, using their travel location while traveling
+	userLocation := userProfile.EffectiveLocation()
 	userLocationWKT := fmt.Sprintf("POINT(%f %f)",
-		userProfile.LocationPoint.Point.Coords()[0],
-		userProfile.LocationPoint.Point.Coords()[1])
+		userLocation.Point.Coords()[0],
+		userLocation.Point.Coords()[1])
+	p1Location, p2Location := discovery.EffectiveLocationSQL("p1"), discovery.EffectiveLocationSQL("p2")
+
+	filters, err := discovery.GetActiveFilters(userID, db)
+	if err != nil {
+		return fmt.Errorf("failed to get discovery filters: %v", err)
+	}
+	if filters == nil {
+		filters = &schemas.DiscoveryFilters{}
+	}
+
+	// Both people in the pair must be open to being shown to this user and match their required filters
+	p1ReciprocalSQL, p1ReciprocalArgs := discovery.ReciprocalPreferenceSQL(userProfile, "p1")
+	p2ReciprocalSQL, p2ReciprocalArgs := discovery.ReciprocalPreferenceSQL(userProfile, "p2")
+	p1FilterSQL, p1FilterArgs := discovery.RequiredFilterSQL(*filters, "p1")
+	p2FilterSQL, p2FilterArgs := discovery.RequiredFilterSQL(*filters, "p2")
+
+	// Dormant, paused, incognito and underage profiles aren't standouts, even in the fallbacks
+	p1ActiveSQL, p1ActiveArgs := discovery.ActiveSQL("p1", time.Now())
+	p2ActiveSQL, p2ActiveArgs := discovery.ActiveSQL("p2", time.Now())
+	p1VisibleSQL, p1VisibleArgs := discovery.VisibleSQL(userID, "p1")
+	p2VisibleSQL, p2VisibleArgs := discovery.VisibleSQL(userID, "p2")
+	p1FilterSQL, p1FilterArgs = p1FilterSQL+" AND "+p1ActiveSQL+" AND "+p1VisibleSQL, append(append(p1FilterArgs, p1ActiveArgs...), p1VisibleArgs...)
+	p2FilterSQL, p2FilterArgs = p2FilterSQL+" AND "+p2ActiveSQL+" AND "+p2VisibleSQL, append(append(p2FilterArgs, p2ActiveArgs...), p2VisibleArgs...)
+	p1FilterSQL, p1FilterArgs = p1FilterSQL+" AND "+utils.AgeSQL("p1.date_of_birth")+" >= ?", append(p1FilterArgs, utils.MinimumAge)
+	p2FilterSQL, p2FilterArgs = p2FilterSQL+" AND "+utils.AgeSQL("p2.date_of_birth")+" >= ?", append(p2FilterArgs, utils.MinimumAge)
+
+	var preferenceArgs []interface{}
+	preferenceArgs = append(preferenceArgs, p1ReciprocalArgs...)
+	preferenceArgs = append(preferenceArgs, p2ReciprocalArgs...)
+	preferenceArgs = append(preferenceArgs, p1FilterArgs...)
+	preferenceArgs = append(preferenceArgs, p2FilterArgs...)
+
+	// Bounding boxes let the spatial index narrow down the pairs before the exact distance check
+	p1NearbySQL, p1NearbyArgs := discovery.NearbySQL(userLocation, float64(userProfile.PreferredDistanceMax), "p1")
+	p2NearbySQL, p2NearbyArgs := discovery.NearbySQL(userLocation, float64(userProfile.PreferredDistanceMax), "p2")
+	nearbyArgs := append(append([]interface{}{}, preferenceArgs...), p1NearbyArgs...)
+	nearbyArgs = append(nearbyArgs, p2NearbyArgs...)
 
 	// Query to find friend pairs within distance, ordered by match count
 	query := `
@@ -307,10 +347,15 @@ func generateAndCacheDuoStandouts(userID uint, limit int, db *gorm.DB, rdb *redi
 		AND f1.profile_id != ?
 		AND f1.friend_id != ?
 		AND f1.profile_id < f1.friend_id -- Avoid duplicates
-		AND (ST_Distance_Sphere(p1.location_point, ST_GeomFromText(?)) <= ? * 1000
-		     OR ST_Distance_Sphere(p2.location_point, ST_GeomFromText(?)) <= ? * 1000)
+		AND (ST_Distance_Sphere(` + p1Location + `, ST_GeomFromText(?)) <= ? * 1000
+		     OR ST_Distance_Sphere(` + p2Location + `, ST_GeomFromText(?)) <= ? * 1000)
 		AND (p1.gender = ? OR ? = '')
 		AND (p2.gender = ? OR ? = '')
+		AND ` + p1ReciprocalSQL + `
+		AND ` + p2ReciprocalSQL + `
+		AND ` + p1FilterSQL + `
+		AND ` + p2FilterSQL + `
+		AND (` + p1NearbySQL + ` OR ` + p2NearbySQL + `)
 		GROUP BY f1.profile_id, f1.friend_id
 		ORDER BY match_count DESC, RAND()
 		LIMIT ?
@@ -323,7 +368,7 @@ func generateAndCacheDuoStandouts(userID uint, limit int, db *gorm.DB, rdb *redi
 	}
 
 	var results []DuoResult
-	if err := db.Raw(query, userID, userID, userLocationWKT, userProfile.PreferredDistanceMax, userLocationWKT, userProfile.PreferredDistanceMax, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender, limit).Scan(&results).Error; err != nil {
+	if err := db.Raw(query, queryArgs([]interface{}{userID, userID, userLocationWKT, userProfile.PreferredDistanceMax, userLocationWKT, userProfile.PreferredDistanceMax, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender}, nearbyArgs, limit)...).Scan(&results).Error; err != nil {
 		return fmt.Errorf("failed to query duo standouts: %v", err)
 	}
 
@@ -345,14 +390,19 @@ func generateAndCacheDuoStandouts(userID uint, limit int, db *gorm.DB, rdb *redi
 			AND f1.profile_id != ?
 			AND f1.friend_id != ?
 			AND f1.profile_id < f1.friend_id -- Avoid duplicates
-			AND (ST_Distance_Sphere(p1.location_point, ST_GeomFromText(?)) <= ? * 1000
-			     OR ST_Distance_Sphere(p2.location_point, ST_GeomFromText(?)) <= ? * 1000)
+			AND (ST_Distance_Sphere(` + p1Location + `, ST_GeomFromText(?)) <= ? * 1000
+			     OR ST_Distance_Sphere(` + p2Location + `, ST_GeomFromText(?)) <= ? * 1000)
 			AND (p1.gender = ? OR ? = '')
 			AND (p2.gender = ? OR ? = '')
+			AND ` + p1ReciprocalSQL + `
+			AND ` + p2ReciprocalSQL + `
+			AND ` + p1FilterSQL + `
+			AND ` + p2FilterSQL + `
+			AND (` + p1NearbySQL + ` OR ` + p2NearbySQL + `)
 			ORDER BY RAND()
 			LIMIT ?
 		`
-		if err := db.Raw(fallbackQuery, userID, userID, userLocationWKT, userProfile.PreferredDistanceMax, userLocationWKT, userProfile.PreferredDistanceMax, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender, limit).Scan(&results).Error; err != nil {
+		if err := db.Raw(fallbackQuery, queryArgs([]interface{}{userID, userID, userLocationWKT, userProfile.PreferredDistanceMax, userLocationWKT, userProfile.PreferredDistanceMax, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender}, nearbyArgs, limit)...).Scan(&results).Error; err != nil {
 			return fmt.Errorf("failed to query fallback duo standouts: %v", err)
 		}
 
@@ -376,10 +426,14 @@ func generateAndCacheDuoStandouts(userID uint, limit int, db *gorm.DB, rdb *redi
 				AND f1.profile_id < f1.friend_id -- Avoid duplicates
 				AND (p1.gender = ? OR ? = '')
 				AND (p2.gender = ? OR ? = '')
+				AND ` + p1ReciprocalSQL + `
+				AND ` + p2ReciprocalSQL + `
+				AND ` + p1FilterSQL + `
+				AND ` + p2FilterSQL + `
 				ORDER BY RAND()
 				LIMIT ?
 			`
-			if err := db.Raw(noDistanceFallbackQuery, userID, userID, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender, limit).Scan(&results).Error; err != nil {
+			if err := db.Raw(noDistanceFallbackQuery, queryArgs([]interface{}{userID, userID, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender}, preferenceArgs, limit)...).Scan(&results).Error; err != nil {
 				return fmt.Errorf("failed to query no-distance fallback duo standouts: %v", err)
 			}
 
@@ -402,10 +456,14 @@ func generateAndCacheDuoStandouts(userID uint, limit int, db *gorm.DB, rdb *redi
 					AND f.profile_id != f.friend_id
 					AND (p1.gender = ? OR ? = '')
 					AND (p2.gender = ? OR ? = '')
+					AND ` + p1ReciprocalSQL + `
+					AND ` + p2ReciprocalSQL + `
+					AND ` + p1FilterSQL + `
+					AND ` + p2FilterSQL + `
 					ORDER BY RAND()
 					LIMIT ?
 				`
-				if err := db.Raw(simpleFriendshipQuery, userID, userID, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender, limit).Scan(&results).Error; err != nil {
+				if err := db.Raw(simpleFriendshipQuery, queryArgs([]interface{}{userID, userID, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender, userProfile.PreferredGender}, preferenceArgs, limit)...).Scan(&results).Error; err != nil {
 					log.Printf("Failed to query simple friendship duo standouts for user %d: %v", userID, err)
 					return fmt.Errorf("failed to query simple friendship duo standouts: %v", err)
 				}
@@ -452,10 +510,36 @@ func generateAndCacheSoloStandouts(userID uint, limit int, db *gorm.DB, rdb *red
 	// Log gender preference for debugging
 	log.Printf("Generating solo standouts for user %d with gender preference: '%s'", userID, userProfile.PreferredGender)
 
-	// Get user's location point for distance calculation
+	// Get user's location point for distance calculation, using their travel location while traveling
+	userLocation := userProfile.EffectiveLocation()
 	userLocationWKT := fmt.Sprintf("POINT(%f %f)",
-		userProfile.LocationPoint.Point.Coords()[0],
-		userProfile.LocationPoint.Point.Coords()[1])
+		userLocation.Point.Coords()[0],
+		userLocation.Point.Coords()[1])
+	p1Location := discovery.EffectiveLocationSQL("p1")
+
+	filters, err := discovery.GetActiveFilters(userID, db)
+	if err != nil {
+		return fmt.Errorf("failed to get discovery filters: %v", err)
+	}
+	if filters == nil {
+		filters = &schemas.DiscoveryFilters{}
+	}
+
+	// Only show profiles whose own preferences accept this user and that match their required filters
+	reciprocalSQL, reciprocalArgs := discovery.ReciprocalPreferenceSQL(userProfile, "p1")
+	filterSQL, filterArgs := discovery.RequiredFilterSQL(*filters, "p1")
+
+	// Dormant, paused, incognito and underage profiles aren't standouts
+	activeSQL, activeArgs := discovery.ActiveSQL("p1", time.Now())
+	visibleSQL, visibleArgs := discovery.VisibleSQL(userID, "p1")
+	filterSQL, filterArgs = filterSQL+" AND "+activeSQL+" AND "+visibleSQL, append(append(filterArgs, activeArgs...), visibleArgs...)
+	filterSQL, filterArgs = filterSQL+" AND "+utils.AgeSQL("p1.date_of_birth")+" >= ?", append(filterArgs, utils.MinimumAge)
+
+	preferenceArgs := append(reciprocalArgs, filterArgs...)
+
+	// A bounding box lets the spatial index narrow down the profiles before the exact distance check
+	nearbySQL, nearbyArgs := discovery.NearbySQL(userLocation, float64(userProfile.PreferredDistanceMax), "p1")
+	preferenceArgs = append(preferenceArgs, nearbyArgs...)
 
 	// First try to get profiles with matches (popularity-based)
 	query := `
@@ -473,8 +557,11 @@ func generateAndCacheSoloStandouts(userID uint, limit int, db *gorm.DB, rdb *red
 			(m.profile4_id = p1.user_id AND m.status = 'accepted')
 		)
 		WHERE p1.user_id != ?
-		AND ST_Distance_Sphere(p1.location_point, ST_GeomFromText(?)) <= ? * 1000
+		AND ST_Distance_Sphere(` + p1Location + `, ST_GeomFromText(?)) <= ? * 1000
 		AND (p1.gender = ? OR ? = '')
+		AND ` + reciprocalSQL + `
+		AND ` + filterSQL + `
+		AND ` + nearbySQL + `
 		GROUP BY p1.user_id
 		ORDER BY popularity_score DESC, RAND()
 		LIMIT ?
@@ -486,7 +573,7 @@ func generateAndCacheSoloStandouts(userID uint, limit int, db *gorm.DB, rdb *red
 	}
 
 	var results []SoloResult
-	if err := db.Raw(query, userID, userLocationWKT, userProfile.PreferredDistanceMax, userProfile.PreferredGender, userProfile.PreferredGender, limit).Scan(&results).Error; err != nil {
+	if err := db.Raw(query, queryArgs([]interface{}{userID, userLocationWKT, userProfile.PreferredDistanceMax, userProfile.PreferredGender, userProfile.PreferredGender}, preferenceArgs, limit)...).Scan(&results).Error; err != nil {
 		return fmt.Errorf("failed to query solo standouts: %v", err)
 	}
 
@@ -498,12 +585,15 @@ func generateAndCacheSoloStandouts(userID uint, limit int, db *gorm.DB, rdb *red
 				0 as popularity_score
 			FROM profiles p1
 			WHERE p1.user_id != ?
-			AND ST_Distance_Sphere(p1.location_point, ST_GeomFromText(?)) <= ? * 1000
+			AND ST_Distance_Sphere(` + p1Location + `, ST_GeomFromText(?)) <= ? * 1000
 			AND (p1.gender = ? OR ? = '')
+			AND ` + reciprocalSQL + `
+			AND ` + filterSQL + `
+			AND ` + nearbySQL + `
 			ORDER BY RAND()
 			LIMIT ?
 		`
-		if err := db.Raw(fallbackQuery, userID, userLocationWKT, userProfile.PreferredDistanceMax, userProfile.PreferredGender, userProfile.PreferredGender, limit).Scan(&results).Error; err != nil {
+		if err := db.Raw(fallbackQuery, queryArgs([]interface{}{userID, userLocationWKT, userProfile.PreferredDistanceMax, userProfile.PreferredGender, userProfile.PreferredGender}, preferenceArgs, limit)...).Scan(&results).Error; err != nil {
 			return fmt.Errorf("failed to query fallback solo standouts: %v", err)
 		}
 	}
@@ -534,6 +624,12 @@ func generateAndCacheSoloStandouts(userID uint, limit int, db *gorm.DB, rdb *red
 	return nil
 }
 
+// queryArgs appends the preference and filter args and the limit after a standouts query's own args
+func queryArgs(args []interface{}, preferenceArgs []interface{}, limit int) []interface{} {
+	args = append(args, preferenceArgs...)
+	return append(args, limit)
+}
+
 // convertDuoRedisToResponse converts Redis duo data to API response format
 func convertDuoRedisToResponse(duoData DuoStandoutsRedis, db *gorm.DB) ([]schemas.DuoStandouts, error) {
 	var standouts []schemas.DuoStandouts
@@ -572,6 +668,11 @@ func convertDuoRedisToResponse(duoData DuoStandoutsRedis, db *gorm.DB) ([]schema
 			continue
 		}
 
+		// Skip pairs where someone went incognito or paused since the standouts were cached
+		if standout.Profile1.Visibility != schemas.VisibilityVisible || standout.Profile2.Visibility != schemas.VisibilityVisible {
+			continue
+		}
+
 		standouts = append(standouts, standout)
 	}
 
@@ -604,6 +705,11 @@ func convertSoloRedisToResponse(soloData SoloStandoutsRedis, db *gorm.DB) ([]sch
 			continue
 		}
 
+		// Skip profiles that went incognito or paused since the standouts were cached
+		if standout.Profile.Visibility != schemas.VisibilityVisible {
+			continue
+		}
+
 		standouts = append(standouts, standout)
 	}
 
@@ -133,7 +133,18 @@ func DowngradeUserFromPro(userID uint, db *gorm.DB) error {
 	}
 
 	// Remove legacy PaidUser record
-	return db.Where("user_id = ?", userID).Delete(&schemas.PaidUser{}).Error
+	if err := db.Where("user_id = ?", userID).Delete(&schemas.PaidUser{}).Error; err != nil {
+		return err
+	}
+
+	return endIncognito(userID, db)
+}
+
+// endIncognito makes an incognito profile visible again once its pro subscription ends
+func endIncognito(userID uint, db *gorm.DB) error {
+	return db.Model(&schemas.Profile{}).
+		Where("user_id = ? AND visibility = ?", userID, schemas.VisibilityIncognito).
+		Update("visibility", schemas.VisibilityVisible).Error
 }
 
 // DeactivateExistingSubscriptions marks all user subscriptions as inactive
@@ -170,6 +181,10 @@ func CleanupExpiredSubscriptions(db *gorm.DB) error {
 
 		if activeCount == 0 {
 			db.Where("user_id = ?", sub.UserID).Delete(&schemas.PaidUser{})
+
+			if err := endIncognito(sub.UserID, db); err != nil {
+				log.Printf("Failed to end incognito for user %d: %v", sub.UserID, err)
+			}
 		}
 	}
 
@@ -2,11 +2,13 @@ package user
 
 import (
 	"errors"
+	"twoman/handlers/helpers/completeness"
 	"twoman/handlers/helpers/notifications"
 	"twoman/handlers/helpers/profile"
 	"twoman/schemas"
 
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/redis/go-redis/v9"
 
 	"gorm.io/gorm"
 )
@@ -21,6 +23,28 @@ func GetUserByID(id uint, db *gorm.DB) (*schemas.User, error) {
 	return user, nil
 }
 
+// Self is the signed in user along with how complete their profile is. Completeness is left out until they create
+// a profile.
+type Self struct {
+	*schemas.User
+	Completeness *completeness.Result `json:"completeness,omitempty"`
+}
+
+// GetSelf returns the signed in user with their profile completeness
+func GetSelf(id uint, db *gorm.DB) (*Self, error) {
+	user, err := GetUserByID(id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := completeness.ForProfile(id, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Self{User: user, Completeness: result}, nil
+}
+
 // GetUserByPhoneNumber returns a user by their phone number
 func GetUserByPhoneNumber(phoneNumber string, db *gorm.DB) (*schemas.User, error) {
 	var user *schemas.User
@@ -90,8 +114,8 @@ func FindUserByEmail(email string, db *gorm.DB) (*schemas.User, error) {
 	return &user, nil
 }
 
-func DeleteUser(userId uint, db *gorm.DB, s3Client *s3.S3) error {
-	if err := profile.DeleteProfile(userId, db, s3Client); err != nil {
+func DeleteUser(userId uint, db *gorm.DB, s3Client *s3.S3, rdb *redis.Client) error {
+	if err := profile.DeleteProfile(userId, db, s3Client, rdb); err != nil {
 		return err
 	}
 
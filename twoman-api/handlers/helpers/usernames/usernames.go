@@ -0,0 +1,216 @@
+package usernames
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"twoman/schemas"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MinLength is the shortest username that can be chosen
+	MinLength = 3
+	// MaxLength is the longest username that can be chosen
+	MaxLength = 50
+	// ChangeCooldown is the least time between two username changes made by the user
+	ChangeCooldown = 30 * 24 * time.Hour
+	// ReservationPeriod is how long an old username keeps pointing at the profile that gave it up
+	ReservationPeriod = 14 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidUsername = fmt.Errorf("usernames must be %d to %d letters, numbers, dots or underscores", MinLength, MaxLength)
+	ErrUsernameTaken   = errors.New("username is taken")
+	ErrUnchanged       = errors.New("username is the same as the current one")
+	ErrChangeTooSoon   = errors.New("username was changed too recently")
+	ErrProfileNotFound = errors.New("profile not found")
+)
+
+var validUsername = regexp.MustCompile(`^[A-Za-z0-9._]+$`)
+
+// Status is a profile's current username and when the user can next change it
+type Status struct {
+	Username      string     `json:"username"`
+	LastChangedAt *time.Time `json:"last_changed_at,omitempty"`
+	NextChangeAt  *time.Time `json:"next_change_at,omitempty"`
+}
+
+// Validate trims a username and checks its length and characters
+func Validate(username string) (string, error) {
+	username = strings.TrimSpace(username)
+	if len(username) < MinLength || len(username) > MaxLength || !validUsername.MatchString(username) {
+		return "", ErrInvalidUsername
+	}
+	return username, nil
+}
+
+// Available reports whether a profile can take a username. Usernames held by another profile, or given up by another
+// profile less than ReservationPeriod ago, are not available. Pass a profileID of 0 to check for anyone.
+func Available(username string, profileID uint, db *gorm.DB) (bool, error) {
+	var taken int64
+	if err := db.Model(&schemas.Profile{}).Where("username = ? AND user_id != ?", username, profileID).
+		Count(&taken).Error; err != nil {
+		return false, err
+	}
+	if taken > 0 {
+		return false, nil
+	}
+
+	var reserved int64
+	if err := db.Model(&schemas.UsernameChange{}).
+		Where("old_username = ? AND profile_id != ? AND reserved_until > ?", username, profileID, time.Now()).
+		Count(&reserved).Error; err != nil {
+		return false, err
+	}
+
+	return reserved == 0, nil
+}
+
+// Resolve finds the profile a username points at. A username given up less than ReservationPeriod ago resolves to
+// the profile that gave it up. It returns gorm.ErrRecordNotFound when nothing matches.
+func Resolve(username string, db *gorm.DB) (*schemas.Profile, error) {
+	var profile schemas.Profile
+	if err := db.Where("username = ?", username).Limit(1).Find(&profile).Error; err != nil {
+		return nil, err
+	}
+	if profile.UserID != 0 {
+		return &profile, nil
+	}
+
+	var change schemas.UsernameChange
+	if err := db.Where("old_username = ? AND reserved_until > ?", username, time.Now()).
+		Order("created_at DESC").Limit(1).Find(&change).Error; err != nil {
+		return nil, err
+	}
+	if change.ID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	if err := db.Where("user_id = ?", change.ProfileID).First(&profile).Error; err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+// GetStatus returns a profile's username and when the user can next change it
+func GetStatus(profileID uint, db *gorm.DB) (*Status, error) {
+	var profile schemas.Profile
+	if err := db.Select("user_id", "username").Where("user_id = ?", profileID).Limit(1).Find(&profile).Error; err != nil {
+		return nil, err
+	}
+	if profile.UserID == 0 {
+		return nil, ErrProfileNotFound
+	}
+
+	status := Status{Username: profile.Username}
+
+	last, err := lastUserChange(profileID, db)
+	if err != nil {
+		return nil, err
+	}
+	if last.ID != 0 {
+		next := last.CreatedAt.Add(ChangeCooldown)
+		status.LastChangedAt = &last.CreatedAt
+		if next.After(time.Now()) {
+			status.NextChangeAt = &next
+		}
+	}
+
+	return &status, nil
+}
+
+// Change sets a new username chosen by the user, at most once per ChangeCooldown
+func Change(profileID uint, username string, db *gorm.DB) (*Status, error) {
+	if err := change(profileID, username, false, db); err != nil {
+		return nil, err
+	}
+	return GetStatus(profileID, db)
+}
+
+// AdminChange sets a new username without the cooldown. It still can't take a username someone else holds or has
+// reserved. Pass the caller's transaction so the change is rolled back with the rest of an admin update.
+func AdminChange(profileID uint, username string, db *gorm.DB) error {
+	return change(profileID, username, true, db)
+}
+
+// History lists a profile's username changes, newest first
+func History(profileID uint, db *gorm.DB) ([]schemas.UsernameChange, error) {
+	history := []schemas.UsernameChange{}
+	if err := db.Where("profile_id = ?", profileID).Order("created_at DESC").Find(&history).Error; err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// DeleteAll removes a profile's username history, releasing its reserved usernames
+func DeleteAll(profileID uint, db *gorm.DB) error {
+	return db.Where("profile_id = ?", profileID).Delete(&schemas.UsernameChange{}).Error
+}
+
+func change(profileID uint, username string, byAdmin bool, db *gorm.DB) error {
+	username, err := Validate(username)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var profile schemas.Profile
+		if err := tx.Select("user_id", "username").Where("user_id = ?", profileID).Limit(1).Find(&profile).Error; err != nil {
+			return err
+		}
+		if profile.UserID == 0 {
+			return ErrProfileNotFound
+		}
+		if profile.Username == username {
+			return ErrUnchanged
+		}
+
+		if !byAdmin {
+			last, err := lastUserChange(profileID, tx)
+			if err != nil {
+				return err
+			}
+			if last.ID != 0 && time.Since(last.CreatedAt) < ChangeCooldown {
+				return ErrChangeTooSoon
+			}
+		}
+
+		available, err := Available(username, profileID, tx)
+		if err != nil {
+			return err
+		}
+		if !available {
+			return ErrUsernameTaken
+		}
+
+		if err := tx.Model(&schemas.Profile{}).Where("user_id = ?", profileID).Update("username", username).Error; err != nil {
+			return err
+		}
+
+		if profile.Username == "" {
+			return nil
+		}
+
+		return tx.Create(&schemas.UsernameChange{
+			ProfileID:      profileID,
+			OldUsername:    profile.Username,
+			NewUsername:    username,
+			ChangedByAdmin: byAdmin,
+			ReservedUntil:  time.Now().Add(ReservationPeriod),
+		}).Error
+	})
+}
+
+// lastUserChange returns the latest change the user made themselves, or an empty change if there isn't one. Admin
+// changes don't start the cooldown.
+func lastUserChange(profileID uint, db *gorm.DB) (schemas.UsernameChange, error) {
+	var last schemas.UsernameChange
+	err := db.Where("profile_id = ? AND changed_by_admin = ?", profileID, false).
+		Order("created_at DESC").Limit(1).Find(&last).Error
+	return last, err
+}
@@ -0,0 +1,305 @@
+package verification
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+	"twoman/handlers/helpers/photos"
+	"twoman/schemas"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SELFIE_URL_TTL is how long the signed selfie links in the admin review queue work
+const SELFIE_URL_TTL = 15 * time.Minute
+
+// Poses are the gestures users are asked to copy in their selfie
+var Poses = []string{
+	"Give a thumbs up next to your face",
+	"Touch your nose with your index finger",
+	"Hold up three fingers next to your face",
+	"Cover one eye with your hand",
+	"Make a peace sign under your chin",
+	"Put your hand flat on top of your head",
+	"Point at the camera",
+	"Touch your ear with your thumb",
+}
+
+var (
+	ErrNotConfigured   = errors.New("verification storage is not configured")
+	ErrAlreadyVerified = errors.New("profile is already verified")
+	ErrUnderReview     = errors.New("a selfie is already waiting for review")
+	ErrNoPose          = errors.New("start a verification to get a pose first")
+	ErrRequestNotFound = errors.New("verification request not found")
+	ErrInvalidStatus   = errors.New("status must be approved or rejected")
+	ErrAlreadyReviewed = errors.New("verification request is not waiting for review")
+	ErrReasonRequired  = errors.New("a reason is required to reject a selfie")
+)
+
+// Review is a submitted selfie with the profile it should match, for admins to compare
+type Review struct {
+	Request   schemas.VerificationRequest `json:"request"`
+	SelfieURL string                      `json:"selfie_url"`
+	Profile   *schemas.Profile            `json:"profile"`
+}
+
+// bucket is the private bucket selfies are stored in, kept apart from the public profile photos
+func bucket() string {
+	return os.Getenv("AWS_VERIFICATION_BUCKET_NAME")
+}
+
+// Latest returns the user's most recent verification request, or nil when they never started one
+func Latest(userID uint, db *gorm.DB) (*schemas.VerificationRequest, error) {
+	var request schemas.VerificationRequest
+	if err := db.Where("user_id = ?", userID).Order("id DESC").Limit(1).Find(&request).Error; err != nil {
+		return nil, err
+	}
+
+	if request.ID == 0 {
+		return nil, nil
+	}
+
+	return &request, nil
+}
+
+// Start assigns the user a random pose. A request still waiting for its selfie is returned as is, so asking again
+// doesn't let users pick the easiest pose.
+func Start(userID uint, db *gorm.DB) (*schemas.VerificationRequest, error) {
+	latest, err := Latest(userID, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if latest != nil {
+		switch latest.Status {
+		case schemas.VerificationAwaitingSelfie:
+			return latest, nil
+		case schemas.VerificationPending:
+			return nil, ErrUnderReview
+		case schemas.VerificationApproved:
+			// Changing the gallery clears the badge, after which the user can verify again
+			var profile schemas.Profile
+			if err := db.Select("user_id", "verified").Where("user_id = ?", userID).Limit(1).Find(&profile).Error; err != nil {
+				return nil, err
+			}
+			if profile.Verified {
+				return nil, ErrAlreadyVerified
+			}
+		}
+	}
+
+	request := schemas.VerificationRequest{
+		UserID: userID,
+		Pose:   Poses[rand.Intn(len(Poses))],
+		Status: schemas.VerificationAwaitingSelfie,
+	}
+
+	if err := db.Create(&request).Error; err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// SubmitSelfie stores a processed selfie for the user's assigned pose and queues it for review
+func SubmitSelfie(userID uint, selfie []byte, db *gorm.DB, s3Client *s3.S3) (*schemas.VerificationRequest, error) {
+	if bucket() == "" {
+		return nil, ErrNotConfigured
+	}
+
+	request, err := Latest(userID, db)
+	if err != nil {
+		return nil, err
+	}
+
+	if request == nil || request.Status == schemas.VerificationRejected {
+		return nil, ErrNoPose
+	}
+	if request.Status == schemas.VerificationPending {
+		return nil, ErrUnderReview
+	}
+	if request.Status == schemas.VerificationApproved {
+		return nil, ErrAlreadyVerified
+	}
+
+	key := fmt.Sprintf("verification/%d/%s.jpg", userID, uuid.New().String())
+
+	_, err = s3Client.PutObject(&s3.PutObjectInput{
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(selfie),
+		Bucket:      aws.String(bucket()),
+		ContentType: aws.String("image/jpeg"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload selfie: %w", err)
+	}
+
+	now := time.Now()
+	request.SelfieKey = key
+	request.Status = schemas.VerificationPending
+	request.SubmittedAt = &now
+
+	if err := db.Model(request).Updates(map[string]interface{}{
+		"selfie_key":   key,
+		"status":       schemas.VerificationPending,
+		"submitted_at": now,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// ListPending returns the selfies waiting for review, oldest first, with signed links and the profile's photos
+func ListPending(db *gorm.DB, s3Client *s3.S3) ([]Review, error) {
+	var requests []schemas.VerificationRequest
+	if err := db.Where("status = ?", schemas.VerificationPending).Order("submitted_at").Find(&requests).Error; err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]uint, 0, len(requests))
+	for _, request := range requests {
+		userIDs = append(userIDs, request.UserID)
+	}
+
+	var profiles []schemas.Profile
+	if len(userIDs) > 0 {
+		if err := db.Where("user_id IN ?", userIDs).Find(&profiles).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	withPhotos := make([]*schemas.Profile, 0, len(profiles))
+	profilesByID := make(map[uint]*schemas.Profile, len(profiles))
+	for i := range profiles {
+		withPhotos = append(withPhotos, &profiles[i])
+		profilesByID[profiles[i].UserID] = &profiles[i]
+	}
+
+	if err := photos.Attach(withPhotos, db); err != nil {
+		return nil, err
+	}
+
+	reviews := make([]Review, 0, len(requests))
+	for _, request := range requests {
+		signed, _ := s3Client.GetObjectRequest(&s3.GetObjectInput{
+			Key:    aws.String(request.SelfieKey),
+			Bucket: aws.String(bucket()),
+		})
+
+		selfieURL, err := signed.Presign(SELFIE_URL_TTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sign selfie url: %w", err)
+		}
+
+		reviews = append(reviews, Review{
+			Request:   request,
+			SelfieURL: selfieURL,
+			Profile:   profilesByID[request.UserID],
+		})
+	}
+
+	return reviews, nil
+}
+
+// Decide records an admin's review. Approving sets the verified badge; either way the selfie is deleted since
+// it is no longer needed.
+func Decide(requestID uint, status string, reason string, adminID uint, db *gorm.DB, s3Client *s3.S3) (*schemas.VerificationRequest, error) {
+	if status != schemas.VerificationApproved && status != schemas.VerificationRejected {
+		return nil, ErrInvalidStatus
+	}
+	if status == schemas.VerificationRejected && reason == "" {
+		return nil, ErrReasonRequired
+	}
+
+	var request schemas.VerificationRequest
+	if err := db.First(&request, requestID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRequestNotFound
+		}
+		return nil, err
+	}
+
+	if request.Status != schemas.VerificationPending {
+		return nil, ErrAlreadyReviewed
+	}
+
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		updated := tx.Model(&schemas.VerificationRequest{}).Where("id = ? AND status = ?", request.ID, schemas.VerificationPending).
+			Updates(map[string]interface{}{
+				"status":           status,
+				"rejection_reason": reason,
+				"reviewed_by":      adminID,
+				"reviewed_at":      now,
+			})
+		if updated.Error != nil {
+			return updated.Error
+		}
+		if updated.RowsAffected == 0 {
+			return ErrAlreadyReviewed
+		}
+
+		if status != schemas.VerificationApproved {
+			return nil
+		}
+
+		if err := tx.Model(&schemas.Profile{}).Where("user_id = ?", request.UserID).Update("verified", true).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&schemas.User{}).Where("id = ?", request.UserID).Update("verified", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request.Status = status
+	request.RejectionReason = reason
+	request.ReviewedBy = &adminID
+	request.ReviewedAt = &now
+
+	if err := deleteSelfie(request.SelfieKey, s3Client); err != nil {
+		return nil, err
+	}
+
+	return &request, nil
+}
+
+// DeleteAll removes a user's verification requests and any selfie still waiting for review
+func DeleteAll(userID uint, db *gorm.DB, s3Client *s3.S3) error {
+	var requests []schemas.VerificationRequest
+	if err := db.Where("user_id = ? AND status = ?", userID, schemas.VerificationPending).Find(&requests).Error; err != nil {
+		return err
+	}
+
+	for _, request := range requests {
+		if err := deleteSelfie(request.SelfieKey, s3Client); err != nil {
+			return err
+		}
+	}
+
+	return db.Where("user_id = ?", userID).Delete(&schemas.VerificationRequest{}).Error
+}
+
+func deleteSelfie(key string, s3Client *s3.S3) error {
+	if key == "" || bucket() == "" {
+		return nil
+	}
+
+	_, err := s3Client.DeleteObject(&s3.DeleteObjectInput{
+		Key:    aws.String(key),
+		Bucket: aws.String(bucket()),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete selfie: %w", err)
+	}
+
+	return nil
+}
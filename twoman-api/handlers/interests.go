@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"twoman/globals"
+	"twoman/handlers/helpers/interests"
+	"twoman/handlers/response"
+	"twoman/types"
+)
+
+func (h Handler) HandleGetInterests() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			catalog, err := interests.Catalog(false, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			response.OKWithData(w, "Successfully got interests", catalog)
+		}
+	})
+}
+
+func (h Handler) HandleGetProfileInterests() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			selected, err := interests.List(session.UserID, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			response.OKWithData(w, "Successfully got profile interests", selected)
+		}
+	})
+}
+
+func (h Handler) HandleSetProfileInterests() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			var request types.SetProfileInterestsRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			selected, err := interests.Set(session.UserID, request.InterestIDs, h.DB(r))
+
+			if err != nil {
+				interestErrorResponse(w, err)
+				return
+			}
+
+			response.OKWithData(w, "Successfully updated profile interests", selected)
+		}
+	})
+}
+
+func (h Handler) HandleAdminGetInterests() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		catalog, err := interests.Catalog(true, h.DB(r))
+
+		if err != nil {
+			response.InternalServerError(w, err, "Could not get interests")
+			return
+		}
+
+		response.OKWithData(w, "Successfully got interests", catalog)
+	})
+}
+
+func (h Handler) HandleAdminCreateInterest() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requestBody types.AdminCreateInterestRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+
+		interest, err := interests.CreateInterest(requestBody.Name, requestBody.Category, h.DB(r))
+
+		if err != nil {
+			interestErrorResponse(w, err)
+			return
+		}
+
+		response.OKWithData(w, "Successfully created interest", interest)
+	})
+}
+
+func (h Handler) HandleAdminUpdateInterest() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		interestId, err := strconv.ParseUint(r.PathValue("interestId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid interest id")
+			return
+		}
+
+		var requestBody types.AdminUpdateInterestRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+
+		interest, err := interests.UpdateInterest(uint(interestId), requestBody.Name, requestBody.CategoryID, requestBody.Active, h.DB(r))
+
+		if err != nil {
+			interestErrorResponse(w, err)
+			return
+		}
+
+		response.OKWithData(w, "Successfully updated interest", interest)
+	})
+}
+
+// validInterestNames writes a bad request when a comma separated interests value has too many or too long names
+func validInterestNames(w http.ResponseWriter, value string) bool {
+	names := interests.Split(value)
+
+	if len(names) > interests.MaxInterests {
+		response.BadRequest(w, fmt.Sprintf("A profile can have at most %d interests", interests.MaxInterests))
+		return false
+	}
+
+	for _, name := range names {
+		if len(name) > interests.MaxNameLength {
+			response.BadRequest(w, fmt.Sprintf("Interests must be less than %d characters", interests.MaxNameLength))
+			return false
+		}
+	}
+
+	return true
+}
+
+// interestErrorResponse turns interest catalog and selection errors into client errors
+func interestErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, interests.ErrInterestNotFound):
+		response.NotFound(w, "Interest not found")
+	case errors.Is(err, interests.ErrCategoryNotFound):
+		response.NotFound(w, "Interest category not found")
+	case errors.Is(err, interests.ErrTooManyInterests), errors.Is(err, interests.ErrUnknownInterest),
+		errors.Is(err, interests.ErrDuplicateName), errors.Is(err, interests.ErrInvalidName):
+		response.BadRequest(w, err.Error())
+	default:
+		response.InternalServerError(w, err, "Something went wrong")
+	}
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"twoman/globals"
+	"twoman/handlers/helpers/duplicates"
+	"twoman/handlers/helpers/photos"
+	"twoman/handlers/response"
+	"twoman/types"
+)
+
+func (h Handler) HandleGetProfilePhotos() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			profilePhotos, err := photos.List(session.UserID, true, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			response.OKWithData(w, "Successfully got photos", profilePhotos)
+		}
+	})
+}
+
+func (h Handler) HandleAddProfilePhoto() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			var request types.AddProfilePhotoRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			if request.URL == "" {
+				response.BadRequest(w, "URL is required")
+				return
+			}
+
+			caption := strings.TrimSpace(request.Caption)
+			if len(caption) > photos.MaxCaptionLength {
+				response.BadRequest(w, fmt.Sprintf("Caption must be less than %d characters", photos.MaxCaptionLength))
+				return
+			}
+
+			photo, err := photos.Add(session.UserID, request.URL, caption, h.DB(r))
+
+			if err != nil {
+				photoErrorResponse(w, err)
+				return
+			}
+
+			response.OKWithData(w, "Successfully added photo", photo)
+		}
+	})
+}
+
+func (h Handler) HandleUpdateProfilePhoto() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		photoId, err := strconv.ParseUint(r.PathValue("photoId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid photo id")
+			return
+		}
+
+		switch clientVersion {
+
+		default:
+			var request types.UpdateProfilePhotoRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			if request.Caption != nil {
+				caption := strings.TrimSpace(*request.Caption)
+				if len(caption) > photos.MaxCaptionLength {
+					response.BadRequest(w, fmt.Sprintf("Caption must be less than %d characters", photos.MaxCaptionLength))
+					return
+				}
+				request.Caption = &caption
+			}
+
+			photo, err := photos.Update(session.UserID, uint(photoId), request.Caption, request.IsPrimary, h.DB(r))
+
+			if err != nil {
+				photoErrorResponse(w, err)
+				return
+			}
+
+			response.OKWithData(w, "Successfully updated photo", photo)
+		}
+	})
+}
+
+func (h Handler) HandleReorderProfilePhotos() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			var request types.ReorderProfilePhotosRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			profilePhotos, err := photos.Reorder(session.UserID, request.PhotoIDs, h.DB(r))
+
+			if err != nil {
+				photoErrorResponse(w, err)
+				return
+			}
+
+			response.OKWithData(w, "Successfully reordered photos", profilePhotos)
+		}
+	})
+}
+
+func (h Handler) HandleDeleteProfilePhoto() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		photoId, err := strconv.ParseUint(r.PathValue("photoId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid photo id")
+			return
+		}
+
+		switch clientVersion {
+
+		default:
+			if err := photos.Remove(session.UserID, uint(photoId), h.DB(r), h.s3); err != nil {
+				photoErrorResponse(w, err)
+				return
+			}
+
+			response.OK(w, "Successfully deleted photo")
+		}
+	})
+}
+
+func (h Handler) HandleAdminModeratePhoto() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		photoId, err := strconv.ParseUint(r.PathValue("photoId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid photo id")
+			return
+		}
+
+		var requestBody types.AdminModeratePhotoRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+
+		photo, err := photos.SetModerationStatus(uint(photoId), requestBody.Status, h.DB(r))
+
+		if err != nil {
+			photoErrorResponse(w, err)
+			return
+		}
+
+		response.OKWithData(w, "Successfully moderated photo", photo)
+	})
+}
+
+func (h Handler) HandleAdminGetDuplicatePhotos() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reviews, err := duplicates.ListPending(h.DB(r))
+
+		if err != nil {
+			response.InternalServerError(w, err, "Could not get duplicate photos")
+			return
+		}
+
+		response.OKWithData(w, "Successfully got duplicate photos", reviews)
+	})
+}
+
+func (h Handler) HandleAdminResolveDuplicatePhoto() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminId := r.Context().Value(globals.AdminMiddlewareKey).(uint)
+
+		flagId, err := strconv.ParseUint(r.PathValue("flagId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid flag id")
+			return
+		}
+
+		var requestBody types.AdminResolveDuplicatePhotoRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+
+		flag, err := duplicates.Resolve(uint(flagId), requestBody.Status, adminId, h.DB(r))
+
+		if err != nil {
+			switch {
+			case errors.Is(err, duplicates.ErrFlagNotFound):
+				response.NotFound(w, "Flag not found")
+			case errors.Is(err, duplicates.ErrInvalidStatus):
+				response.BadRequest(w, err.Error())
+			default:
+				response.InternalServerError(w, err, "Something went wrong")
+			}
+			return
+		}
+
+		response.OKWithData(w, "Successfully resolved duplicate photo", flag)
+	})
+}
+
+// photoErrorResponse turns gallery validation errors into client errors
+func photoErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, photos.ErrPhotoNotFound):
+		response.NotFound(w, "Photo not found")
+	case errors.Is(err, photos.ErrTooManyPhotos):
+		response.BadRequest(w, fmt.Sprintf("A profile can have at most %d photos", photos.MaxPhotos()))
+	case errors.Is(err, photos.ErrDuplicatePhoto):
+		response.Conflict(w, err.Error())
+	case errors.Is(err, photos.ErrLastPhoto), errors.Is(err, photos.ErrFileNotOwned),
+		errors.Is(err, photos.ErrInvalidOrder), errors.Is(err, photos.ErrInvalidStatus), errors.Is(err, photos.ErrNoVisiblePhoto),
+		errors.Is(err, photos.ErrInvalidPhoto):
+		response.BadRequest(w, err.Error())
+	default:
+		response.InternalServerError(w, err, "Something went wrong")
+	}
+}
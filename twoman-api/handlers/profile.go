@@ -2,20 +2,34 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 	"twoman/globals"
+	"twoman/handlers/helpers/attributes"
+	"twoman/handlers/helpers/birthdate"
+	"twoman/handlers/helpers/deck"
+	"twoman/handlers/helpers/discovery"
 	"twoman/handlers/helpers/friendship"
+	"twoman/handlers/helpers/geocoder"
+	"twoman/handlers/helpers/interests"
 	"twoman/handlers/helpers/matches"
+	"twoman/handlers/helpers/photos"
 	"twoman/handlers/helpers/profile"
+	"twoman/handlers/helpers/prompts"
+	"twoman/handlers/helpers/seen"
 	"twoman/handlers/helpers/socket"
+	"twoman/handlers/helpers/subscription"
 	"twoman/handlers/response"
 	"twoman/schemas"
 	"twoman/types"
-	"twoman/utils"
+
+	"gorm.io/gorm"
 )
 
 func (h Handler) HandleCreateProfile() http.Handler {
@@ -110,8 +124,7 @@ func (h Handler) HandleCreateProfile() http.Handler {
 				return
 			}
 
-			if len(request.Interests) > 50 {
-				response.BadRequest(w, "Interests must be less than 50 characters")
+			if !validInterestNames(w, request.Interests) {
 				return
 			}
 
@@ -130,7 +143,18 @@ func (h Handler) HandleCreateProfile() http.Handler {
 				return
 			}
 
-			city, err := profile.GetCity(request.Lat, request.Lon, h.maps)
+			available, err := profile.CheckUsernameAvailability(request.Username, session.UserID, h.DB(r))
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			if !available {
+				response.Conflict(w, "Username is taken")
+				return
+			}
+
+			city, err := geocoder.CityOrEmpty(h.geocoder, request.Lat, request.Lon)
 
 			if err != nil {
 				log.Println(err)
@@ -145,10 +169,8 @@ func (h Handler) HandleCreateProfile() http.Handler {
 				return
 			}
 
-			minAge := 18
-			minBirthDate := time.Now().AddDate(-minAge, 0, 0)
-			if parsedDateOfBirth.After(minBirthDate) {
-				response.BadRequest(w, "User must be at least 18 years old")
+			if err := birthdate.Validate(parsedDateOfBirth); err != nil {
+				birthdateErrorResponse(w, err)
 				return
 			}
 
@@ -158,15 +180,6 @@ func (h Handler) HandleCreateProfile() http.Handler {
 				return
 			}
 
-			newImagesRaw := []string{request.Image1, request.Image2, request.Image3, request.Image4}
-			newImages := utils.CompactImages(newImagesRaw)
-
-			// Update the request with the compacted images
-			request.Image1 = newImages[0]
-			request.Image2 = newImages[1]
-			request.Image3 = newImages[2]
-			request.Image4 = newImages[3]
-
 			newProfile := schemas.Profile{
 				UserID:               session.UserID,
 				Name:                 request.Name,
@@ -179,18 +192,28 @@ func (h Handler) HandleCreateProfile() http.Handler {
 				Education:            "",
 				Occupation:           "",
 				Interests:            request.Interests,
-				Image1:               request.Image1,
-				Image2:               request.Image2,
-				Image3:               request.Image3,
-				Image4:               request.Image4,
 				PreferredGender:      request.PreferredGender,
 				PreferredAgeMin:      request.PreferredAgeMin,
 				PreferredAgeMax:      request.PreferredAgeMax,
 				PreferredDistanceMax: request.PreferredDistanceMax,
 			}
 
-			if err := profile.CreateProfileWithData(newProfile, h.DB(r)); err != nil {
-				response.InternalServerError(w, err, "Something went wrong")
+			err = h.DB(r).Transaction(func(tx *gorm.DB) error {
+				if err := profile.CreateProfileWithData(newProfile, tx); err != nil {
+					return err
+				}
+
+				// The images become the start of the photo gallery, which fills in Image1..Image4. A new profile
+				// has no photos to replace.
+				if _, err := photos.SetImages(session.UserID, []string{request.Image1, request.Image2, request.Image3, request.Image4}, tx); err != nil {
+					return err
+				}
+
+				_, err := interests.SetFromNames(session.UserID, request.Interests, tx)
+				return err
+			})
+			if err != nil {
+				photoErrorResponse(w, err)
 				return
 			}
 
@@ -232,11 +255,6 @@ func (h Handler) HandleUpdateProfile() http.Handler {
 				return
 			}
 
-			if request.Image1 == "" {
-				response.BadRequest(w, "Image1 is required")
-				return
-			}
-
 			if request.PreferredGender == "" {
 				response.BadRequest(w, "Preferred Gender is required")
 				return
@@ -283,8 +301,7 @@ func (h Handler) HandleUpdateProfile() http.Handler {
 				}
 			}
 
-			if len(request.Interests) > 50 {
-				response.BadRequest(w, "Interests must be less than 50 characters")
+			if !validInterestNames(w, request.Interests) {
 				return
 			}
 
@@ -307,10 +324,19 @@ func (h Handler) HandleUpdateProfile() http.Handler {
 			request.Bio = trimmedBio
 
 			if err := profile.UpdateProfile(request, session.UserID, h.DB(r), h.s3); err != nil {
-				response.InternalServerError(w, err, "Something went wrong")
+				if errors.Is(err, attributes.ErrUnknownAttribute) || errors.Is(err, attributes.ErrInvalidValue) {
+					attributeErrorResponse(w, err)
+					return
+				}
+				photoErrorResponse(w, err)
 				return
 			}
 
+			// Preferences or location may have changed, so the cached deck is no longer ranked correctly
+			if err := deck.ResetDeck(session.UserID, h.rdb); err != nil {
+				log.Println("Error resetting discovery deck:", err)
+			}
+
 			response.OK(w, "OK")
 		}
 	})
@@ -343,7 +369,7 @@ func (h Handler) HandleUpdateProfileLocation() http.Handler {
 				return
 			}
 
-			city, err := profile.GetCity(request.Lat, request.Lon, h.maps)
+			city, err := geocoder.CityOrEmpty(h.geocoder, request.Lat, request.Lon)
 
 			if err != nil {
 				log.Println(err)
@@ -356,11 +382,180 @@ func (h Handler) HandleUpdateProfileLocation() http.Handler {
 				return
 			}
 
+			// Preferences or location may have changed, so the cached deck is no longer ranked correctly
+			if err := deck.ResetDeck(session.UserID, h.rdb); err != nil {
+				log.Println("Error resetting discovery deck:", err)
+			}
+
 			response.OK(w, "OK")
 		}
 	})
 }
 
+// Travel mode limits. Setting TRAVEL_MODE_PRO_ONLY=true restricts travel mode to pro users.
+const (
+	defaultTravelDuration = 7 * 24 * time.Hour
+	maxTravelDuration     = 30 * 24 * time.Hour
+)
+
+func (h Handler) HandleSetTravelLocation() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+
+			var request types.SetTravelLocationRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			// 0 is a real latitude and longitude, so only a missing coordinate counts as no location
+			if request.Lat == nil || request.Lon == nil {
+				response.BadRequest(w, "Location is required")
+				return
+			}
+
+			lat, lon := *request.Lat, *request.Lon
+			if !schemas.NewPoint(lat, lon).Valid() {
+				response.BadRequest(w, "Invalid location")
+				return
+			}
+
+			expiresAt := time.Now().Add(defaultTravelDuration)
+			if request.ExpiresAt != "" {
+				parsedExpiresAt, err := time.Parse(time.RFC3339, request.ExpiresAt)
+
+				if err != nil {
+					response.BadRequest(w, "Invalid expiry time")
+					return
+				}
+
+				expiresAt = parsedExpiresAt
+			}
+
+			if expiresAt.Before(time.Now()) {
+				response.BadRequest(w, "Expiry time must be in the future")
+				return
+			}
+
+			if expiresAt.After(time.Now().Add(maxTravelDuration)) {
+				response.BadRequest(w, "Travel mode can last at most 30 days")
+				return
+			}
+
+			if os.Getenv("TRAVEL_MODE_PRO_ONLY") == "true" {
+				isPro, err := subscription.IsUserPro(session.UserID, h.DB(r))
+
+				if err != nil {
+					log.Println("Error checking pro status:", err)
+					response.InternalServerError(w, err, "Failed to check subscription status")
+					return
+				}
+
+				if !isPro {
+					response.Forbidden(w, "Travel mode requires a Pro subscription")
+					return
+				}
+			}
+
+			city, err := geocoder.CityOrEmpty(h.geocoder, lat, lon)
+
+			if err != nil {
+				log.Println(err)
+				response.BadRequest(w, "Invalid location")
+				return
+			}
+
+			updatedProfile, err := profile.SetTravelLocation(session.UserID, lat, lon, city, expiresAt, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			if err := deck.ResetDeck(session.UserID, h.rdb); err != nil {
+				log.Println("Error resetting discovery deck:", err)
+			}
+
+			updatedProfile.ShowLocation()
+			updatedProfile.ShowVisibility()
+
+			response.OKWithData(w, "Successfully set travel location", updatedProfile)
+		}
+	})
+}
+
+func (h Handler) HandleClearTravelLocation() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+
+			if err := profile.ClearTravelLocation(session.UserID, h.DB(r)); err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			if err := deck.ResetDeck(session.UserID, h.rdb); err != nil {
+				log.Println("Error resetting discovery deck:", err)
+			}
+
+			response.OK(w, "Successfully cleared travel location")
+		}
+	})
+}
+
+func (h Handler) HandleUpdateVisibility() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			var request types.UpdateVisibilityRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			switch request.Visibility {
+			case schemas.VisibilityVisible, schemas.VisibilityPaused:
+			case schemas.VisibilityIncognito:
+				isPro, err := subscription.IsUserPro(session.UserID, h.DB(r))
+
+				if err != nil {
+					log.Println("Error checking pro status:", err)
+					response.InternalServerError(w, err, "Failed to check subscription status")
+					return
+				}
+
+				if !isPro {
+					response.Forbidden(w, "Incognito mode requires a Pro subscription")
+					return
+				}
+			default:
+				response.BadRequest(w, "Visibility must be visible, incognito or paused")
+				return
+			}
+
+			if err := profile.UpdateVisibility(session.UserID, request.Visibility, h.DB(r)); err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			response.OKWithData(w, "Successfully updated visibility", map[string]string{"visibility": request.Visibility})
+		}
+	})
+}
+
 func (h Handler) HandleGetProfile() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
@@ -371,14 +566,14 @@ func (h Handler) HandleGetProfile() http.Handler {
 			return
 		}
 
-		if id == "me" {
-			session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
 
-			if session == nil {
-				response.BadRequest(w, "Invalid session")
-				return
-			}
+		if session == nil {
+			response.BadRequest(w, "Invalid session")
+			return
+		}
 
+		if id == "me" {
 			id = strconv.FormatUint(uint64(session.UserID), 10)
 		}
 
@@ -403,6 +598,58 @@ func (h Handler) HandleGetProfile() http.Handler {
 				return
 			}
 
+			// Only the owner sees their coordinates, everyone else gets a fuzzed distance
+			if profileRecord.UserID == session.UserID {
+				profileRecord.ShowLocation()
+				profileRecord.ShowVisibility()
+			} else {
+				viewerProfile, err := profile.GetProfileById(session.UserID, h.DB(r))
+
+				if err != nil {
+					response.InternalServerError(w, err, "Something went wrong")
+					return
+				}
+
+				profileRecord.SetDistanceFrom(*viewerProfile)
+			}
+
+			// Owners also see their rejected photos so they know to replace them
+			profileRecord.Photos, err = photos.List(profileRecord.UserID, profileRecord.UserID == session.UserID, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			profileRecord.InterestTags, err = interests.List(profileRecord.UserID, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			// Owners also see their private attributes
+			profileRecord.Attributes, err = attributes.Get(profileRecord.UserID, profileRecord.UserID == session.UserID, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			profileRecord.Prompts, err = prompts.List(profileRecord.UserID, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			profileRecord.VoicePrompt, err = prompts.GetVoice(profileRecord.UserID, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
 			response.OKWithData(w, "OK", profileRecord)
 		}
 	})
@@ -478,18 +725,240 @@ func (h Handler) HandleDiscoverProfiles() http.Handler {
 				return
 			}
 
-			discoverNewProfile, err := profile.DiscoverNewProfile(*userProfile, h.DB(r))
+			discoverNewProfile, err := profile.DiscoverNewProfile(*userProfile, h.DB(r), h.rdb)
 			if err != nil {
 				log.Println("Error getting discoverNewProfileprofile:", err)
 				response.InternalServerError(w, err, "Something went wrong")
 				return
 			}
 
+			discoverNewProfile.SetDistanceFrom(*userProfile)
+
+			if err := photos.Attach([]*schemas.Profile{&discoverNewProfile}, h.DB(r)); err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			if err := prompts.Attach([]*schemas.Profile{&discoverNewProfile}, h.DB(r)); err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
 			response.OKWithData(w, "OK", discoverNewProfile)
 		}
 	})
 }
 
+func (h Handler) HandleDiscoverDeck() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+
+			size := deck.DefaultPageSize
+			if sizeParam := r.URL.Query().Get("size"); sizeParam != "" {
+				parsedSize, err := strconv.Atoi(sizeParam)
+
+				if err != nil || parsedSize < 1 || parsedSize > deck.MaxPageSize {
+					response.BadRequest(w, fmt.Sprintf("Size must be between 1 and %d", deck.MaxPageSize))
+					return
+				}
+
+				size = parsedSize
+			}
+
+			userProfile, err := profile.GetProfileById(session.UserID, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			profiles, err := deck.GetDeck(*userProfile, size, h.DB(r), h.rdb)
+
+			if err != nil {
+				log.Println("Error getting discovery deck:", err)
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			withPhotos := make([]*schemas.Profile, 0, len(profiles))
+			for i := range profiles {
+				profiles[i].SetDistanceFrom(*userProfile)
+				withPhotos = append(withPhotos, &profiles[i])
+			}
+
+			// Feeds show thumbnails, so send the smaller renditions along with the profiles
+			if err := photos.Attach(withPhotos, h.DB(r)); err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			if err := prompts.Attach(withPhotos, h.DB(r)); err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			response.OKWithData(w, "OK", profiles)
+		}
+	})
+}
+
+func (h Handler) HandleDiscoverDuoPairs() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+
+			size := deck.DefaultPageSize
+			if sizeParam := r.URL.Query().Get("size"); sizeParam != "" {
+				parsedSize, err := strconv.Atoi(sizeParam)
+
+				if err != nil || parsedSize < 1 || parsedSize > deck.MaxPageSize {
+					response.BadRequest(w, fmt.Sprintf("Size must be between 1 and %d", deck.MaxPageSize))
+					return
+				}
+
+				size = parsedSize
+			}
+
+			userProfile, err := profile.GetProfileById(session.UserID, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			pairs, err := discovery.DiscoverDuoPairs(*userProfile, size, h.DB(r), h.rdb)
+
+			if err != nil {
+				log.Println("Error getting duo discovery pairs:", err)
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			withPhotos := make([]*schemas.Profile, 0, len(pairs)*2)
+			for i := range pairs {
+				pairs[i].Target.SetDistanceFrom(*userProfile)
+				pairs[i].Friend.SetDistanceFrom(*userProfile)
+				withPhotos = append(withPhotos, &pairs[i].Target, &pairs[i].Friend)
+			}
+
+			if err := photos.Attach(withPhotos, h.DB(r)); err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			if err := prompts.Attach(withPhotos, h.DB(r)); err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			response.OKWithData(w, "OK", pairs)
+		}
+	})
+}
+
+func (h Handler) HandleGetDiscoveryFilters() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+
+			filters, err := discovery.GetFilters(session.UserID, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			response.OKWithData(w, "OK", filters)
+		}
+	})
+}
+
+func (h Handler) HandleUpdateDiscoveryFilters() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+
+			var request types.UpdateDiscoveryFiltersRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			isPro, err := subscription.IsUserPro(session.UserID, h.DB(r))
+
+			if err != nil {
+				log.Println("Error checking pro status:", err)
+				response.InternalServerError(w, err, "Failed to check subscription status")
+				return
+			}
+
+			if !isPro {
+				response.Forbidden(w, "Advanced filters require a Pro subscription")
+				return
+			}
+
+			filters, err := discovery.UpdateFilters(session.UserID, request, h.DB(r))
+
+			if err != nil {
+				response.BadRequest(w, err.Error())
+				return
+			}
+
+			if err := deck.ResetDeck(session.UserID, h.rdb); err != nil {
+				log.Println("Error resetting discovery deck:", err)
+			}
+
+			response.OKWithData(w, "Successfully updated discovery filters", filters)
+		}
+	})
+}
+
+func (h Handler) HandleResetPasses() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientVersion := r.Header.Get("X-Client-Version")
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+
+		switch clientVersion {
+
+		default:
+			profileIDs, err := profile.ResetPasses(session.UserID, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Failed to reset passes")
+				return
+			}
+
+			if err := seen.Unmark(session.UserID, profileIDs, h.DB(r), h.rdb); err != nil {
+				response.InternalServerError(w, err, "Failed to reset passes")
+				return
+			}
+
+			if err := deck.ResetDeck(session.UserID, h.rdb); err != nil {
+				log.Println("Error resetting discovery deck:", err)
+			}
+
+			response.OKWithData(w, "Successfully reset passes", map[string]int{"reset": len(profileIDs)})
+		}
+	})
+}
+
 func (h Handler) HandleGetAllProfiles() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		clientVersion := r.Header.Get("X-Client-Version")
@@ -523,13 +992,15 @@ func (h Handler) HandleCheckUsernameAvailability() http.Handler {
 			return
 		}
 
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+
 		clientVersion := r.Header.Get("X-Client-Version")
 
 		switch clientVersion {
 
 		default:
 
-			available, err := profile.CheckUsernameAvailability(username, h.DB(r))
+			available, err := profile.CheckUsernameAvailability(username, session.UserID, h.DB(r))
 			if err != nil {
 				response.InternalServerError(w, err, "Something went wrong")
 				return
@@ -841,35 +1312,19 @@ func (h Handler) HandleUpdateDateOfBirth() http.Handler {
 			return
 		}
 
-		minAge := 18
-		minBirthDate := time.Now().AddDate(-minAge, 0, 0)
-		if parsedDateOfBirth.After(minBirthDate) {
-			response.BadRequest(w, "User must be at least 18 years old")
-			return
-		}
-
-		profileRecord, err := profile.GetProfileById(session.UserID, h.DB(r))
+		// Setting a missing date and the first change go through, after that an admin has to approve
+		change, err := birthdate.Request(session.UserID, parsedDateOfBirth, requestBody.Reason, h.DB(r))
 
 		if err != nil {
-			response.NotFound(w, "Could not find requested user")
-			return
-		}
-
-		if profileRecord.UserID == 0 {
-			response.NotFound(w, "Could not find requested user")
-			return
-		}
-
-		if time.Now().AddDate(-100, 0, 0).After(profileRecord.DateOfBirth) {
-			response.Forbidden(w, "Date of Birth is already set")
+			birthdateErrorResponse(w, err)
 			return
 		}
 
-		if err := profile.UpdateDateOfBirth(session.UserID, parsedDateOfBirth, h.DB(r)); err != nil {
-			response.InternalServerError(w, err, "Something went wrong")
+		if change.Status == schemas.DateOfBirthPending {
+			response.OKWithData(w, "Date of Birth change is waiting for review", change)
 			return
 		}
 
-		response.OK(w, "Updated Date of Birth")
+		response.OKWithData(w, "Updated Date of Birth", change)
 	})
 }
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"twoman/globals"
+	"twoman/handlers/helpers/prompts"
+	"twoman/handlers/response"
+	"twoman/types"
+)
+
+func (h Handler) HandleGetPrompts() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			catalog, err := prompts.Catalog(false, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			response.OKWithData(w, "Successfully got prompts", catalog)
+		}
+	})
+}
+
+func (h Handler) HandleGetPromptAnswers() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			answers, err := prompts.List(session.UserID, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			response.OKWithData(w, "Successfully got prompt answers", answers)
+		}
+	})
+}
+
+func (h Handler) HandleAnswerPrompt() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			var request types.AnswerPromptRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			if request.PromptID == 0 {
+				response.BadRequest(w, "Prompt id is required")
+				return
+			}
+
+			answer, ok := validPromptAnswer(w, request.Answer)
+			if !ok {
+				return
+			}
+
+			promptAnswer, err := prompts.Answer(session.UserID, request.PromptID, answer, h.DB(r))
+
+			if err != nil {
+				promptErrorResponse(w, err)
+				return
+			}
+
+			response.OKWithData(w, "Successfully answered prompt", promptAnswer)
+		}
+	})
+}
+
+func (h Handler) HandleUpdatePromptAnswer() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		answerId, err := strconv.ParseUint(r.PathValue("answerId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid answer id")
+			return
+		}
+
+		switch clientVersion {
+
+		default:
+			var request types.UpdatePromptAnswerRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			answer, ok := validPromptAnswer(w, request.Answer)
+			if !ok {
+				return
+			}
+
+			promptAnswer, err := prompts.UpdateAnswer(session.UserID, uint(answerId), answer, h.DB(r))
+
+			if err != nil {
+				promptErrorResponse(w, err)
+				return
+			}
+
+			response.OKWithData(w, "Successfully updated prompt answer", promptAnswer)
+		}
+	})
+}
+
+func (h Handler) HandleReorderPromptAnswers() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			var request types.ReorderPromptAnswersRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			answers, err := prompts.Reorder(session.UserID, request.AnswerIDs, h.DB(r))
+
+			if err != nil {
+				promptErrorResponse(w, err)
+				return
+			}
+
+			response.OKWithData(w, "Successfully reordered prompt answers", answers)
+		}
+	})
+}
+
+func (h Handler) HandleDeletePromptAnswer() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		answerId, err := strconv.ParseUint(r.PathValue("answerId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid answer id")
+			return
+		}
+
+		switch clientVersion {
+
+		default:
+			if err := prompts.Remove(session.UserID, uint(answerId), h.DB(r)); err != nil {
+				promptErrorResponse(w, err)
+				return
+			}
+
+			response.OK(w, "Successfully deleted prompt answer")
+		}
+	})
+}
+
+func (h Handler) HandleSetVoicePrompt() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			var request types.SetVoicePromptRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			if request.URL == "" {
+				response.BadRequest(w, "URL is required")
+				return
+			}
+
+			voicePrompt, err := prompts.SetVoice(session.UserID, request.URL, request.PromptID, h.DB(r), h.s3)
+
+			if err != nil {
+				promptErrorResponse(w, err)
+				return
+			}
+
+			response.OKWithData(w, "Successfully set voice prompt", voicePrompt)
+		}
+	})
+}
+
+func (h Handler) HandleDeleteVoicePrompt() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			if err := prompts.RemoveVoice(session.UserID, h.DB(r), h.s3); err != nil {
+				promptErrorResponse(w, err)
+				return
+			}
+
+			response.OK(w, "Successfully deleted voice prompt")
+		}
+	})
+}
+
+func (h Handler) HandleAdminGetPrompts() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		catalog, err := prompts.Catalog(true, h.DB(r))
+
+		if err != nil {
+			response.InternalServerError(w, err, "Could not get prompts")
+			return
+		}
+
+		response.OKWithData(w, "Successfully got prompts", catalog)
+	})
+}
+
+func (h Handler) HandleAdminCreatePrompt() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requestBody types.AdminCreatePromptRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+
+		prompt, err := prompts.CreatePrompt(strings.TrimSpace(requestBody.Text), strings.TrimSpace(requestBody.Category), h.DB(r))
+
+		if err != nil {
+			promptErrorResponse(w, err)
+			return
+		}
+
+		response.OKWithData(w, "Successfully created prompt", prompt)
+	})
+}
+
+func (h Handler) HandleAdminUpdatePrompt() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		promptId, err := strconv.ParseUint(r.PathValue("promptId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid prompt id")
+			return
+		}
+
+		var requestBody types.AdminUpdatePromptRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+
+		if requestBody.Text != nil {
+			text := strings.TrimSpace(*requestBody.Text)
+			requestBody.Text = &text
+		}
+		if requestBody.Category != nil {
+			category := strings.TrimSpace(*requestBody.Category)
+			requestBody.Category = &category
+		}
+
+		prompt, err := prompts.UpdatePrompt(uint(promptId), requestBody.Text, requestBody.Category, requestBody.Active, h.DB(r))
+
+		if err != nil {
+			promptErrorResponse(w, err)
+			return
+		}
+
+		response.OKWithData(w, "Successfully updated prompt", prompt)
+	})
+}
+
+func (h Handler) HandleAdminDeletePrompt() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		promptId, err := strconv.ParseUint(r.PathValue("promptId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid prompt id")
+			return
+		}
+
+		if err := prompts.DeletePrompt(uint(promptId), h.DB(r)); err != nil {
+			promptErrorResponse(w, err)
+			return
+		}
+
+		response.OK(w, "Successfully deleted prompt")
+	})
+}
+
+// validPromptAnswer trims an answer and writes a bad request when it's empty or too long
+func validPromptAnswer(w http.ResponseWriter, answer string) (string, bool) {
+	answer = strings.TrimSpace(answer)
+
+	if answer == "" {
+		response.BadRequest(w, "Answer is required")
+		return "", false
+	}
+
+	if len(answer) > prompts.MaxAnswerLength {
+		response.BadRequest(w, fmt.Sprintf("Answer must be less than %d characters", prompts.MaxAnswerLength))
+		return "", false
+	}
+
+	return answer, true
+}
+
+// promptErrorResponse turns prompt catalog and answer errors into client errors
+func promptErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, prompts.ErrPromptNotFound):
+		response.NotFound(w, "Prompt not found")
+	case errors.Is(err, prompts.ErrAnswerNotFound):
+		response.NotFound(w, "Prompt answer not found")
+	case errors.Is(err, prompts.ErrVoicePromptMissing):
+		response.NotFound(w, "Voice prompt not found")
+	case errors.Is(err, prompts.ErrTooManyAnswers):
+		response.BadRequest(w, fmt.Sprintf("A profile can answer at most %d prompts", prompts.MaxAnswers()))
+	case errors.Is(err, prompts.ErrPromptInactive), errors.Is(err, prompts.ErrPromptInUse),
+		errors.Is(err, prompts.ErrAlreadyAnswered), errors.Is(err, prompts.ErrInvalidOrder),
+		errors.Is(err, prompts.ErrDuplicatePrompt), errors.Is(err, prompts.ErrEmptyPromptText),
+		errors.Is(err, prompts.ErrPromptTextLength), errors.Is(err, prompts.ErrAudioNotOwned):
+		response.BadRequest(w, err.Error())
+	default:
+		response.InternalServerError(w, err, "Something went wrong")
+	}
+}
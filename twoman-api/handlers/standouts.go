@@ -5,8 +5,12 @@ import (
 	"net/http"
 	"strconv"
 	"twoman/globals"
+	"twoman/handlers/helpers/attributes"
+	"twoman/handlers/helpers/profile"
+	"twoman/handlers/helpers/prompts"
 	"twoman/handlers/helpers/standouts"
 	"twoman/handlers/response"
+	"twoman/schemas"
 	"twoman/types"
 )
 
@@ -42,6 +46,29 @@ func (h Handler) HandleGetDuoStandouts() http.Handler {
 			return
 		}
 
+		userProfile, err := profile.GetProfileById(session.UserID, h.DB(r))
+		if err != nil {
+			response.InternalServerError(w, err, "Failed to get duo standouts")
+			return
+		}
+
+		withPrompts := make([]*schemas.Profile, 0, len(duoStandouts)*2)
+		for i := range duoStandouts {
+			duoStandouts[i].Profile1.SetDistanceFrom(*userProfile)
+			duoStandouts[i].Profile2.SetDistanceFrom(*userProfile)
+			withPrompts = append(withPrompts, &duoStandouts[i].Profile1, &duoStandouts[i].Profile2)
+		}
+
+		if err := prompts.Attach(withPrompts, h.DB(r)); err != nil {
+			response.InternalServerError(w, err, "Failed to get duo standouts")
+			return
+		}
+
+		if err := attributes.Attach(withPrompts, h.DB(r)); err != nil {
+			response.InternalServerError(w, err, "Failed to get duo standouts")
+			return
+		}
+
 		log.Printf("Duo standouts retrieved successfully %d", len(duoStandouts))
 
 		response.OKWithData(w, "Duo standouts retrieved successfully", map[string]interface{}{
@@ -69,6 +96,28 @@ func (h Handler) HandleGetSoloStandouts() http.Handler {
 			return
 		}
 
+		userProfile, err := profile.GetProfileById(session.UserID, h.DB(r))
+		if err != nil {
+			response.InternalServerError(w, err, "Failed to get solo standouts")
+			return
+		}
+
+		withPrompts := make([]*schemas.Profile, 0, len(soloStandouts))
+		for i := range soloStandouts {
+			soloStandouts[i].Profile.SetDistanceFrom(*userProfile)
+			withPrompts = append(withPrompts, &soloStandouts[i].Profile)
+		}
+
+		if err := prompts.Attach(withPrompts, h.DB(r)); err != nil {
+			response.InternalServerError(w, err, "Failed to get solo standouts")
+			return
+		}
+
+		if err := attributes.Attach(withPrompts, h.DB(r)); err != nil {
+			response.InternalServerError(w, err, "Failed to get solo standouts")
+			return
+		}
+
 		response.OKWithData(w, "Solo standouts retrieved successfully", map[string]interface{}{
 			"solo_standouts": soloStandouts,
 		})
@@ -16,7 +16,7 @@ func (h Handler) HandleGetUserSelf() http.Handler {
 
 		default:
 
-			foundUser, err := user.GetUserByID(session.UserID, h.DB(r))
+			foundUser, err := user.GetSelf(session.UserID, h.DB(r))
 
 			if err != nil {
 				response.NotFound(w, err.Error())
@@ -36,7 +36,7 @@ func (h Handler) HandleDeleteUser() http.Handler {
 		switch clientVersion {
 		default:
 
-			// if err := user.DeleteUser(session.UserID, h.DB(r), h.s3); err != nil {
+			// if err := user.DeleteUser(session.UserID, h.DB(r), h.s3, h.rdb); err != nil {
 			// 	response.InternalServerError(w, err, "Something went wrong")
 			// 	return
 			// }
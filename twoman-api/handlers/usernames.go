@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"twoman/globals"
+	"twoman/handlers/helpers/usernames"
+	"twoman/handlers/response"
+	"twoman/types"
+)
+
+func (h Handler) HandleGetUsernameStatus() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			status, err := usernames.GetStatus(session.UserID, h.DB(r))
+
+			if err != nil {
+				usernameErrorResponse(w, err)
+				return
+			}
+
+			response.OKWithData(w, "Successfully got username status", status)
+		}
+	})
+}
+
+func (h Handler) HandleChangeUsername() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			var request types.ChangeUsernameRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				response.BadRequest(w, "Invalid request body")
+				return
+			}
+
+			status, err := usernames.Change(session.UserID, request.Username, h.DB(r))
+
+			if err != nil {
+				if errors.Is(err, usernames.ErrChangeTooSoon) {
+					// Tell the user when they can try again
+					if current, statusErr := usernames.GetStatus(session.UserID, h.DB(r)); statusErr == nil && current.NextChangeAt != nil {
+						response.TooManyRequests(w, fmt.Sprintf("You can change your username again on %s", current.NextChangeAt.Format("January 2, 2006")))
+						return
+					}
+				}
+
+				usernameErrorResponse(w, err)
+				return
+			}
+
+			response.OKWithData(w, "Successfully changed username", status)
+		}
+	})
+}
+
+func (h Handler) HandleAdminGetUsernameHistory() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parsedProfileId, err := strconv.ParseUint(r.PathValue("profileId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid profileId")
+			return
+		}
+
+		history, err := usernames.History(uint(parsedProfileId), h.DB(r))
+
+		if err != nil {
+			response.InternalServerError(w, err, "Something went wrong")
+			return
+		}
+
+		response.OKWithData(w, "Successfully got username history", history)
+	})
+}
+
+// usernameErrorResponse turns username change errors into client errors
+func usernameErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, usernames.ErrProfileNotFound):
+		response.NotFound(w, "Profile not found")
+	case errors.Is(err, usernames.ErrUsernameTaken):
+		response.Conflict(w, "Username is taken")
+	case errors.Is(err, usernames.ErrChangeTooSoon):
+		response.TooManyRequests(w, "Username was changed too recently")
+	case errors.Is(err, usernames.ErrInvalidUsername), errors.Is(err, usernames.ErrUnchanged):
+		response.BadRequest(w, err.Error())
+	default:
+		response.InternalServerError(w, err, "Something went wrong")
+	}
+}
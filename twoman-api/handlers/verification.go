@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"twoman/globals"
+	"twoman/handlers/helpers/verification"
+	"twoman/handlers/response"
+	"twoman/types"
+
+	fileHelper "twoman/handlers/helpers/file"
+)
+
+func (h Handler) HandleGetVerification() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			request, err := verification.Latest(session.UserID, h.DB(r))
+
+			if err != nil {
+				response.InternalServerError(w, err, "Something went wrong")
+				return
+			}
+
+			response.OKWithData(w, "Successfully got verification", request)
+		}
+	})
+}
+
+func (h Handler) HandleStartVerification() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			request, err := verification.Start(session.UserID, h.DB(r))
+
+			if err != nil {
+				verificationErrorResponse(w, err)
+				return
+			}
+
+			response.OKWithData(w, "Successfully started verification", request)
+		}
+	})
+}
+
+func (h Handler) HandleSubmitVerificationSelfie() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
+		clientVersion := r.Header.Get("X-Client-Version")
+
+		switch clientVersion {
+
+		default:
+			allowedMimeTypes := map[string]bool{
+				"image/jpeg": true,
+				"image/png":  true,
+			}
+
+			if err := r.ParseMultipartForm(10 << 20); err != nil { // Max size of 10MB
+				response.BadRequest(w, "Error parsing form")
+				return
+			}
+
+			file, header, err := r.FormFile("file")
+			if err != nil {
+				response.BadRequest(w, "Error getting file")
+				return
+			}
+			defer func(file multipart.File) {
+				if err := file.Close(); err != nil {
+					log.Println("Error closing file:", err)
+				}
+			}(file)
+
+			if !allowedMimeTypes[header.Header.Get("Content-Type")] {
+				response.BadRequest(w, "Invalid file type")
+				return
+			}
+
+			// Processing turns the selfie upright and drops its metadata, including GPS
+			processedImage, err := fileHelper.ProcessImage(file)
+			if err != nil {
+				log.Println("Error processing selfie:", err)
+				response.BadRequest(w, "Error processing image")
+				return
+			}
+
+			var selfie []byte
+			for _, rendition := range processedImage.Renditions {
+				if rendition.Name == "full" {
+					selfie = rendition.Data
+				}
+			}
+
+			request, err := verification.SubmitSelfie(session.UserID, selfie, h.DB(r), h.s3)
+
+			if err != nil {
+				verificationErrorResponse(w, err)
+				return
+			}
+
+			response.OKWithData(w, "Successfully submitted selfie", request)
+		}
+	})
+}
+
+func (h Handler) HandleAdminGetVerifications() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reviews, err := verification.ListPending(h.DB(r), h.s3)
+
+		if err != nil {
+			response.InternalServerError(w, err, "Could not get verifications")
+			return
+		}
+
+		response.OKWithData(w, "Successfully got verifications", reviews)
+	})
+}
+
+func (h Handler) HandleAdminReviewVerification() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminId := r.Context().Value(globals.AdminMiddlewareKey).(uint)
+
+		requestId, err := strconv.ParseUint(r.PathValue("requestId"), 10, 64)
+
+		if err != nil {
+			response.BadRequest(w, "Invalid request id")
+			return
+		}
+
+		var requestBody types.AdminReviewVerificationRequest
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+
+		request, err := verification.Decide(uint(requestId), requestBody.Status, requestBody.Reason, adminId, h.DB(r), h.s3)
+
+		if err != nil {
+			verificationErrorResponse(w, err)
+			return
+		}
+
+		response.OKWithData(w, "Successfully reviewed verification", request)
+	})
+}
+
+// verificationErrorResponse turns verification workflow errors into client errors
+func verificationErrorResponse(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, verification.ErrRequestNotFound):
+		response.NotFound(w, "Verification request not found")
+	case errors.Is(err, verification.ErrAlreadyVerified), errors.Is(err, verification.ErrUnderReview),
+		errors.Is(err, verification.ErrNoPose), errors.Is(err, verification.ErrInvalidStatus),
+		errors.Is(err, verification.ErrAlreadyReviewed), errors.Is(err, verification.ErrReasonRequired):
+		response.BadRequest(w, err.Error())
+	default:
+		response.InternalServerError(w, err, "Something went wrong")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"sync"
 	"time"
+	"twoman/handlers/helpers/activity"
 	"twoman/handlers/helpers/auth"
 	"twoman/handlers/helpers/socket"
 	wsvalidator "twoman/handlers/helpers/websocket"
@@ -312,6 +313,7 @@ func (h Handler) HandleWebsocket() http.Handler {
 				log.Println("Read error:", err)
 				return
 			}
+			activity.Touch(session.UserID, db, h.rdb)
 			handleMessage(messageType, p, conn, session.UserID, db, h.rdb, socketHanlder, clientVersion, h.wsValidator)
 		}
 	})
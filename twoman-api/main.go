@@ -8,7 +8,10 @@ import (
 	"os"
 	"strings"
 	"time"
+	"twoman/handlers/helpers/completeness"
 	"twoman/handlers/helpers/database"
+	"twoman/handlers/helpers/dates"
+	"twoman/handlers/helpers/geocoder"
 	"twoman/migrations"
 	"twoman/router"
 	"twoman/schemas"
@@ -21,7 +24,6 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/cors"
 	"github.com/twilio/twilio-go"
-	"googlemaps.github.io/maps"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
@@ -194,9 +196,10 @@ func main() {
 
 	log.Println("Successfully migrated maria DB")
 
-	log.Println("Creating maps client")
+	log.Println("Creating geocoder")
 
-	mapsClient, err := maps.NewClient(maps.WithAPIKey(os.Getenv("GOOGLE_API_KEY")))
+	// GEOCODER=google switches city lookups to the Google Maps API, which needs GOOGLE_API_KEY
+	geo, err := geocoder.New(os.Getenv("GEOCODER"), os.Getenv("GOOGLE_API_KEY"))
 	if err != nil {
 		log.Fatalf("fatal error: %s", err)
 	}
@@ -211,8 +214,8 @@ func main() {
 		lng := -118.2426
 
 		log.Println("Seeding the database...")
-		database.CreateUsersAndProfiles(liveDB, *numUsers, lat, lng, mapsClient)
-		database.CreateUsersAndProfiles(demoDB, *numUsers, lat, lng, mapsClient)
+		database.CreateUsersAndProfiles(liveDB, *numUsers, lat, lng, geo)
+		database.CreateUsersAndProfiles(demoDB, *numUsers, lat, lng, geo)
 
 		log.Println("Database seeding completed successfully!")
 		os.Exit(0)
@@ -238,6 +241,16 @@ func main() {
 
 	log.Println("Successfully created twilio client")
 
+	log.Println("Starting date reminder workers")
+
+	go dates.StartReminderWorker(liveDB)
+	go dates.StartReminderWorker(demoDB)
+
+	// Demo profiles are never nudged
+	log.Println("Starting profile completeness nudge worker")
+
+	go completeness.StartNudgeWorker(liveDB)
+
 	log.Println("Starting http server")
 
 	port := os.Getenv("PORT")
@@ -261,7 +274,7 @@ func main() {
 		AllowCredentials: true,
 	})
 
-	handler := c.Handler(router.Router(liveDB, demoDB, rdb, s3Svc, rlmdb, mapsClient, twClient, development))
+	handler := c.Handler(router.Router(liveDB, demoDB, rdb, s3Svc, rlmdb, geo, twClient, development))
 
 	err = http.ListenAndServe(fmt.Sprintf(":%s", port), handler)
 
@@ -294,6 +307,29 @@ func MigrateDB(db *gorm.DB) error {
 		&schemas.ProSubscriptionV2{},
 		&schemas.Stars{},
 		&schemas.StarTransactions{},
+		&schemas.DatePlan{},
+		&schemas.DateRSVP{},
+		&schemas.DiscoveryWeights{},
+		&schemas.DiscoveryFilters{},
+		&schemas.DiscoveryRecyclePolicy{},
+		&schemas.SeenProfiles{},
+		&schemas.ProfilePass{},
+		&schemas.SwipeStats{},
+		&schemas.ProfilePhoto{},
+		&schemas.DuplicatePhotoFlag{},
+		&schemas.VerificationRequest{},
+		&schemas.Prompt{},
+		&schemas.PromptAnswer{},
+		&schemas.VoicePrompt{},
+		&schemas.InterestCategory{},
+		&schemas.Interest{},
+		&schemas.ProfileInterest{},
+		&schemas.ProfileAttribute{},
+		&schemas.ProfileAttributeValue{},
+		&schemas.DiscoveryAttributeFilter{},
+		&schemas.CompletenessNudge{},
+		&schemas.UsernameChange{},
+		&schemas.DateOfBirthChange{},
 	)
 
 	if err != nil {
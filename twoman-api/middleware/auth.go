@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 	"twoman/globals"
+	"twoman/handlers/helpers/activity"
 	"twoman/handlers/helpers/admin"
 	"twoman/handlers/helpers/auth"
 	"twoman/handlers/response"
@@ -121,11 +122,21 @@ func (mw Provider) AuthMiddleware(next http.Handler) http.HandlerFunc {
 			// Continue processing - session extension failure shouldn't block request
 		}
 
+		go activity.Touch(session.UserID, mw.sessionDB(session), mw.rdb)
+
 		ctx := context.WithValue(r.Context(), globals.SessionMiddlewareKey, session)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
 
+// sessionDB returns the database a session belongs to when the request doesn't pick one
+func (mw Provider) sessionDB(session *types.Session) *gorm.DB {
+	if session.Type == "demo" {
+		return mw.demoDB
+	}
+	return mw.liveDB
+}
+
 func (mw Provider) rateLimit(ctx context.Context, userID uint, config RateLimitConfig) (bool, error) {
 	key := fmt.Sprintf("rate_limit:%d", userID)
 	now := time.Now().UnixNano()
@@ -217,8 +228,8 @@ func (mw Provider) DatabaseMiddleware(next http.Handler) http.Handler {
 			dbConn = mw.liveDB
 		default:
 			session, ok := r.Context().Value(globals.SessionMiddlewareKey).(*types.Session)
-			if ok && session.Type == "demo" {
-				dbConn = mw.demoDB
+			if ok {
+				dbConn = mw.sessionDB(session)
 			} else {
 				dbConn = mw.liveDB
 			}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// MigrateFileHashChunks fills in the hash chunks of images uploaded before duplicate checks looked them up by chunk
+func MigrateFileHashChunks(db *gorm.DB) error {
+	log.Println("Backfilling file hash chunks...")
+
+	result := db.Exec(`
+		UPDATE file_metadata
+		SET hash_chunk1 = (perceptual_hash >> 48) & 0xFFFF,
+			hash_chunk2 = (perceptual_hash >> 32) & 0xFFFF,
+			hash_chunk3 = (perceptual_hash >> 16) & 0xFFFF,
+			hash_chunk4 = perceptual_hash & 0xFFFF
+		WHERE perceptual_hash IS NOT NULL AND hash_chunk1 IS NULL
+	`)
+	if result.Error != nil {
+		log.Printf("Error backfilling file hash chunks: %v", result.Error)
+		return result.Error
+	}
+
+	log.Printf("Backfilled hash chunks for %d files", result.RowsAffected)
+	return nil
+}
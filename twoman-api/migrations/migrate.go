@@ -27,6 +27,38 @@ func RunMigrations(db *gorm.DB) error {
 			Name: "001_redesign_notifications",
 			Func: MigrateNotificationSystem,
 		},
+		{
+			Name: "003_seen_profiles",
+			Func: MigrateSeenProfiles,
+		},
+		{
+			Name: "004_profiles_spatial_index",
+			Func: MigrateProfilesSpatialIndex,
+		},
+		{
+			Name: "005_profile_activity",
+			Func: MigrateProfileActivity,
+		},
+		{
+			Name: "006_profile_photos",
+			Func: MigrateProfilePhotos,
+		},
+		{
+			Name: "007_profile_interests",
+			Func: MigrateProfileInterests,
+		},
+		{
+			Name: "008_profile_attributes",
+			Func: MigrateProfileAttributes,
+		},
+		{
+			Name: "009_file_hash_chunks",
+			Func: MigrateFileHashChunks,
+		},
+		{
+			Name: "010_profile_passes",
+			Func: MigrateProfilePasses,
+		},
 		// Add future migrations here
 	}
 
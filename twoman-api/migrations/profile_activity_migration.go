@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// MigrateProfileActivity backfills profiles.last_active_at from the last swipe each user made, falling back to
+// when they signed up, so existing users aren't all treated as dormant
+func MigrateProfileActivity(db *gorm.DB) error {
+	log.Println("Backfilling profile activity...")
+
+	result := db.Exec(`
+		UPDATE profiles p
+		JOIN users u ON u.id = p.user_id
+		SET p.last_active_at = GREATEST(
+			COALESCE((SELECT MAX(pv.updated_at) FROM profile_views pv WHERE pv.user_id = p.user_id), u.created_at),
+			u.created_at
+		)
+		WHERE p.last_active_at IS NULL
+	`)
+	if result.Error != nil {
+		log.Printf("Error backfilling profile activity: %v", result.Error)
+		return result.Error
+	}
+
+	log.Printf("Backfilled activity for %d profiles", result.RowsAffected)
+	return nil
+}
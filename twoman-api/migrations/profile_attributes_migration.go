@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"log"
+	"twoman/handlers/helpers/attributes"
+
+	"gorm.io/gorm"
+)
+
+// MigrateProfileAttributes seeds the default profile attribute definitions, such as height and smoking. Admins can
+// change or add to them afterwards.
+func MigrateProfileAttributes(db *gorm.DB) error {
+	log.Println("Seeding profile attributes...")
+
+	if err := attributes.SeedDefinitions(db); err != nil {
+		log.Printf("Error seeding profile attributes: %v", err)
+		return err
+	}
+
+	log.Printf("Seeded %d profile attributes", len(attributes.DefaultDefinitions))
+	return nil
+}
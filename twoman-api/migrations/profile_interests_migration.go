@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"log"
+	"strings"
+	"twoman/handlers/helpers/interests"
+	"twoman/schemas"
+
+	"gorm.io/gorm"
+)
+
+// MigrateProfileInterests seeds the interest catalog and links every profile to the catalog entries for its free
+// text interests. Names are matched case and whitespace insensitively; ones that aren't in the catalog are added as
+// inactive Other interests so nobody loses what they wrote. Profile.Interests is rewritten with the catalog names.
+func MigrateProfileInterests(db *gorm.DB) error {
+	log.Println("Migrating profile interests to the interest catalog...")
+
+	if err := interests.SeedCatalog(db); err != nil {
+		log.Printf("Error seeding interest catalog: %v", err)
+		return err
+	}
+
+	var profiles []schemas.Profile
+	migrated := 0
+	result := db.Select("user_id", "interests").Where("interests IS NOT NULL AND interests != ''").
+		FindInBatches(&profiles, 500, func(tx *gorm.DB, batch int) error {
+			for _, profile := range profiles {
+				names := interests.Split(profile.Interests)
+				if len(names) > interests.MaxInterests {
+					log.Printf("Profile %d has %d interests, dropping %q", profile.UserID, len(names),
+						strings.Join(names[interests.MaxInterests:], ","))
+					names = names[:interests.MaxInterests]
+				}
+
+				for i, name := range names {
+					if len(name) > interests.MaxNameLength {
+						names[i] = truncateName(name, interests.MaxNameLength)
+						log.Printf("Profile %d interest %q shortened to %q", profile.UserID, name, names[i])
+					}
+				}
+
+				if _, err := interests.SetFromNames(profile.UserID, strings.Join(names, ","), db); err != nil {
+					return err
+				}
+				migrated++
+			}
+			return nil
+		})
+	if result.Error != nil {
+		log.Printf("Error migrating profile interests: %v", result.Error)
+		return result.Error
+	}
+
+	log.Printf("Migrated interests for %d profiles", migrated)
+	return nil
+}
+
+// truncateName cuts name to at most maxBytes without splitting a multi-byte character
+func truncateName(name string, maxBytes int) string {
+	if len(name) <= maxBytes {
+		return name
+	}
+
+	// Cut at the last character boundary that fits
+	end := 0
+	for i := range name {
+		if i > maxBytes {
+			break
+		}
+		end = i
+	}
+	return strings.TrimSpace(name[:end])
+}
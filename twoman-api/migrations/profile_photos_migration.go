@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// MigrateProfilePhotos copies profiles.image1..image4 into profile_photos, keeping their order with empty slots
+// skipped. Existing photos have already been live, so they start out approved. Profiles that already have
+// gallery rows are left alone.
+func MigrateProfilePhotos(db *gorm.DB) error {
+	log.Println("Migrating profile images to profile photos...")
+
+	result := db.Exec(`
+		INSERT INTO profile_photos (created_at, updated_at, profile_id, url, filename, position, caption, is_primary, moderation_status)
+		SELECT NOW(), NOW(), slots.user_id, slots.url, SUBSTRING_INDEX(slots.url, '/', -1),
+			ROW_NUMBER() OVER (PARTITION BY slots.user_id ORDER BY slots.slot) - 1, '',
+			ROW_NUMBER() OVER (PARTITION BY slots.user_id ORDER BY slots.slot) = 1, 'approved'
+		FROM (
+			SELECT user_id, image1 AS url, 1 AS slot FROM profiles
+			UNION ALL SELECT user_id, image2, 2 FROM profiles
+			UNION ALL SELECT user_id, image3, 3 FROM profiles
+			UNION ALL SELECT user_id, image4, 4 FROM profiles
+		) AS slots
+		WHERE slots.url IS NOT NULL AND slots.url != ''
+			AND NOT EXISTS (SELECT 1 FROM profile_photos pp WHERE pp.profile_id = slots.user_id)
+	`)
+	if result.Error != nil {
+		log.Printf("Error migrating profile photos: %v", result.Error)
+		return result.Error
+	}
+
+	log.Printf("Migrated %d profile photos", result.RowsAffected)
+	return nil
+}
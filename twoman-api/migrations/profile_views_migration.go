@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"log"
+	"twoman/schemas"
+
+	"gorm.io/gorm"
+)
+
+// MigrateSeenProfiles compacts every existing profile view into seen_profiles, 64 profiles per row
+func MigrateSeenProfiles(db *gorm.DB) error {
+	log.Println("Compacting profile views into seen profiles...")
+
+	result := db.Exec(`
+		INSERT INTO seen_profiles (user_id, chunk, bits)
+		SELECT user_id, profile_id DIV 64, BIT_OR(1 << (profile_id % 64))
+		FROM profile_views
+		GROUP BY user_id, profile_id DIV 64
+		ON DUPLICATE KEY UPDATE bits = bits | VALUES(bits)
+	`)
+	if result.Error != nil {
+		log.Printf("Error compacting profile views: %v", result.Error)
+		return result.Error
+	}
+
+	log.Printf("Created %d seen profile rows", result.RowsAffected)
+	return nil
+}
+
+// MigrateProfilePasses copies passes out of profile_views into profile_passes and counts each user's swipes into
+// swipe_stats. Views never recorded whether they were a like or a pass, so any view that didn't lead to a match is
+// treated as a pass. Likes are counted from the matches they created.
+func MigrateProfilePasses(db *gorm.DB) error {
+	log.Println("Moving passes into profile passes...")
+
+	result := db.Exec(`
+		INSERT INTO profile_passes (user_id, profile_id, passed_at)
+		SELECT pv.user_id, pv.profile_id, MAX(pv.updated_at)
+		FROM profile_views pv
+		WHERE NOT EXISTS (
+			SELECT 1 FROM matches m
+			WHERE (m.profile1_id = pv.user_id OR m.profile2_id = pv.user_id OR m.profile3_id = pv.user_id OR m.profile4_id = pv.user_id)
+			AND (m.profile1_id = pv.profile_id OR m.profile2_id = pv.profile_id OR m.profile3_id = pv.profile_id OR m.profile4_id = pv.profile_id)
+		)
+		GROUP BY pv.user_id, pv.profile_id
+		ON DUPLICATE KEY UPDATE passed_at = GREATEST(passed_at, VALUES(passed_at))
+	`)
+	if result.Error != nil {
+		log.Printf("Error moving passes: %v", result.Error)
+		return result.Error
+	}
+
+	log.Printf("Created %d profile passes", result.RowsAffected)
+
+	result = db.Exec(`
+		INSERT INTO swipe_stats (user_id, likes, passes)
+		SELECT p.user_id,
+			(SELECT COUNT(*) FROM matches m WHERE m.profile1_id = p.user_id),
+			(SELECT COUNT(*) FROM profile_passes pp WHERE pp.user_id = p.user_id)
+		FROM profiles p
+		ON DUPLICATE KEY UPDATE likes = VALUES(likes), passes = VALUES(passes)
+	`)
+	if result.Error != nil {
+		log.Printf("Error counting swipes: %v", result.Error)
+		return result.Error
+	}
+
+	log.Printf("Counted swipes for %d profiles", result.RowsAffected)
+
+	// Databases that ran an earlier version of this migration have a decision column nothing reads any more
+	if db.Migrator().HasColumn(&schemas.ProfileView{}, "decision") {
+		if err := db.Migrator().DropColumn(&schemas.ProfileView{}, "decision"); err != nil {
+			log.Printf("Error dropping profile view decisions: %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// MigrateProfilesSpatialIndex adds a SPATIAL index on profiles.location_point so distance filters can narrow
+// candidates down with MBRContains instead of computing ST_Distance_Sphere for every profile
+func MigrateProfilesSpatialIndex(db *gorm.DB) error {
+	if db.Migrator().HasIndex("profiles", "idx_profiles_location_point") {
+		log.Println("Spatial index on profiles.location_point already exists")
+		return nil
+	}
+
+	log.Println("Creating spatial index on profiles.location_point...")
+
+	if err := db.Exec("CREATE SPATIAL INDEX idx_profiles_location_point ON profiles (location_point)").Error; err != nil {
+		log.Printf("Error creating spatial index: %v", err)
+		return err
+	}
+
+	return nil
+}
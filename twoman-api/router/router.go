@@ -3,22 +3,22 @@ package router
 import (
 	"net/http"
 	"twoman/handlers"
+	"twoman/handlers/helpers/geocoder"
 	"twoman/middleware"
 
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/redis/go-redis/v9"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"github.com/twilio/twilio-go"
-	"googlemaps.github.io/maps"
 	"gorm.io/gorm"
 
 	_ "twoman/docs"
 )
 
-func Router(liveDB, demoDB *gorm.DB, rdb *redis.Client, s3 *s3.S3, rlmdb *redis.Client, mapsClient *maps.Client, twClient *twilio.RestClient, development bool) *http.ServeMux {
+func Router(liveDB, demoDB *gorm.DB, rdb *redis.Client, s3 *s3.S3, rlmdb *redis.Client, geo geocoder.Geocoder, twClient *twilio.RestClient, development bool) *http.ServeMux {
 
 	router := http.NewServeMux()
-	handler := handlers.NewHandler(liveDB, demoDB, s3, rdb, rlmdb, mapsClient, twClient)
+	handler := handlers.NewHandler(liveDB, demoDB, s3, rdb, rlmdb, geo, twClient)
 
 	middlewareProvider := middleware.NewMiddlewareProvider(rdb, rlmdb, liveDB, demoDB)
 
@@ -46,9 +46,39 @@ func Router(liveDB, demoDB *gorm.DB, rdb *redis.Client, s3 *s3.S3, rlmdb *redis.
 	router.Handle("PATCH /v1/profile", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleUpdateProfile())))
 	router.Handle("POST /v1/profile", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleCreateProfile())))
 	router.Handle("GET /v1/profile/username", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleCheckUsernameAvailability())))
+	router.Handle("GET /v1/profile/username/status", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetUsernameStatus())))
+	router.Handle("PUT /v1/profile/username", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleChangeUsername())))
 	router.Handle("GET /v1/profile/{profileId}", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetProfile())))
 	router.Handle("GET /v1/profile/{profileId}/friends", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetProfileFriends())))
 	router.Handle("GET /v1/profile/discover", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleDiscoverProfiles())))
+	router.Handle("GET /v1/profile/discover/deck", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleDiscoverDeck())))
+	router.Handle("GET /v1/profile/discover/duo", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleDiscoverDuoPairs())))
+	router.Handle("GET /v1/profile/preferences/filters", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetDiscoveryFilters())))
+	router.Handle("PUT /v1/profile/preferences/filters", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleUpdateDiscoveryFilters())))
+	router.Handle("POST /v1/profile/travel", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleSetTravelLocation())))
+	router.Handle("DELETE /v1/profile/travel", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleClearTravelLocation())))
+	router.Handle("PUT /v1/profile/visibility", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleUpdateVisibility())))
+	router.Handle("GET /v1/profile/photos", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetProfilePhotos())))
+	router.Handle("POST /v1/profile/photos", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleAddProfilePhoto())))
+	router.Handle("PUT /v1/profile/photos/order", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleReorderProfilePhotos())))
+	router.Handle("PATCH /v1/profile/photos/{photoId}", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleUpdateProfilePhoto())))
+	router.Handle("DELETE /v1/profile/photos/{photoId}", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleDeleteProfilePhoto())))
+	router.Handle("GET /v1/prompts", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetPrompts())))
+	router.Handle("GET /v1/profile/prompts", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetPromptAnswers())))
+	router.Handle("POST /v1/profile/prompts", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleAnswerPrompt())))
+	router.Handle("PUT /v1/profile/prompts/order", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleReorderPromptAnswers())))
+	router.Handle("PATCH /v1/profile/prompts/{answerId}", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleUpdatePromptAnswer())))
+	router.Handle("DELETE /v1/profile/prompts/{answerId}", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleDeletePromptAnswer())))
+	router.Handle("PUT /v1/profile/voice-prompt", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleSetVoicePrompt())))
+	router.Handle("DELETE /v1/profile/voice-prompt", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleDeleteVoicePrompt())))
+	router.Handle("GET /v1/attributes", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetAttributes())))
+	router.Handle("GET /v1/interests", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetInterests())))
+	router.Handle("GET /v1/profile/interests", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetProfileInterests())))
+	router.Handle("PUT /v1/profile/interests", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleSetProfileInterests())))
+	router.Handle("GET /v1/verification", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetVerification())))
+	router.Handle("POST /v1/verification", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleStartVerification())))
+	router.Handle("POST /v1/verification/selfie", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleSubmitVerificationSelfie())))
+	router.Handle("DELETE /v1/profile/passes", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleResetPasses())))
 	router.Handle("POST /v1/profile/location", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleUpdateProfileLocation())))
 	router.Handle("POST /v1/profile/block", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleBlockProfile())))
 	router.Handle("POST /v1/profile/unblock", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleUnblockProfile())))
@@ -82,6 +112,12 @@ func Router(liveDB, demoDB *gorm.DB, rdb *redis.Client, s3 *s3.S3, rlmdb *redis.
 	router.Handle("GET /v1/match/pending", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetPendingMatches())))
 	router.Handle("GET /v1/match/pending/target", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetPendingMatchTarget())))
 
+	// Date Routes
+	router.Handle("GET /v1/match/{matchId}/dates", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleGetMatchDatePlans())))
+	router.Handle("POST /v1/match/{matchId}/dates", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleCreateDatePlan())))
+	router.Handle("POST /v1/dates/{dateId}/rsvp", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleRSVPDatePlan())))
+	router.Handle("POST /v1/dates/{dateId}/cancel", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleCancelDatePlan())))
+
 	//Bug Routes
 	router.Handle("POST /v1/bug", middlewareProvider.AuthMiddleware(middlewareProvider.DatabaseMiddleware(handler.HandleReportBug())))
 
@@ -138,6 +174,26 @@ func Router(liveDB, demoDB *gorm.DB, rdb *redis.Client, s3 *s3.S3, rlmdb *redis.
 	router.HandleFunc("GET /admin/users/profiles/{profileId}", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetProfile()))
 	router.HandleFunc("PATCH /admin/users/profiles/{profileId}", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminUpdateProfile()))
 	router.HandleFunc("DELETE /admin/users/profiles/{profileId}", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminDeleteProfile()))
+	router.HandleFunc("GET /admin/users/profiles/{profileId}/usernames", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetUsernameHistory()))
+	router.HandleFunc("GET /admin/users/profiles/{profileId}/date-of-birth-changes", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetDateOfBirthHistory()))
+	router.HandleFunc("PATCH /admin/photos/{photoId}/moderation", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminModeratePhoto()))
+	router.HandleFunc("GET /admin/duplicate-photos", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetDuplicatePhotos()))
+	router.HandleFunc("PATCH /admin/duplicate-photos/{flagId}", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminResolveDuplicatePhoto()))
+	router.HandleFunc("GET /admin/verification", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetVerifications()))
+	router.HandleFunc("PATCH /admin/verification/{requestId}", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminReviewVerification()))
+	router.HandleFunc("GET /admin/date-of-birth-changes", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetDateOfBirthChanges()))
+	router.HandleFunc("PATCH /admin/date-of-birth-changes/{changeId}", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminReviewDateOfBirthChange()))
+	router.HandleFunc("GET /admin/prompts", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetPrompts()))
+	router.HandleFunc("POST /admin/prompts", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminCreatePrompt()))
+	router.HandleFunc("PATCH /admin/prompts/{promptId}", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminUpdatePrompt()))
+	router.HandleFunc("DELETE /admin/prompts/{promptId}", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminDeletePrompt()))
+	router.HandleFunc("GET /admin/attributes", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetAttributes()))
+	router.HandleFunc("POST /admin/attributes", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminCreateAttribute()))
+	router.HandleFunc("PATCH /admin/attributes/{attributeId}", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminUpdateAttribute()))
+	router.HandleFunc("DELETE /admin/attributes/{attributeId}", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminDeleteAttribute()))
+	router.HandleFunc("GET /admin/interests", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetInterests()))
+	router.HandleFunc("POST /admin/interests", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminCreateInterest()))
+	router.HandleFunc("PATCH /admin/interests/{interestId}", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminUpdateInterest()))
 	router.HandleFunc("GET /admin/users/profiles/{profileId}/friends", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetProfileFriends()))
 	router.HandleFunc("POST /admin/users/profiles/{profileId}/friends", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminCreateFriendship()))
 	router.HandleFunc("DELETE /admin/users/profiles/{profileId}/friends", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminDeleteFriendship()))
@@ -152,6 +208,11 @@ func Router(liveDB, demoDB *gorm.DB, rdb *redis.Client, s3 *s3.S3, rlmdb *redis.
 	router.HandleFunc("POST /admin/users/demo/seed", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminSeedDemoDatabase()))
 	router.HandleFunc("GET /admin/reports", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetReports()))
 	router.HandleFunc("DELETE /admin/reports/{reportId}", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminDeleteReport()))
+	router.HandleFunc("GET /admin/discovery/weights", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetDiscoveryWeights()))
+	router.HandleFunc("PUT /admin/discovery/weights", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminUpdateDiscoveryWeights()))
+	router.HandleFunc("GET /admin/discovery/recycle-policy", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminGetRecyclePolicy()))
+	router.HandleFunc("PUT /admin/discovery/recycle-policy", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminUpdateRecyclePolicy()))
+	router.HandleFunc("GET /admin/discovery/{profileId}/explain", middlewareProvider.AdminAuthMiddleware(handler.HandleAdminExplainDiscovery()))
 
 	return router
 }
@@ -0,0 +1,10 @@
+package router
+
+import "testing"
+
+// TestRouter registers every route, so patterns that conflict with each other panic here instead of at startup
+func TestRouter(t *testing.T) {
+	if Router(nil, nil, nil, nil, nil, nil, nil, false) == nil {
+		t.Fatal("Router returned nil")
+	}
+}
@@ -0,0 +1,64 @@
+package schemas
+
+import "time"
+
+// ProfileAttribute defines an extra profile field, such as height or smoking, so new fields can be added by admins
+// without a migration. Select and multi select attributes only accept AllowedValues; number attributes are checked
+// against Min and Max when set. Private attributes are only shown to the profile's owner and can't be filtered on.
+type ProfileAttribute struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Key           string    `gorm:"size:64;not null;uniqueIndex" json:"key"`
+	Label         string    `gorm:"size:128;not null" json:"label"`
+	Type          string    `gorm:"type:enum('text','number','boolean','select','multi_select');not null" json:"type"`
+	AllowedValues []string  `gorm:"serializer:json;type:text" json:"allowed_values,omitempty"`
+	Min           *float64  `json:"min,omitempty"`
+	Max           *float64  `json:"max,omitempty"`
+	Visibility    string    `gorm:"type:enum('public','private');default:'public';not null" json:"visibility"`
+	Filterable    bool      `gorm:"not null;default:false" json:"filterable"`
+	Active        bool      `gorm:"not null;default:true" json:"active"`
+	Position      int       `json:"position"`
+}
+
+// Profile attribute types
+const (
+	AttributeTypeText        = "text"
+	AttributeTypeNumber      = "number"
+	AttributeTypeBoolean     = "boolean"
+	AttributeTypeSelect      = "select"
+	AttributeTypeMultiSelect = "multi_select"
+)
+
+// Profile attribute visibilities
+const (
+	AttributeVisibilityPublic  = "public"
+	AttributeVisibilityPrivate = "private"
+)
+
+// ProfileAttributeValue is a profile's value for an attribute. Values are stored as text so discovery can filter on
+// them in SQL; a multi select attribute has one row per picked value.
+type ProfileAttributeValue struct {
+	ProfileID   uint      `gorm:"primaryKey" json:"profile_id"`
+	AttributeID uint      `gorm:"primaryKey;index" json:"attribute_id"`
+	Value       string    `gorm:"primaryKey;size:255" json:"value"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Attributes maps attribute keys to a profile's values: a string for text and select, a number, a bool, or a list of
+// strings for multi select
+type Attributes map[string]interface{}
+
+// DiscoveryAttributeFilter is a pro user's discovery filter on a filterable attribute. Number attributes match
+// between Min and Max, the other types match any of Values. Like the other discovery filters, a required filter
+// removes candidates that don't match it; otherwise matching only boosts the candidate's rank.
+type DiscoveryAttributeFilter struct {
+	ProfileID   uint             `gorm:"primaryKey" json:"-"`
+	AttributeID uint             `gorm:"primaryKey" json:"attribute_id"`
+	Attribute   ProfileAttribute `gorm:"foreignKey:AttributeID;constraint:OnDelete:CASCADE" json:"-"`
+	Key         string           `gorm:"-" json:"key"`
+	Values      []string         `gorm:"serializer:json;type:text" json:"values,omitempty"`
+	Min         *float64         `json:"min,omitempty"`
+	Max         *float64         `json:"max,omitempty"`
+	Required    bool             `json:"required"`
+}
@@ -0,0 +1,30 @@
+package schemas
+
+import "time"
+
+// DateOfBirthChange is the audit record of a date of birth being set or changed. Users get one change they can make
+// themselves, after which changes wait for an admin to approve them.
+type DateOfBirthChange struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	ProfileID       uint       `gorm:"index" json:"profile_id"`
+	OldDateOfBirth  time.Time  `json:"old_date_of_birth"`
+	NewDateOfBirth  time.Time  `json:"new_date_of_birth"`
+	Status          string     `gorm:"type:enum('initial','applied','pending','approved','rejected','admin');not null;index" json:"status"`
+	Reason          string     `json:"reason,omitempty"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	ReviewedBy      *uint      `json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// Date of birth change states. Initial is the first time a missing date is filled in, applied is the user's one
+// self-service change and admin is a change made directly by an admin.
+const (
+	DateOfBirthInitial  = "initial"
+	DateOfBirthApplied  = "applied"
+	DateOfBirthPending  = "pending"
+	DateOfBirthApproved = "approved"
+	DateOfBirthRejected = "rejected"
+	DateOfBirthAdmin    = "admin"
+)
@@ -0,0 +1,11 @@
+package schemas
+
+import "time"
+
+// CompletenessNudge records the pushes sent to a profile asking it to fill in what's missing, so nudges are spaced
+// out and stop after a few
+type CompletenessNudge struct {
+	ProfileID  uint      `gorm:"primaryKey" json:"profile_id"`
+	Count      int       `gorm:"not null;default:0" json:"count"`
+	LastSentAt time.Time `json:"last_sent_at"`
+}
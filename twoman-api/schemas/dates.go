@@ -0,0 +1,60 @@
+package schemas
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DatePlan is a concrete plan (time + venue) proposed inside an accepted match
+type DatePlan struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	MatchID        uint       `gorm:"index" json:"match_id"`
+	ProposedByID   uint       `json:"proposed_by_id"`
+	ProposedTime   time.Time  `gorm:"index" json:"proposed_time"`
+	VenueName      string     `json:"venue_name"`
+	VenueLocation  Point      `gorm:"type:point;not null;SRID:4326" json:"-"`
+	VenueLat       float64    `gorm:"-" json:"venue_lat"`
+	VenueLon       float64    `gorm:"-" json:"venue_lon"`
+	Note           string     `json:"note"`
+	Status         string     `gorm:"type:enum('proposed','confirmed','cancelled');default:'proposed'" json:"status"`
+	ReminderSentAt *time.Time `json:"-"`
+	Match          Matches    `gorm:"foreignKey:MatchID;constraint:OnDelete:CASCADE" json:"-"`
+	ProposedBy     Profile    `gorm:"foreignKey:ProposedByID" json:"proposed_by"`
+	RSVPs          []DateRSVP `gorm:"foreignKey:DatePlanID;constraint:OnDelete:CASCADE" json:"rsvps"`
+}
+
+// DateRSVP is a single participant's response to a DatePlan
+type DateRSVP struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	DatePlanID uint      `gorm:"uniqueIndex:idx_date_rsvp_profile" json:"date_plan_id"`
+	ProfileID  uint      `gorm:"uniqueIndex:idx_date_rsvp_profile" json:"profile_id"`
+	Response   string    `gorm:"type:enum('going','maybe','declined')" json:"response"`
+}
+
+// AfterFind exposes the venue coordinates, which do not serialize through Point
+func (d *DatePlan) AfterFind(tx *gorm.DB) error {
+	if d.VenueLocation.Point != nil {
+		d.VenueLat = d.VenueLocation.Y()
+		d.VenueLon = d.VenueLocation.X()
+	}
+	return nil
+}
+
+// Constants for date plan status
+const (
+	DateStatusProposed  = "proposed"
+	DateStatusConfirmed = "confirmed"
+	DateStatusCancelled = "cancelled"
+)
+
+// Constants for RSVP responses
+const (
+	RSVPGoing    = "going"
+	RSVPMaybe    = "maybe"
+	RSVPDeclined = "declined"
+)
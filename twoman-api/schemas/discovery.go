@@ -0,0 +1,67 @@
+package schemas
+
+import "time"
+
+// DiscoveryWeights controls how much each ranking signal contributes to a discovery score.
+// A single row (ID 1) is stored; when it doesn't exist the defaults are used.
+type DiscoveryWeights struct {
+	ID              uint      `gorm:"primaryKey" json:"-"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	Distance        float64   `json:"distance"`
+	Activity        float64   `json:"activity"`
+	InterestOverlap float64   `json:"interest_overlap"`
+	ReciprocalFit   float64   `json:"reciprocal_fit"`
+	InboundLike     float64   `json:"inbound_like"`
+	FilterMatch     float64   `gorm:"default:0.15" json:"filter_match"`
+	Completeness    float64   `gorm:"default:0.10" json:"completeness"`
+}
+
+// DiscoveryFilters are a pro user's extra discovery filters. Each field is a comma separated list, like
+// Profile.Interests, and interests match against the slugs of a candidate's catalog interests. A required filter
+// removes candidates that don't match it; otherwise matching only boosts the candidate's rank. VerifiedOnly always
+// removes candidates without a verified selfie. Attributes holds filters on filterable profile attributes.
+type DiscoveryFilters struct {
+	ProfileID          uint                       `gorm:"primaryKey" json:"profile_id"`
+	CreatedAt          time.Time                  `json:"created_at"`
+	UpdatedAt          time.Time                  `json:"updated_at"`
+	Education          string                     `json:"education"`
+	EducationRequired  bool                       `json:"education_required"`
+	Occupation         string                     `json:"occupation"`
+	OccupationRequired bool                       `json:"occupation_required"`
+	Interests          string                     `json:"interests"`
+	InterestsRequired  bool                       `json:"interests_required"`
+	VerifiedOnly       bool                       `json:"verified_only"`
+	Attributes         []DiscoveryAttributeFilter `gorm:"foreignKey:ProfileID;references:ProfileID" json:"attributes"`
+}
+
+// DiscoveryRecyclePolicy controls when passed profiles are shown again. Profiles whose photos or bio changed
+// since the pass come back after the shorter ChangedContentCooldownDays. A single row (ID 1) is stored.
+type DiscoveryRecyclePolicy struct {
+	ID                         uint      `gorm:"primaryKey" json:"-"`
+	UpdatedAt                  time.Time `json:"updated_at"`
+	Enabled                    bool      `json:"enabled"`
+	CooldownDays               int       `json:"cooldown_days"`
+	ChangedContentCooldownDays int       `json:"changed_content_cooldown_days"`
+}
+
+// SeenProfiles is the compacted set of profiles a user has swiped on. Each row covers 64 profile IDs starting
+// at Chunk*64, with bit i of Bits set when profile Chunk*64+i was seen.
+type SeenProfiles struct {
+	UserID uint   `gorm:"primaryKey;autoIncrement:false"`
+	Chunk  uint   `gorm:"primaryKey;autoIncrement:false"`
+	Bits   uint64 `gorm:"not null;default:0"`
+}
+
+// ProfilePass records when a user last passed on a profile. Likes aren't stored here since they become matches.
+type ProfilePass struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false"`
+	ProfileID uint      `gorm:"primaryKey;autoIncrement:false"`
+	PassedAt  time.Time `gorm:"not null"`
+}
+
+// SwipeStats counts how many profiles a user has liked and passed on, so ranking doesn't have to count them
+type SwipeStats struct {
+	UserID uint `gorm:"primaryKey;autoIncrement:false"`
+	Likes  int  `gorm:"not null;default:0"`
+	Passes int  `gorm:"not null;default:0"`
+}
@@ -8,6 +8,21 @@ type FileMetadata struct {
 	UpdatedAt time.Time
 	Filename  string
 	Size      int64
-	UserID    uint
-	User      User `gorm:"foreignKey:UserID;"`
+	// ContentType is what the file is served as. It is empty for images uploaded before audio was supported.
+	ContentType string
+	// Image uploads only. Renditions lists the object keys of the smaller renditions, comma separated.
+	Blurhash       string
+	PerceptualHash *uint64 `gorm:"index"`
+	Width          int
+	Height         int
+	Renditions     string
+	UserID         uint
+	User           User `gorm:"foreignKey:UserID;"`
+	// The perceptual hash split into 16 bit chunks, highest first, so near copies can be looked up by index
+	HashChunk1 *uint16 `gorm:"index"`
+	HashChunk2 *uint16 `gorm:"index"`
+	HashChunk3 *uint16 `gorm:"index"`
+	HashChunk4 *uint16 `gorm:"index"`
+	// Audio uploads only
+	DurationMs int
 }
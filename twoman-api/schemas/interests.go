@@ -0,0 +1,33 @@
+package schemas
+
+import "time"
+
+// InterestCategory groups the interest catalog, e.g. Sports or Food & Drink
+type InterestCategory struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Name      string     `gorm:"size:64;not null;uniqueIndex" json:"name"`
+	Position  int        `json:"position"`
+	Interests []Interest `gorm:"foreignKey:CategoryID" json:"interests,omitempty"`
+}
+
+// Interest is one entry in the interest catalog. Slug is the normalised name that matching uses. Inactive interests
+// aren't offered to pick any more, but still count for profiles that have them, e.g. free text interests from before
+// the catalog existed.
+type Interest struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	CategoryID uint      `gorm:"index" json:"category_id"`
+	Name       string    `gorm:"size:64;not null" json:"name"`
+	Slug       string    `gorm:"size:64;not null;uniqueIndex" json:"slug"`
+	Active     bool      `gorm:"not null;default:true" json:"active"`
+}
+
+// ProfileInterest links a profile to an interest it picked
+type ProfileInterest struct {
+	ProfileID  uint      `gorm:"primaryKey" json:"profile_id"`
+	InterestID uint      `gorm:"primaryKey;index" json:"interest_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
@@ -0,0 +1,51 @@
+package schemas
+
+import "time"
+
+// ProfilePhoto is one photo in a profile's gallery. The primary photo is always first. Profile.Image1..Image4
+// mirror the first four photos that haven't been rejected, for clients that predate the gallery.
+type ProfilePhoto struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	ProfileID        uint      `gorm:"index" json:"profile_id"`
+	URL              string    `json:"url"`
+	ThumbnailURL     string    `json:"thumbnail_url,omitempty"`
+	MediumURL        string    `json:"medium_url,omitempty"`
+	Blurhash         string    `json:"blurhash,omitempty"`
+	Filename         string    `json:"-"`
+	Position         int       `json:"position"`
+	Caption          string    `json:"caption"`
+	IsPrimary        bool      `json:"is_primary"`
+	ModerationStatus string    `gorm:"type:enum('pending','approved','rejected');default:'pending';not null" json:"moderation_status"`
+}
+
+// Photo moderation states. Pending photos are shown until a moderator rejects them.
+const (
+	PhotoModerationPending  = "pending"
+	PhotoModerationApproved = "approved"
+	PhotoModerationRejected = "rejected"
+)
+
+// DuplicatePhotoFlag queues an upload whose perceptual hash is close to a photo uploaded by another account, so an
+// admin can compare both accounts side by side
+type DuplicatePhotoFlag struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	FileID        uint       `gorm:"index" json:"file_id"`
+	UserID        uint       `gorm:"index" json:"user_id"`
+	MatchedFileID uint       `gorm:"index" json:"matched_file_id"`
+	MatchedUserID uint       `gorm:"index" json:"matched_user_id"`
+	Distance      int        `json:"distance"`
+	Status        string     `gorm:"type:enum('pending','dismissed','confirmed');default:'pending';not null;index" json:"status"`
+	ReviewedBy    *uint      `json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// Duplicate photo review outcomes. Confirming a flag rejects the uploader's copy of the photo.
+const (
+	DuplicateFlagPending   = "pending"
+	DuplicateFlagDismissed = "dismissed"
+	DuplicateFlagConfirmed = "confirmed"
+)
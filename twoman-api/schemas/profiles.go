@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"log"
 	"time"
+	"twoman/utils"
 
 	"github.com/twpayne/go-geom"
 	"github.com/twpayne/go-geom/encoding/wkb"
+	"gorm.io/gorm"
 )
 
 type Point struct {
@@ -17,33 +19,140 @@ type Point struct {
 }
 
 type Profile struct {
-	UserID               uint      `gorm:"primaryKey" json:"user_id"`
-	Name                 string    `json:"name"`
-	Username             string    `gorm:"uniqueIndex" json:"username"`
-	Bio                  string    `json:"bio"`
-	Gender               string    `json:"gender"`
-	DateOfBirth          time.Time `json:"date_of_birth"`
-	LocationPoint        Point     `gorm:"type:point;not null;SRID:4326" json:"location_point"`
-	City                 string    `json:"city"`
-	Education            string    `json:"education"`
-	Occupation           string    `json:"occupation"`
-	Interests            string    `json:"interests"`
-	Image1               string    `json:"image1"`
-	Image2               string    `json:"image2"`
-	Image3               string    `json:"image3"`
-	Image4               string    `json:"image4"`
-	PreferredGender      string    `json:"preferred_gender"`
-	PreferredAgeMin      int       `json:"preferred_age_min"`
-	PreferredAgeMax      int       `json:"preferred_age_max"`
-	PreferredDistanceMax int       `json:"preferred_distance_max"`
+	UserID               uint           `gorm:"primaryKey" json:"user_id"`
+	Name                 string         `json:"name"`
+	Username             string         `gorm:"uniqueIndex" json:"username"`
+	Bio                  string         `json:"bio"`
+	Gender               string         `json:"gender"`
+	DateOfBirth          time.Time      `json:"date_of_birth"`
+	LocationPoint        Point          `gorm:"type:point;not null;SRID:4326" json:"-"`
+	City                 string         `json:"city"`
+	Education            string         `json:"education"`
+	Occupation           string         `json:"occupation"`
+	Interests            string         `json:"interests"` // Names of InterestTags, for clients that predate the catalog
+	Image1               string         `json:"image1"`
+	Image2               string         `json:"image2"`
+	Image3               string         `json:"image3"`
+	Image4               string         `json:"image4"`
+	PreferredGender      string         `json:"preferred_gender"`
+	PreferredAgeMin      int            `json:"preferred_age_min"`
+	PreferredAgeMax      int            `json:"preferred_age_max"`
+	PreferredDistanceMax int            `json:"preferred_distance_max"`
+	TravelLocationPoint  *Point         `gorm:"type:point;SRID:4326" json:"-"`
+	TravelCity           string         `json:"-"`
+	TravelExpiresAt      *time.Time     `gorm:"index" json:"travel_expires_at,omitempty"`
+	TravelingTo          string         `gorm:"-" json:"traveling_to,omitempty"`
+	ContentUpdatedAt     *time.Time     `json:"-"`
+	LastActiveAt         *time.Time     `gorm:"index" json:"-"`
+	ActivityBadge        string         `gorm:"-" json:"activity_badge,omitempty"`
+	Visibility           string         `gorm:"type:enum('visible','incognito','paused');default:'visible';not null" json:"-"`
+	Verified             bool           `gorm:"not null;default:false" json:"verified"`
+	Location             *Location      `gorm:"-" json:"location,omitempty"`
+	Distance             string         `gorm:"-" json:"distance,omitempty"`
+	OwnVisibility        string         `gorm:"-" json:"visibility,omitempty"`
+	Photos               []ProfilePhoto `gorm:"-" json:"photos,omitempty"`
+	Prompts              []PromptAnswer `gorm:"-" json:"prompts,omitempty"`
+	VoicePrompt          *VoicePrompt   `gorm:"-" json:"voice_prompt,omitempty"`
+	InterestTags         []Interest     `gorm:"-" json:"interest_tags,omitempty"`
+	Attributes           Attributes     `gorm:"-" json:"attributes,omitempty"`
+}
+
+// Location holds exact coordinates, which are only ever returned to the profile's owner and admins
+type Location struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+// IsTraveling reports whether the profile has a travel location that hasn't expired
+func (p *Profile) IsTraveling() bool {
+	return p.TravelLocationPoint != nil && p.TravelLocationPoint.Point != nil &&
+		p.TravelExpiresAt != nil && p.TravelExpiresAt.After(time.Now())
+}
+
+// EffectiveLocation is the location used for discovery: the travel location while traveling, otherwise the real one
+func (p *Profile) EffectiveLocation() Point {
+	if p.IsTraveling() {
+		return *p.TravelLocationPoint
+	}
+	return p.LocationPoint
+}
+
+// SetTravelingTo fills TravelingTo with the travel city while the travel location is active
+func (p *Profile) SetTravelingTo() {
+	p.TravelingTo = ""
+	if p.IsTraveling() {
+		p.TravelingTo = p.TravelCity
+	}
+}
+
+// ShowLocation fills Location with the profile's coordinates. Only use it when the profile is sent to its owner or
+// an admin.
+func (p *Profile) ShowLocation() {
+	if p.LocationPoint.Point == nil {
+		return
+	}
+	p.Location = &Location{Lat: p.LocationPoint.Y(), Lon: p.LocationPoint.X()}
+}
+
+// ShowVisibility fills OwnVisibility with the profile's visibility. Only use it when the profile is sent to its owner,
+// since it would tell others that someone who liked them is incognito or that a match has paused.
+func (p *Profile) ShowVisibility() {
+	p.OwnVisibility = p.Visibility
+}
+
+// SetDistanceFrom fills Distance with a rounded, fuzzed distance between the viewer and this profile
+func (p *Profile) SetDistanceFrom(viewer Profile) {
+	p.Distance = ""
+
+	from, to := viewer.EffectiveLocation(), p.EffectiveLocation()
+	if from.Point == nil || to.Point == nil || viewer.UserID == p.UserID {
+		return
+	}
+
+	p.Distance = utils.FuzzyDistance(from.Y(), from.X(), to.Y(), to.X(), viewer.UserID, p.UserID)
+}
+
+// Profile visibility modes. Incognito profiles are only shown to people they have liked, paused profiles aren't
+// shown to anyone new but keep their matches and chats.
+const (
+	VisibilityVisible   = "visible"
+	VisibilityIncognito = "incognito"
+	VisibilityPaused    = "paused"
+)
+
+// Activity badges shown on profiles, based on LastActiveAt
+const (
+	ActivityBadgeToday    = "Active today"
+	ActivityBadgeThisWeek = "Active this week"
+)
+
+// SetActivityBadge fills ActivityBadge from LastActiveAt. Exact activity times are never returned.
+func (p *Profile) SetActivityBadge() {
+	p.ActivityBadge = ""
+	if p.LastActiveAt == nil {
+		return
+	}
+
+	switch since := time.Since(*p.LastActiveAt); {
+	case since < 24*time.Hour:
+		p.ActivityBadge = ActivityBadgeToday
+	case since < 7*24*time.Hour:
+		p.ActivityBadge = ActivityBadgeThisWeek
+	}
+}
+
+func (p *Profile) AfterFind(tx *gorm.DB) error {
+	p.SetTravelingTo()
+	p.SetActivityBadge()
+	return nil
 }
 
 type ProfileView struct {
 	ID        uint `gorm:"primarykey"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	UserID    uint `json:"user_id"`
-	ProfileID uint `json:"profile_id"`
+	UserID    uint `gorm:"index:idx_profile_views_user_profile" json:"user_id"`
+	ProfileID uint `gorm:"index:idx_profile_views_user_profile" json:"profile_id"`
 }
 
 func NewPoint(latitude, longitude float64) *Point {
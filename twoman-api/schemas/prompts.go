@@ -0,0 +1,39 @@
+package schemas
+
+import "time"
+
+// Prompt is a question from the catalog admins manage. Inactive prompts can't be picked for new answers, but
+// existing answers to them stay on profiles.
+type Prompt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Text      string    `gorm:"size:255;not null;uniqueIndex" json:"text"`
+	Category  string    `gorm:"size:64;index" json:"category"`
+	Active    bool      `gorm:"not null;default:true" json:"active"`
+}
+
+// PromptAnswer is a profile's answer to a prompt, shown as a card between photos
+type PromptAnswer struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ProfileID uint      `gorm:"uniqueIndex:idx_prompt_answers_profile_prompt" json:"profile_id"`
+	PromptID  uint      `gorm:"uniqueIndex:idx_prompt_answers_profile_prompt" json:"prompt_id"`
+	Prompt    Prompt    `gorm:"foreignKey:PromptID;constraint:OnDelete:RESTRICT" json:"prompt"`
+	Answer    string    `json:"answer"`
+	Position  int       `json:"position"`
+}
+
+// VoicePrompt is a profile's recorded voice intro. It can answer a prompt from the catalog or stand on its own.
+type VoicePrompt struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	ProfileID  uint      `gorm:"uniqueIndex" json:"profile_id"`
+	PromptID   *uint     `json:"prompt_id,omitempty"`
+	Prompt     *Prompt   `gorm:"foreignKey:PromptID;constraint:OnDelete:RESTRICT" json:"prompt,omitempty"`
+	URL        string    `json:"url"`
+	Filename   string    `json:"-"`
+	DurationMs int       `json:"duration_ms"`
+}
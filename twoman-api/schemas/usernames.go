@@ -0,0 +1,15 @@
+package schemas
+
+import "time"
+
+// UsernameChange is one entry in a profile's username history. The old username stays reserved for the profile
+// until ReservedUntil, so nobody else can take it and invites sent to it still reach the same person.
+type UsernameChange struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ProfileID      uint      `gorm:"index" json:"profile_id"`
+	OldUsername    string    `gorm:"size:50;index" json:"old_username"`
+	NewUsername    string    `gorm:"size:50" json:"new_username"`
+	ChangedByAdmin bool      `gorm:"not null;default:false" json:"changed_by_admin"`
+	ReservedUntil  time.Time `gorm:"index" json:"reserved_until"`
+}
@@ -0,0 +1,27 @@
+package schemas
+
+import "time"
+
+// VerificationRequest is a selfie verification attempt. The user is assigned a random pose to copy, so a photo
+// taken from somewhere else can't be submitted. Selfies are kept in a private bucket and deleted once reviewed.
+type VerificationRequest struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	UserID          uint       `gorm:"index" json:"user_id"`
+	Pose            string     `json:"pose"`
+	SelfieKey       string     `json:"-"`
+	Status          string     `gorm:"type:enum('awaiting_selfie','pending','approved','rejected');default:'awaiting_selfie';not null;index" json:"status"`
+	RejectionReason string     `json:"rejection_reason,omitempty"`
+	SubmittedAt     *time.Time `json:"submitted_at,omitempty"`
+	ReviewedBy      *uint      `json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// Verification request states
+const (
+	VerificationAwaitingSelfie = "awaiting_selfie"
+	VerificationPending        = "pending"
+	VerificationApproved       = "approved"
+	VerificationRejected       = "rejected"
+)
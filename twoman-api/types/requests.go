@@ -44,10 +44,33 @@ type UpdateProfileRequest struct {
 	PreferredAgeMin      int    `json:"preferred_age_min"`
 	PreferredAgeMax      int    `json:"preferred_age_max"`
 	PreferredDistanceMax int    `json:"preferred_distance_max"`
+	// Values of profile attributes by key. Attributes left out are unchanged and null removes a value.
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+type UpdateDiscoveryFiltersRequest struct {
+	Education          string                   `json:"education"`
+	EducationRequired  bool                     `json:"education_required"`
+	Occupation         string                   `json:"occupation"`
+	OccupationRequired bool                     `json:"occupation_required"`
+	Interests          string                   `json:"interests"`
+	InterestsRequired  bool                     `json:"interests_required"`
+	VerifiedOnly       bool                     `json:"verified_only"`
+	Attributes         []AttributeFilterRequest `json:"attributes"`
+}
+
+type AttributeFilterRequest struct {
+	Key      string   `json:"key"`
+	Values   []string `json:"values,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	Required bool     `json:"required"`
 }
 
 type UpdateDateOfBirthRequest struct {
 	DateOfBirth string `json:"date_of_birth"`
+	// Why the date is being changed, shown to admins when the change needs approval
+	Reason string `json:"reason,omitempty"`
 }
 
 type UpdateProfileLocationRequest struct {
@@ -55,6 +78,52 @@ type UpdateProfileLocationRequest struct {
 	Lon float64 `json:"lon"`
 }
 
+type SetTravelLocationRequest struct {
+	Lat       *float64 `json:"lat"`
+	Lon       *float64 `json:"lon"`
+	ExpiresAt string   `json:"expires_at"`
+}
+
+type UpdateVisibilityRequest struct {
+	Visibility string `json:"visibility"`
+}
+
+type AddProfilePhotoRequest struct {
+	URL     string `json:"url"`
+	Caption string `json:"caption"`
+}
+
+type UpdateProfilePhotoRequest struct {
+	Caption   *string `json:"caption,omitempty"`
+	IsPrimary bool    `json:"is_primary"`
+}
+
+type ReorderProfilePhotosRequest struct {
+	PhotoIDs []uint `json:"photo_ids"`
+}
+
+type AnswerPromptRequest struct {
+	PromptID uint   `json:"prompt_id"`
+	Answer   string `json:"answer"`
+}
+
+type UpdatePromptAnswerRequest struct {
+	Answer string `json:"answer"`
+}
+
+type ReorderPromptAnswersRequest struct {
+	AnswerIDs []uint `json:"answer_ids"`
+}
+
+type SetVoicePromptRequest struct {
+	URL      string `json:"url"`
+	PromptID *uint  `json:"prompt_id,omitempty"`
+}
+
+type SetProfileInterestsRequest struct {
+	InterestIDs []uint `json:"interest_ids"`
+}
+
 type PhoneAuthRequest struct {
 	PhoneNumber string `json:"phone_number"`
 }
@@ -130,6 +199,10 @@ type UpdateNotificationPreferencesRequest struct {
 	NewFriendRequestNotificationsEnabled bool   `json:"new_friend_request_notifications_enabled"`
 }
 
+type ChangeUsernameRequest struct {
+	Username string `json:"username"`
+}
+
 type AdminAuthRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -170,6 +243,69 @@ type AdminUpdateFlagRequest struct {
 	IsEnabled bool `json:"is_enabled"`
 }
 
+type AdminModeratePhotoRequest struct {
+	Status string `json:"status"`
+}
+
+type AdminResolveDuplicatePhotoRequest struct {
+	Status string `json:"status"`
+}
+
+type AdminReviewVerificationRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+type AdminReviewDateOfBirthRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+type AdminCreatePromptRequest struct {
+	Text     string `json:"text"`
+	Category string `json:"category"`
+}
+
+type AdminUpdatePromptRequest struct {
+	Text     *string `json:"text,omitempty"`
+	Category *string `json:"category,omitempty"`
+	Active   *bool   `json:"active,omitempty"`
+}
+
+type AdminCreateInterestRequest struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+type AdminUpdateInterestRequest struct {
+	Name       *string `json:"name,omitempty"`
+	CategoryID *uint   `json:"category_id,omitempty"`
+	Active     *bool   `json:"active,omitempty"`
+}
+
+type AdminCreateAttributeRequest struct {
+	Key           string   `json:"key"`
+	Label         string   `json:"label"`
+	Type          string   `json:"type"`
+	AllowedValues []string `json:"allowed_values,omitempty"`
+	Min           *float64 `json:"min,omitempty"`
+	Max           *float64 `json:"max,omitempty"`
+	Visibility    string   `json:"visibility,omitempty"`
+	Filterable    bool     `json:"filterable"`
+}
+
+type AdminUpdateAttributeRequest struct {
+	Label         *string   `json:"label,omitempty"`
+	Type          *string   `json:"type,omitempty"`
+	AllowedValues *[]string `json:"allowed_values,omitempty"`
+	Min           *float64  `json:"min,omitempty"`
+	Max           *float64  `json:"max,omitempty"`
+	Visibility    *string   `json:"visibility,omitempty"`
+	Filterable    *bool     `json:"filterable,omitempty"`
+	Active        *bool     `json:"active,omitempty"`
+	Position      *int      `json:"position,omitempty"`
+}
+
 type AdminUpdateProfileRequest struct {
 	Username             string  `json:"username"`
 	Name                 string  `json:"name"`
@@ -190,3 +326,15 @@ type AdminUpdateProfileRequest struct {
 	PreferredAgeMax      int     `json:"preferred_age_max"`
 	PreferredDistanceMax int     `json:"preferred_distance_max"`
 }
+
+type CreateDatePlanRequest struct {
+	ProposedTime string   `json:"proposed_time"`
+	VenueName    string   `json:"venue_name"`
+	VenueLat     *float64 `json:"venue_lat"`
+	VenueLon     *float64 `json:"venue_lon"`
+	Note         string   `json:"note"`
+}
+
+type DateRSVPRequest struct {
+	Response string `json:"response"`
+}
@@ -48,12 +48,14 @@ type SocketMatchData struct {
 }
 
 type SocketProfileDecisionData struct {
-	Decision      string `json:"decision"`
-	IsDuo         bool   `json:"is_duo,omitempty"`
-	FriendProfile uint   `json:"friend_profile,omitempty"`
-	TargetProfile uint   `json:"target_profile,omitempty"`
-	IsStandout    bool   `json:"is_standout,omitempty"`
-	StarsCost     int    `json:"stars_cost,omitempty"`
+	Decision            string `json:"decision"`
+	IsDuo               bool   `json:"is_duo,omitempty"`
+	FriendProfile       uint   `json:"friend_profile,omitempty"`
+	TargetProfile       uint   `json:"target_profile,omitempty"`
+	TargetFriendProfile uint   `json:"target_friend_profile,omitempty"`
+	IsStandout          bool   `json:"is_standout,omitempty"`
+	StarsCost           int    `json:"stars_cost,omitempty"`
+	PromptAnswerID      uint   `json:"prompt_answer_id,omitempty"`
 }
 
 type SocketProfileDiscoveryData struct {
@@ -1,24 +1,11 @@
 package utils
 
-func CompactImages(images []string) []string {
-	// Create a new slice to hold non-empty images
-	compact := []string{}
-
-	// Collect all non-empty images
-	for _, img := range images {
-		if img != "" {
-			compact = append(compact, img)
-		}
-	}
-
-	// Create the result with proper padding
-	result := make([]string, 4)
-	for i := 0; i < len(compact) && i < 4; i++ {
-		result[i] = compact[i]
-	}
-
-	return result
-}
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"time"
+)
 
 // Max returns the larger of two integers
 func Max(a, b int) int {
@@ -27,3 +14,80 @@ func Max(a, b int) int {
 	}
 	return b
 }
+
+// MinimumAge is the youngest anyone can be to have a profile
+const MinimumAge = 18
+
+// Age returns the age in whole years of someone born on dateOfBirth, as of now
+func Age(dateOfBirth time.Time, now time.Time) int {
+	age := now.Year() - dateOfBirth.Year()
+	if now.Month() < dateOfBirth.Month() || (now.Month() == dateOfBirth.Month() && now.Day() < dateOfBirth.Day()) {
+		age--
+	}
+	return age
+}
+
+// AgeSQL is Age as a MySQL expression, for the date in column as of today
+func AgeSQL(column string) string {
+	return "((YEAR(CURDATE()) - YEAR(" + column + ")) - (DATE_FORMAT(CURDATE(), '%m%d') < DATE_FORMAT(" + column + ", '%m%d')))"
+}
+
+// DistanceKm returns the great circle distance between two points, using the same earth radius as ST_Distance_Sphere
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6370.986
+
+	phi1, phi2 := lat1*math.Pi/180, lat2*math.Pi/180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// LocationGridKm is the size of the grid cells profiles' locations are snapped to before a distance is shown
+const LocationGridKm = 2.0
+
+// SnapToGrid moves a coordinate to the centre of its LocationGridKm grid cell. Cells are fixed, so distances measured
+// from anywhere only ever lead back to the cell, never to the exact location inside it.
+func SnapToGrid(lat, lon float64) (float64, float64) {
+	const kmPerDegree = 111.195
+
+	latStep := LocationGridKm / kmPerDegree
+	snappedLat := (math.Floor(lat/latStep) + 0.5) * latStep
+
+	// Degrees of longitude shrink towards the poles, so cells in each row are widened to stay about square
+	lonStep := latStep / math.Max(math.Cos(snappedLat*math.Pi/180), 0.01)
+	snappedLon := (math.Floor(lon/lonStep) + 0.5) * lonStep
+
+	return math.Max(-90, math.Min(90, snappedLat)), snappedLon
+}
+
+// FuzzyDistance describes the distance from the viewer to a profile without giving away exact locations, e.g.
+// "15 km away". The profile's location is snapped to the grid first, so moving the viewer around (e.g. with travel
+// mode) can't trilaterate it. The distance is then shifted by up to half a rounding step before rounding. The shift
+// only depends on viewerID and profileID, so asking again or averaging many requests doesn't narrow it down.
+func FuzzyDistance(viewerLat, viewerLon, profileLat, profileLon float64, viewerID uint, profileID uint) string {
+	profileLat, profileLon = SnapToGrid(profileLat, profileLon)
+	distanceKm := DistanceKm(viewerLat, viewerLon, profileLat, profileLon)
+
+	step := 1.0
+	switch {
+	case distanceKm >= 200:
+		step = 50
+	case distanceKm >= 50:
+		step = 10
+	case distanceKm >= 10:
+		step = 5
+	}
+
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d:%d", viewerID, profileID)
+	shift := float64(hash.Sum64()%1000)/1000 - 0.5
+
+	rounded := math.Round((distanceKm+shift*step)/step) * step
+	if rounded < 2 {
+		return "Less than 2 km away"
+	}
+
+	return fmt.Sprintf("%d km away", int(rounded))
+}
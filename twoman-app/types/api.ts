@@ -57,7 +57,11 @@ export interface Profile {
   gender: string;
   age?: number;
   date_of_birth: string;
-  location_point: string;
+  // Exact coordinates are only sent with the user's own profile, other profiles get a rounded distance
+  location?: { lat: number; lon: number };
+  distance?: string;
+  // Only sent with the user's own profile
+  visibility?: "visible" | "incognito" | "paused";
   city: string;
   education: string;
   occupation: string;